@@ -17,12 +17,13 @@ type AggregateMetadata struct {
 	EventMethods      []string
 	CommandMethods    []string
 	ll                loglib.LogLevelEnum
-	lg                loglib.Logger
+	lg                loglib.LeveledLogger
 	lmu               sync.Mutex
 	commandMethodsMap map[string]reflect.Method
 	eventMethodsMap   map[reflect.Type]reflect.Method
 	//eventTypesMap     TypeHintMapper
-	newInstance func() (*EntityMetadata, error)
+	newInstance      func() (*EntityMetadata, error)
+	snapshotStrategy SnapshotStrategy
 }
 
 func (meta *AggregateMetadata) String() string {
@@ -114,7 +115,7 @@ func CreateAggregateMetadata(newInstance interface{}, entityTypeName string) (*A
 						methodName,
 						commandType)
 				}
-				return nil, AppError("Signature mismatch. func (recv %s) %s(command %s). Ensure the argument `command` ends with 'Command', and the method returns a single value",
+				return nil, SignatureMismatchError("Signature mismatch. func (recv %s) %s(command %s). Ensure the argument `command` ends with 'Command', and the method returns a single value",
 					funcTypeOut,
 					methodName,
 					commandType)
@@ -136,7 +137,7 @@ func CreateAggregateMetadata(newInstance interface{}, entityTypeName string) (*A
 				eventMethods[classDef] = method
 				//eventTypes[eventCoreName] = classDef
 			} else {
-				return nil, AppError("Signature mismatch. func (recv %s) %s(Event %v). Ensure the argument `Event` is a structure whose name ends with 'Event', and the method returns a single value",
+				return nil, SignatureMismatchError("Signature mismatch. func (recv %s) %s(Event %v). Ensure the argument `Event` is a structure whose name ends with 'Event', and the method returns a single value",
 					funcTypeOut,
 					methodName,
 					classDef)
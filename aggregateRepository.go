@@ -1,7 +1,9 @@
 package eventuate
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
 	"reflect"
@@ -11,9 +13,14 @@ import (
 type AggregateRepository struct {
 	Client    Crud
 	ll        loglib.LogLevelEnum
-	lg        loglib.Logger
+	lg        loglib.LeveledLogger
 	typeHints typeHintsMap
 	meta      *AggregateMetadata
+	upcasters *UpcasterRegistry
+	codecs    *EventCodecs
+	encryptor *PIIEncryptor
+	metadata  EventMetadataProvider
+	tracer    Tracer
 }
 
 func (repo *AggregateRepository) RegisterEventType(name string, typeInstance interface{}) error {
@@ -22,22 +29,68 @@ func (repo *AggregateRepository) RegisterEventType(name string, typeInstance int
 
 func NewAggregateRepository(client Crud, meta *AggregateMetadata) *AggregateRepository {
 	var (
-		ll        loglib.LogLevelEnum = loglib.Silent
-		lg        loglib.Logger       = loglib.NewNilLogger()
-		typeHints typeHintsMap        = NewTypeHintsMap()
+		ll        loglib.LogLevelEnum  = loglib.Silent
+		lg        loglib.LeveledLogger = loglib.NewNilLogger()
+		typeHints typeHintsMap         = NewTypeHintsMap()
+		upcasters *UpcasterRegistry
+		codecs    *EventCodecs
+		encryptor *PIIEncryptor
+		metadata  EventMetadataProvider
+		tracer    Tracer
 	)
 	maybeRestClient, maybeRestClientOk := client.(*RESTClient)
 	if maybeRestClientOk {
 		ll = maybeRestClient.ll
 		lg = maybeRestClient.lg
 		typeHints = maybeRestClient.typeHints
+		upcasters = maybeRestClient.upcasters
+		codecs = maybeRestClient.codecs
+		encryptor = maybeRestClient.encryptor
+		metadata = maybeRestClient.metadata
+		tracer = maybeRestClient.tracer
 	}
 	return &AggregateRepository{
 		Client:    client,
 		ll:        ll,
 		lg:        lg,
 		typeHints: typeHints,
-		meta:      meta}
+		meta:      meta,
+		upcasters: upcasters,
+		codecs:    codecs,
+		encryptor: encryptor,
+		metadata:  metadata,
+		tracer:    tracer}
+}
+
+// SetUpcasters makes Find upcast the loaded events, new events are stamped with their current schema version
+func (repo *AggregateRepository) SetUpcasters(registry *UpcasterRegistry) *AggregateRepository {
+	repo.upcasters = registry
+	return repo
+}
+
+// SetEventCodecs sets the codecs events are serialized and deserialized with, JSON is used by default
+func (repo *AggregateRepository) SetEventCodecs(codecs *EventCodecs) *AggregateRepository {
+	repo.codecs = codecs
+	return repo
+}
+
+// SetPIIEncryptor makes the fields tagged `eventuate:"pii"` of the events and snapshots encrypted.
+// Saving then assigns the entity id on the client side, the data key of the entity is created with it.
+func (repo *AggregateRepository) SetPIIEncryptor(encryptor *PIIEncryptor) *AggregateRepository {
+	repo.encryptor = encryptor
+	return repo
+}
+
+// SetEventMetadataProvider makes the metadata supplied by `provider` attached to the events written by the repository
+func (repo *AggregateRepository) SetEventMetadataProvider(provider EventMetadataProvider) *AggregateRepository {
+	repo.metadata = provider
+	return repo
+}
+
+// SetTracer makes the operations of the repository traced, the trace context is added to the metadata of the new events
+func (repo *AggregateRepository) SetTracer(tracer Tracer) *AggregateRepository {
+	repo.tracer = tracer
+	return repo
 }
 
 func (repo *AggregateRepository) SetLogLevel(level loglib.LogLevelEnum) *AggregateRepository {
@@ -46,164 +99,457 @@ func (repo *AggregateRepository) SetLogLevel(level loglib.LogLevelEnum) *Aggrega
 	return repo
 }
 
+func (repo *AggregateRepository) SetLogger(lg loglib.LeveledLogger) *AggregateRepository {
+	repo.lg = lg
+	return repo
+}
+
 func (repo *AggregateRepository) Save(cmd Command) (*EntityMetadata, error) {
+	return repo.SaveWithOptions(cmd, nil)
+}
 
-	var meta *AggregateMetadata = repo.meta
+// SaveWithOptions is Save that passes `saveOptions.TriggeringEvent` to the server.
+// If the triggering event was already processed, the result has `AlreadyProcessed` set.
+func (repo *AggregateRepository) SaveWithOptions(cmd Command, saveOptions *SaveOptions) (*EntityMetadata, error) {
+	return repo.SaveCtx(context.Background(), cmd, saveOptions)
+}
+
+// SaveCtx is SaveWithOptions bound to `ctx`
+func (repo *AggregateRepository) SaveCtx(ctx context.Context, cmd Command, saveOptions *SaveOptions) (*EntityMetadata, error) {
+	return repo.flow().saveCtx(ctx, cmd, saveOptions)
+}
+
+func (repo *AggregateRepository) Update(entityId Int128, cmd Command) (*EntityMetadata, error) {
+	return repo.UpdateWithOptions(entityId, cmd, nil)
+}
 
+// UpdateWithOptions is Update that re-runs Find and the command on optimistic locking failures
+// according to `updateOptions.Retry` and passes `updateOptions.TriggeringEvent` to the server.
+// If the triggering event was already processed, the result has `AlreadyProcessed` set.
+func (repo *AggregateRepository) UpdateWithOptions(entityId Int128, cmd Command, updateOptions *UpdateOptions) (*EntityMetadata, error) {
+	return repo.UpdateCtx(context.Background(), entityId, cmd, updateOptions)
+}
+
+// UpdateCtx is UpdateWithOptions bound to `ctx`, a cancelled context also ends the waiting between retries
+func (repo *AggregateRepository) UpdateCtx(ctx context.Context, entityId Int128, cmd Command, updateOptions *UpdateOptions) (*EntityMetadata, error) {
+	return repo.flow().updateCtx(ctx, entityId, cmd, updateOptions)
+}
+
+func (repo *AggregateRepository) Find(entityId Int128) (*EntityMetadata, error) {
+	return repo.FindCtx(context.Background(), entityId)
+}
+
+func (repo *AggregateRepository) FindCtx(ctx context.Context, entityId Int128) (*EntityMetadata, error) {
+	loaded, err := repo.flow().findCtx(ctx, entityId)
+	if err != nil {
+		return nil, err
+	}
+	entity := *loaded.aggregate.(*EntityMetadata)
+	entity.EntityVersion = loaded.version
+	return &entity, nil
+}
+
+// flow is the shared save, update and find flow with the current settings of the repository
+func (repo *AggregateRepository) flow() *repositoryFlow {
+	return &repositoryFlow{
+		store:          repo,
+		client:         repo.Client,
+		entityTypeName: repo.meta.EntityTypeName,
+		encryptor:      repo.encryptor,
+		metadata:       repo.metadata,
+		tracer:         repo.tracer,
+		lg:             repo.lg}
+}
+
+// syncLogger makes the aggregate metadata log with the logger of the repository
+func (repo *AggregateRepository) syncLogger() {
+	meta := repo.meta
+	meta.lmu.Lock()
 	meta.ll = repo.ll
 	meta.lg = repo.lg
+	meta.lmu.Unlock()
+}
+
+// The aggregates of AggregateRepository, see aggregateStore, are *EntityMetadata
+
+func (repo *AggregateRepository) newAggregate() (interface{}, error) {
+	repo.syncLogger()
+	return repo.meta.newInstance()
+}
+
+func (repo *AggregateRepository) processCommand(aggregate interface{}, cmd Command) ([]Event, error) {
+	return aggregate.(*EntityMetadata).ProcessCommand(cmd)
+}
+
+func (repo *AggregateRepository) serializeEvents(events []Event) ([]EventTypeAndData, error) {
+	return prepareEventsForEventuate(events, repo.typeHints, repo.upcasters, repo.codecs)
+}
+
+func (repo *AggregateRepository) restore(entityId Int128, loadedEvents *LoadedEvents) (interface{}, error) {
+	var meta *AggregateMetadata = repo.meta
+	repo.syncLogger()
+
+	var (
+		entity    *EntityMetadata
+		entityErr error
+	)
+	snapshot := loadedEvents.Snapshot
+	if snapshot != nil && snapshot.SerializedSnapshot != nil {
+		entity, entityErr = meta.restoreSnapshot(snapshot.SerializedSnapshot)
+	} else {
+		entity, entityErr = meta.newInstance()
+	}
+	if entityErr != nil {
+		return nil, entityErr
+	}
+	if entity.EntityInstance, entityErr = repo.encryptor.decryptFields(meta.EntityTypeName, entityId, entity.EntityInstance); entityErr != nil {
+		return nil, entityErr
+	}
+
+	events, _, deserializationErr := materializeEventsFromEventuate(meta, loadedEvents.Events, repo.typeHints, repo.upcasters, repo.codecs)
+	if deserializationErr != nil {
+		return nil, deserializationErr
+	}
+	for idx, event := range events {
+		if events[idx], deserializationErr = repo.encryptor.decryptFields(meta.EntityTypeName, entityId, event); deserializationErr != nil {
+			return nil, deserializationErr
+		}
+	}
+
+	nextEntity, applyErr := entity.applyEvents(events)
+	if applyErr != nil {
+		return nil, applyErr
+	}
+
+	return &EntityMetadata{
+		EntityTypeName: meta.EntityTypeName,
+		EntityId:       entityId,
+		HasEntity:      true,
+		EntityInstance: nextEntity.EntityInstance,
+		metadata:       meta}, nil
+}
+
+func (repo *AggregateRepository) snapshotStrategy() SnapshotStrategy {
+	return repo.meta.snapshotStrategy
+}
+
+func (repo *AggregateRepository) applyEvents(aggregate interface{}, events []Event) (interface{}, interface{}, error) {
+	newEvents := make([]interface{}, len(events))
+	for idx, event := range events {
+		newEvents[idx] = event
+	}
+
+	nextEntity, applyErr := aggregate.(*EntityMetadata).applyEvents(newEvents)
+	if applyErr != nil {
+		return nil, nil, applyErr
+	}
+	return nextEntity, nextEntity.EntityInstance, nil
+}
+
+func (repo *AggregateRepository) serializeSnapshot(aggregate interface{}, instance interface{}) (*SerializedSnapshot, error) {
+	snapshotEntity := *aggregate.(*EntityMetadata)
+	snapshotEntity.EntityInstance = instance
+	return repo.meta.serializeSnapshot(&snapshotEntity)
+}
+
+func (repo *AggregateRepository) result(entityId Int128, entityVersion Int128) *EntityMetadata {
+	return &EntityMetadata{
+		EntityTypeName: repo.meta.EntityTypeName,
+		EntityId:       entityId,
+		EntityVersion:  entityVersion,
+		HasEntity:      false,
+		EntityInstance: nil,
+		metadata:       repo.meta}
+}
+
+// aggregateStore is what a repository plugs into the repositoryFlow:
+// how its aggregates are created, changed, serialized and restored
+type aggregateStore interface {
+	newAggregate() (interface{}, error)
+	processCommand(aggregate interface{}, cmd Command) ([]Event, error)
+	// serializeEvents maps the events, encrypted already, to their stored form
+	serializeEvents(events []Event) ([]EventTypeAndData, error)
+	// restore rebuilds the aggregate from the snapshot, if any, and the events loaded
+	restore(entityId Int128, loadedEvents *LoadedEvents) (interface{}, error)
+	snapshotStrategy() SnapshotStrategy
+	// applyEvents returns the aggregate with `events` applied and the instance its snapshots hold
+	applyEvents(aggregate interface{}, events []Event) (interface{}, interface{}, error)
+	// serializeSnapshot serializes the aggregate holding `instance`, encrypted already
+	serializeSnapshot(aggregate interface{}, instance interface{}) (*SerializedSnapshot, error)
+	result(entityId Int128, entityVersion Int128) *EntityMetadata
+}
+
+// repositoryFlow is the save, update and find flow shared by AggregateRepository and TypedRepository
+type repositoryFlow struct {
+	store          aggregateStore
+	client         Crud
+	entityTypeName string
+	encryptor      *PIIEncryptor
+	metadata       EventMetadataProvider
+	tracer         Tracer
+	lg             loglib.LeveledLogger
+}
+
+// loadedAggregate is an aggregate restored by find, at the version of its last event
+type loadedAggregate struct {
+	aggregate interface{}
+	version   Int128
+	// events applied after the snapshot the aggregate was restored from
+	eventsSinceSnapshot int
+}
+
+func (flow *repositoryFlow) saveCtx(ctx context.Context, cmd Command, saveOptions *SaveOptions) (*EntityMetadata, error) {
+	ctx, span := startSpan(flow.tracer, ctx, "eventuate.repository.Save", entitySpanAttributes(flow.entityTypeName, Int128Nil))
+	result, err := flow.save(ctx, cmd, saveOptions)
+	span.End(err)
+	return result, err
+}
 
-	entity, ctorErr := meta.newInstance()
+func (flow *repositoryFlow) save(ctx context.Context, cmd Command, saveOptions *SaveOptions) (*EntityMetadata, error) {
+	aggregate, ctorErr := flow.store.newAggregate()
 	if ctorErr != nil {
 		return nil, ctorErr
 	}
 
-	events, processErr := entity.ProcessCommand(cmd)
+	events, processErr := flow.store.processCommand(aggregate, cmd)
 	if processErr != nil {
 		return nil, processErr
 	}
 
 	if len(events) == 0 {
-		return &EntityMetadata{
-			EntityTypeName: meta.EntityTypeName,
-			EntityId:       Int128Nil,
-			EntityVersion:  Int128Nil,
-			HasEntity:      false,
-			EntityInstance: nil,
-			metadata:       meta}, nil
+		return flow.store.result(Int128Nil, Int128Nil), nil
+	}
+
+	var (
+		options          = &AggregateCrudSaveOptions{}
+		explicitMetadata map[string]string
+	)
+	if saveOptions != nil {
+		options.TriggeringEvent = saveOptions.TriggeringEvent
+		explicitMetadata = saveOptions.EventMetadata
+	}
+	eventMetadata, metadataErr := callEventMetadata(ctx, flow.metadata, flow.tracer, explicitMetadata)
+	if metadataErr != nil {
+		return nil, metadataErr
+	}
+	options.EventMetadata = eventMetadata
+	if flow.encryptor != nil {
+		options.EntityId = Int128Random()
 	}
 
-	mappedEvents, errMapping := prepareEventsForEventuate(events, repo.typeHints)
+	mappedEvents, errMapping := flow.serializeEvents(options.EntityId, events)
 	if errMapping != nil {
 		return nil, errMapping
 	}
 
-	options := &AggregateCrudSaveOptions{}
-
-	evEntity, saveErr := repo.Client.Save(meta.EntityTypeName, mappedEvents, options)
+	saved, saveErr := crudSave(ctx, flow.client, flow.entityTypeName, mappedEvents, options)
 	if saveErr != nil {
-		return nil, AppError("Repository persist exception (Save): %v", saveErr)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if errors.Is(saveErr, ErrDuplicateEvent) {
+			return flow.alreadyProcessed(Int128Nil), nil
+		}
+		return nil, AppError("Repository persist exception (Save): %w", saveErr)
 	}
-
-	return &EntityMetadata{
-		EntityTypeName: meta.EntityTypeName,
-		EntityId:       evEntity.EntityId,
-		EntityVersion:  evEntity.EntityVersion,
-		HasEntity:      false,
-		EntityInstance: nil,
-		metadata:       meta}, nil
+	return flow.store.result(saved.EntityId, saved.EntityVersion), nil
 }
 
-func (repo *AggregateRepository) Update(entityId Int128, cmd Command) (*EntityMetadata, error) {
+func (flow *repositoryFlow) updateCtx(ctx context.Context, entityId Int128, cmd Command, updateOptions *UpdateOptions) (*EntityMetadata, error) {
+	var (
+		policy           *RetryPolicy
+		triggeringEvent  *EventContext
+		explicitMetadata map[string]string
+	)
+	if updateOptions != nil {
+		policy = updateOptions.Retry
+		triggeringEvent = updateOptions.TriggeringEvent
+		explicitMetadata = updateOptions.EventMetadata
+	}
 
-	var meta *AggregateMetadata = repo.meta
+	ctx, span := startSpan(flow.tracer, ctx, "eventuate.repository.Update", entitySpanAttributes(flow.entityTypeName, entityId))
+	eventMetadata, err := callEventMetadata(ctx, flow.metadata, flow.tracer, explicitMetadata)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
 
-	meta.ll = repo.ll
-	meta.lg = repo.lg
+	var result *EntityMetadata
+	err = updateWithRetries(ctx, policy, flow.lg, entityId, func() (err error) {
+		result, err = flow.update(ctx, entityId, cmd, &AggregateCrudUpdateOptions{
+			TriggeringEvent: triggeringEvent,
+			EventMetadata:   eventMetadata})
+		return err
+	})
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	entity, findErr := repo.Find(entityId)
-	if findErr != nil {
-		return nil, findErr
+// update performs a single Find-process-Update round, the failures of the Crud calls are wrapped
+func (flow *repositoryFlow) update(ctx context.Context, entityId Int128, cmd Command, options *AggregateCrudUpdateOptions) (*EntityMetadata, error) {
+	loaded, err := flow.find(ctx, entityId, &AggregateCrudFindOptions{
+		TriggeringEvent: options.TriggeringEvent})
+	if err != nil {
+		if errors.Is(err, ErrDuplicateEvent) {
+			return flow.alreadyProcessed(entityId), nil
+		}
+		return nil, err
 	}
 
-	repo.lg.Printf("Entity, after Find(), before Update(): %#v\n", entity)
+	flow.lg.Debug("Entity found for the update", loglib.KeyEntityType, flow.entityTypeName, loglib.KeyEntityId, entityId)
 
-	events, processErr := entity.ProcessCommand(cmd)
+	events, processErr := flow.store.processCommand(loaded.aggregate, cmd)
 	if processErr != nil {
 		return nil, processErr
 	}
 
 	if len(events) == 0 {
-		return &EntityMetadata{
-			EntityTypeName: meta.EntityTypeName,
-			EntityId:       entity.EntityId,
-			EntityVersion:  entity.EntityVersion,
-			HasEntity:      false,
-			EntityInstance: nil,
-			metadata:       meta}, nil
+		return flow.store.result(entityId, loaded.version), nil
 	}
 
-	mappedEvents, errMapping := prepareEventsForEventuate(events, repo.typeHints)
+	mappedEvents, errMapping := flow.serializeEvents(entityId, events)
 	if errMapping != nil {
 		return nil, errMapping
 	}
 
-	options := &AggregateCrudUpdateOptions{}
-
-	evEntity, updErr := repo.Client.Update(EntityIdAndType{
-		EntityType: entity.EntityTypeName,
-		EntityId:   entityId}, entity.EntityVersion, mappedEvents, options)
+	snapshot, snapshotErr := flow.maybeSnapshot(entityId, loaded, events)
+	if snapshotErr != nil {
+		return nil, snapshotErr
+	}
+	options.SerializedSnapshot = snapshot
 
+	updated, updErr := crudUpdate(ctx, flow.client, EntityIdAndType{
+		EntityType: flow.entityTypeName,
+		EntityId:   entityId}, loaded.version, mappedEvents, options)
 	if updErr != nil {
-		return nil, AppError("Repository persist exception (Update): %v", updErr)
+		if errors.Is(updErr, ErrDuplicateEvent) {
+			return flow.alreadyProcessed(entityId), nil
+		}
+		return nil, AppError("Repository persist exception (Update): %w", updErr)
 	}
+	return flow.store.result(updated.EntityId, updated.EntityVersion), nil
+}
 
-	return &EntityMetadata{
-		EntityTypeName: meta.EntityTypeName,
-		EntityId:       evEntity.EntityId,
-		EntityVersion:  evEntity.EntityVersion,
-		HasEntity:      false,
-		EntityInstance: nil,
-		metadata:       meta}, nil
+func (flow *repositoryFlow) findCtx(ctx context.Context, entityId Int128) (*loadedAggregate, error) {
+	ctx, span := startSpan(flow.tracer, ctx, "eventuate.repository.Find", entitySpanAttributes(flow.entityTypeName, entityId))
+	loaded, err := flow.find(ctx, entityId, &AggregateCrudFindOptions{})
+	span.End(err)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return loaded, nil
 }
 
-func (repo *AggregateRepository) Find(entityId Int128) (*EntityMetadata, error) {
+// find loads the aggregate, a failure of the Crud.Find call is wrapped
+func (flow *repositoryFlow) find(ctx context.Context, entityId Int128, options *AggregateCrudFindOptions) (*loadedAggregate, error) {
+	loadedEvents, findErr := crudFind(ctx, flow.client, flow.entityTypeName, entityId, options)
+	if findErr != nil {
+		return nil, AppError("Repository search exception (Find): %w", findErr)
+	}
 
-	var meta *AggregateMetadata = repo.meta
+	aggregate, restoreErr := flow.store.restore(entityId, loadedEvents)
+	if restoreErr != nil {
+		return nil, restoreErr
+	}
 
-	meta.lmu.Lock()
-	meta.ll = repo.ll
-	meta.lg = repo.lg
-	meta.lmu.Unlock()
+	loaded := &loadedAggregate{
+		aggregate:           aggregate,
+		eventsSinceSnapshot: len(loadedEvents.Events)}
+	if loadedEvents.Snapshot != nil {
+		loaded.version = loadedEvents.Snapshot.EntityVersion
+	}
+	if eventsCount := len(loadedEvents.Events); eventsCount > 0 {
+		loaded.version = loadedEvents.Events[eventsCount-1].EventId
+	}
+	return loaded, nil
+}
 
-	entity, ctorErr := meta.newInstance()
-	if ctorErr != nil {
-		return nil, ctorErr
+// serializeEvents encrypts the events for the entity and maps them to their stored form
+func (flow *repositoryFlow) serializeEvents(entityId Int128, events []Event) ([]EventTypeAndData, error) {
+	encryptedEvents, encryptErr := encryptEvents(flow.encryptor, flow.entityTypeName, entityId, events)
+	if encryptErr != nil {
+		return nil, encryptErr
 	}
+	return flow.store.serializeEvents(encryptedEvents)
+}
 
-	options := &AggregateCrudFindOptions{}
-	loadedEvents, findErr := repo.Client.Find(
-		meta.EntityTypeName,
-		entityId,
-		options)
+// maybeSnapshot consults the snapshot strategy and serializes the aggregate with the new events applied
+func (flow *repositoryFlow) maybeSnapshot(entityId Int128, loaded *loadedAggregate, events []Event) (*SerializedSnapshot, error) {
+	strategy := flow.store.snapshotStrategy()
+	if strategy == nil {
+		return nil, nil
+	}
 
-	if findErr != nil {
-		return nil, AppError("Repository search exception (Find): %v", findErr)
+	next, instance, applyErr := flow.store.applyEvents(loaded.aggregate, events)
+	if applyErr != nil {
+		return nil, applyErr
 	}
 
-	eventuateEvents := loadedEvents.Events
-	//snapshot := loadedEvents.Snapshot // ???
+	if !strategy.ShouldSnapshot(instance, loaded.eventsSinceSnapshot+len(events)) {
+		return nil, nil
+	}
 
-	events, entityVersion, deserializationErr := materializeEventsFromEventuate(meta, eventuateEvents, repo.typeHints)
-	if deserializationErr != nil {
-		return nil, deserializationErr
+	encryptedInstance, encryptErr := flow.encryptor.encryptFields(flow.entityTypeName, entityId, instance)
+	if encryptErr != nil {
+		return nil, encryptErr
 	}
+	return flow.store.serializeSnapshot(next, encryptedInstance)
+}
 
-	nextEntity, applyErr := entity.applyEvents(events)
-	if applyErr != nil {
-		return nil, applyErr
+func (flow *repositoryFlow) alreadyProcessed(entityId Int128) *EntityMetadata {
+	result := flow.store.result(entityId, Int128Nil)
+	result.AlreadyProcessed = true
+	return result
+}
+
+// crudFind, crudSave and crudUpdate use the context-aware Crud methods when the client has them
+
+func crudFind(ctx context.Context, client Crud, aggregateType string, entityId Int128,
+	findOptions *AggregateCrudFindOptions) (*LoadedEvents, error) {
+
+	if clientCtx, isCrudCtx := client.(CrudCtx); isCrudCtx {
+		return clientCtx.FindCtx(ctx, aggregateType, entityId, findOptions)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	return client.Find(aggregateType, entityId, findOptions)
+}
 
-	return &EntityMetadata{
-		EntityTypeName: meta.EntityTypeName,
-		EntityId:       entityId,
-		EntityVersion:  entityVersion,
-		HasEntity:      true,
-		EntityInstance: nextEntity.EntityInstance,
-		metadata:       meta}, nil
+func crudSave(ctx context.Context, client Crud, aggregateType string, events []EventTypeAndData,
+	saveOptions *AggregateCrudSaveOptions) (*EntityIdVersionAndEventIds, error) {
+
+	if clientCtx, isCrudCtx := client.(CrudCtx); isCrudCtx {
+		return clientCtx.SaveCtx(ctx, aggregateType, events, saveOptions)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.Save(aggregateType, events, saveOptions)
 }
 
-func prepareEventsForEventuate(events []Event, eventTypesMap TypeHintMapper) ([]EventTypeAndData, error) {
+func crudUpdate(ctx context.Context, client Crud, entityIdAndType EntityIdAndType, entityVersion Int128,
+	events []EventTypeAndData, updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error) {
+
+	if clientCtx, isCrudCtx := client.(CrudCtx); isCrudCtx {
+		return clientCtx.UpdateCtx(ctx, entityIdAndType, entityVersion, events, updateOptions)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.Update(entityIdAndType, entityVersion, events, updateOptions)
+}
+
+func prepareEventsForEventuate(events []Event, eventTypesMap TypeHintMapper, upcasters *UpcasterRegistry, codecs *EventCodecs) ([]EventTypeAndData, error) {
 	mappedEvents := make([]EventTypeAndData, len(events))
 	for idx, event := range events {
 
-		val := getUnderlyingValue(event)
-		serializedEvent, err := json.Marshal(val)
-		if err != nil {
-			return nil,
-				AppError("Cannot serialize Event (%v), json Error: %v", event, err)
-		}
-
 		evtTypeName := getUnderlyingType(reflect.TypeOf(event)).Name()
 		hasEventName, eventName := eventTypesMap.GetTypeByTypeName(evtTypeName)
 
@@ -211,19 +557,33 @@ func prepareEventsForEventuate(events []Event, eventTypesMap TypeHintMapper) ([]
 			panic(fmt.Sprintf("Cannot serialize EventType (%v), register its type first", event))
 		}
 
+		serializedEvent, metadata, err := codecs.encode(eventName, getUnderlyingValue(event), "")
+		if err != nil {
+			return nil, err
+		}
+
+		metadata, metadataErr := upcasters.stamp(eventName, metadata)
+		if metadataErr != nil {
+			return nil, metadataErr
+		}
+
 		mappedEvents[idx] = EventTypeAndData{
 			EventType: eventName,
-			EventData: string(serializedEvent)}
+			EventData: serializedEvent,
+			Metadata:  metadata}
 	}
 	return mappedEvents, nil
 }
 
-func materializeEventsFromEventuate(meta *AggregateMetadata, events []EventIdTypeAndData, typeHints TypeHintMapper) ([]interface{}, Int128, error) {
+func materializeEventsFromEventuate(meta *AggregateMetadata, events []EventIdTypeAndData, typeHints TypeHintMapper, upcasters *UpcasterRegistry, codecs *EventCodecs) ([]interface{}, Int128, error) {
 	eventNamePattern := regexp.MustCompile(`^(\w+)Event$`)
 	var entityVersion Int128
 
-	for i, ev := range events {
-		meta.lg.Printf("%d: %v", i, ev)
+	if meta.lg.Enabled(loglib.Debug) {
+		for _, ev := range events {
+			meta.lg.Debug("Loaded event", loglib.KeyEventType, ev.EventType, loglib.KeyEventId, ev.EventId,
+				loglib.KeyEventData, ev.EventData)
+		}
 	}
 
 	mappedEvents := make([]interface{}, len(events))
@@ -232,19 +592,26 @@ func materializeEventsFromEventuate(meta *AggregateMetadata, events []EventIdTyp
 
 		entityVersion = event.EventId
 
+		var upcastErr error
+		event.EventType, event.EventData, upcastErr = upcasters.Upcast(event.EventType, event.EventData, event.Metadata)
+		if upcastErr != nil {
+			return nil, Int128Nil, upcastErr
+		}
+
 		isRegistered := typeHints.HasEventType(event.EventType)
 		registeredType := typeHints.GetEventType(event.EventType)
 		if isRegistered {
-			meta.lg.Printf("event.EventType = %v, registeredType = %v, Is Interface? %v; Is Ptr? %v; Is Struct? %v\n",
-				event.EventType, registeredType, registeredType.Kind() == reflect.Interface, registeredType.Kind() == reflect.Ptr, registeredType.Kind() == reflect.Struct)
+			meta.lg.Debug("Deserializing event into its registered type", loglib.KeyEventType, event.EventType,
+				"registered_type", registeredType)
 			newVal := reflect.New(registeredType).Interface()
-			err := json.Unmarshal([]byte(event.EventData), newVal)
+			err := codecs.decode(event.EventData, event.Metadata, newVal)
 			//_, err := reJson(event.EventData, newVal)
 			if err != nil {
-				return nil, Int128Nil, AppError("Cannot deserialize Event of type `%s` into a registered type (%v) for data: %v",
+				return nil, Int128Nil, AppError("Cannot deserialize Event of type `%s` into a registered type (%v) for data: %v, Error: %v",
 					event.EventType,
 					registeredType,
-					event)
+					event,
+					err)
 			}
 			//mappedEvents[idx] = Event(result)
 			mappedEvents[idx] = getUnderlyingValue(newVal)
@@ -263,12 +630,13 @@ func materializeEventsFromEventuate(meta *AggregateMetadata, events []EventIdTyp
 
 		if typeExists {
 			newVal := reflect.New(eventType).Interface()
-			_, err := reJson(event.EventData, newVal)
+			err := codecs.decode(event.EventData, event.Metadata, newVal)
 			if err != nil {
-				return nil, Int128Nil, AppError("Cannot deserialize Event of type `%s` into a reflected type (%v) for data: %v",
+				return nil, Int128Nil, AppError("Cannot deserialize Event of type `%s` into a reflected type (%v) for data: %v, Error: %v",
 					event.EventType,
 					eventType,
-					event)
+					event,
+					err)
 			}
 			mappedEvents[idx] = getUnderlyingValue(newVal)
 		} else {
@@ -287,8 +655,3 @@ func materializeEventsFromEventuate(meta *AggregateMetadata, events []EventIdTyp
 	}
 	return mappedEvents, entityVersion, nil
 }
-
-func reJson(evtData string, propResult interface{}) (interface{}, error) {
-	unmarshalErr := json.Unmarshal([]byte(evtData), propResult)
-	return propResult, unmarshalErr
-}
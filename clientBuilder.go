@@ -1,6 +1,12 @@
 package eventuate
 
-import loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+)
 
 type ClientBuilderInstance struct {
 	overrideCredentials bool
@@ -8,13 +14,27 @@ type ClientBuilderInstance struct {
 	overrideSpace       bool
 	overrideStompUrl    bool
 	ll                  loglib.LogLevelEnum
-	lg                  loglib.Logger
+	lg                  loglib.LeveledLogger
 	apiKeyId            string
 	apiKeySecret        string
 	url                 string
 	space               string
 	stompUrl            string
 	typeHints           typeHintsMap
+	reconnectPolicy     *RetryPolicy
+	stateHandler        ConnectionStateHandler
+	upcasters           *UpcasterRegistry
+	codecs              *EventCodecs
+	encryptor           *PIIEncryptor
+	metadata            EventMetadataProvider
+	tracer              Tracer
+	metrics             MetricsRecorder
+	http                HTTPOptions
+	stompTLSConfig      *tls.Config
+	stompConnect        time.Duration
+	stompHandshake      time.Duration
+	stompTransport      StompTransport
+	stompProxy          string
 }
 
 func ClientBuilder() *ClientBuilderInstance {
@@ -30,7 +50,21 @@ func ClientBuilder() *ClientBuilderInstance {
 		"https://api.eventuate.io",
 		"default",
 		"https://api.eventuate.io:61614",
-		nil}
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		HTTPOptions{},
+		nil,
+		0,
+		0,
+		StompTransportAuto,
+		""}
 }
 
 func (bldr *ClientBuilderInstance) WithUrl(serverUrl string) *ClientBuilderInstance {
@@ -76,12 +110,164 @@ func (bldr *ClientBuilderInstance) WithTypeHintPair(name string, typeInstance in
 	return bldr
 }
 
+// WithStompTLSConfig sets the roots, client certificates and server name of the STOMP connections over TLS.
+// The certificate of the server is verified unless `config` turns it off.
+func (bldr *ClientBuilderInstance) WithStompTLSConfig(config *tls.Config) *ClientBuilderInstance {
+	bldr.stompTLSConfig = config
+	return bldr
+}
+
+// WithStompTimeouts limits the time to open the STOMP connections and the time of their TLS and STOMP handshakes
+func (bldr *ClientBuilderInstance) WithStompTimeouts(connect, handshake time.Duration) *ClientBuilderInstance {
+	bldr.stompConnect = connect
+	bldr.stompHandshake = handshake
+	return bldr
+}
+
+// WithStompTransport makes the STOMP client use TCP or WebSocket regardless of the scheme of the STOMP URL
+func (bldr *ClientBuilderInstance) WithStompTransport(transport StompTransport) *ClientBuilderInstance {
+	bldr.stompTransport = transport
+	return bldr
+}
+
+// WithStompProxy makes the STOMP client connect over WebSocket through the HTTP proxy `proxyUrl`
+func (bldr *ClientBuilderInstance) WithStompProxy(proxyUrl string) *ClientBuilderInstance {
+	bldr.stompProxy = proxyUrl
+	return bldr
+}
+
+// WithReconnectPolicy configures how a lost STOMP connection is re-established
+func (bldr *ClientBuilderInstance) WithReconnectPolicy(policy *RetryPolicy) *ClientBuilderInstance {
+	bldr.reconnectPolicy = policy
+	return bldr
+}
+
+func (bldr *ClientBuilderInstance) WithConnectionStateHandler(handler ConnectionStateHandler) *ClientBuilderInstance {
+	bldr.stateHandler = handler
+	return bldr
+}
+
+// WithUpcasters makes the clients (and repositories and subscriptions made with them) upcast the events they read
+func (bldr *ClientBuilderInstance) WithUpcasters(registry *UpcasterRegistry) *ClientBuilderInstance {
+	bldr.upcasters = registry
+	return bldr
+}
+
+// WithEventCodec sets the codec of the event types without a codec of their own
+func (bldr *ClientBuilderInstance) WithEventCodec(codec EventCodec) *ClientBuilderInstance {
+	bldr.eventCodecs().SetDefault(codec)
+	return bldr
+}
+
+// WithEventTypeCodec makes events of `eventType` written with `codec`
+func (bldr *ClientBuilderInstance) WithEventTypeCodec(eventType string, codec EventCodec) *ClientBuilderInstance {
+	bldr.eventCodecs().RegisterCodec(eventType, codec)
+	return bldr
+}
+
+// WithEventCodecs replaces the codecs configured so far
+func (bldr *ClientBuilderInstance) WithEventCodecs(codecs *EventCodecs) *ClientBuilderInstance {
+	bldr.codecs = codecs
+	return bldr
+}
+
+// WithPIIEncryptor makes the fields tagged `eventuate:"pii"` encrypted with the data keys of their entities
+func (bldr *ClientBuilderInstance) WithPIIEncryptor(encryptor *PIIEncryptor) *ClientBuilderInstance {
+	bldr.encryptor = encryptor
+	return bldr
+}
+
+// WithEventMetadataProvider makes the repositories made with the REST client attach the metadata of `provider` to their events
+func (bldr *ClientBuilderInstance) WithEventMetadataProvider(provider EventMetadataProvider) *ClientBuilderInstance {
+	bldr.metadata = provider
+	return bldr
+}
+
+// WithTracer makes the clients, and the repositories and subscriptions made with them, traced by `tracer`
+func (bldr *ClientBuilderInstance) WithTracer(tracer Tracer) *ClientBuilderInstance {
+	bldr.tracer = tracer
+	return bldr
+}
+
+// WithMetricsRecorder makes the clients, and the subscriptions made with them, report their metrics to `recorder`
+func (bldr *ClientBuilderInstance) WithMetricsRecorder(recorder MetricsRecorder) *ClientBuilderInstance {
+	bldr.metrics = recorder
+	return bldr
+}
+
+// WithTimeout limits the duration of the REST requests
+func (bldr *ClientBuilderInstance) WithTimeout(timeout time.Duration) *ClientBuilderInstance {
+	bldr.http.Timeout = timeout
+	return bldr
+}
+
+// WithTransport makes the REST client send its requests with `transport`, the proxy and TLS options are then ignored
+func (bldr *ClientBuilderInstance) WithTransport(transport http.RoundTripper) *ClientBuilderInstance {
+	bldr.http.Transport = transport
+	return bldr
+}
+
+func (bldr *ClientBuilderInstance) WithProxy(proxyUrl string) *ClientBuilderInstance {
+	bldr.http.ProxyURL = proxyUrl
+	return bldr
+}
+
+func (bldr *ClientBuilderInstance) WithTLSConfig(config *tls.Config) *ClientBuilderInstance {
+	bldr.http.TLSConfig = config
+	return bldr
+}
+
+// WithCABundle makes the REST client trust the certificate authorities of the PEM file `path` instead of the system ones
+func (bldr *ClientBuilderInstance) WithCABundle(path string) *ClientBuilderInstance {
+	bldr.http.CABundleFile = path
+	return bldr
+}
+
+func (bldr *ClientBuilderInstance) WithClientCertificates(certificates ...tls.Certificate) *ClientBuilderInstance {
+	bldr.http.ClientCertificates = append(bldr.http.ClientCertificates, certificates...)
+	return bldr
+}
+
+// WithRetries retries `count` times the REST requests failed because the server was unavailable,
+// waiting from `waitTime` up to `maxWaitTime` between the attempts
+func (bldr *ClientBuilderInstance) WithRetries(count int, waitTime, maxWaitTime time.Duration) *ClientBuilderInstance {
+	bldr.http.RetryCount = count
+	bldr.http.RetryWaitTime = waitTime
+	bldr.http.RetryMaxWaitTime = maxWaitTime
+	return bldr
+}
+
+func (bldr *ClientBuilderInstance) WithUserAgent(userAgent string) *ClientBuilderInstance {
+	bldr.http.UserAgent = userAgent
+	return bldr
+}
+
+// WithHTTPOptions replaces the HTTP options configured so far
+func (bldr *ClientBuilderInstance) WithHTTPOptions(options HTTPOptions) *ClientBuilderInstance {
+	bldr.http = options
+	return bldr
+}
+
+func (bldr *ClientBuilderInstance) eventCodecs() *EventCodecs {
+	if bldr.codecs == nil {
+		bldr.codecs = NewEventCodecs()
+	}
+	return bldr.codecs
+}
+
 func (bldr *ClientBuilderInstance) SetLogLevel(level loglib.LogLevelEnum) *ClientBuilderInstance {
 	bldr.ll = level
 	bldr.lg = loglib.NewLogger(level)
 	return bldr
 }
 
+// WithLogger makes the clients, and the repositories and subscriptions made with them, log to `lg`,
+// e.g. a logger.NewLeveledLogger with a slog backend
+func (bldr *ClientBuilderInstance) WithLogger(lg loglib.LeveledLogger) *ClientBuilderInstance {
+	bldr.lg = lg
+	return bldr
+}
+
 func (bldr *ClientBuilderInstance) BuildREST() (*RESTClient, error) {
 	var (
 		credentials *Credentials
@@ -96,14 +282,19 @@ func (bldr *ClientBuilderInstance) BuildREST() (*RESTClient, error) {
 		return nil, err
 	}
 
-	result, clientErr := NewRESTClient(credentials, bldr.url)
+	result, clientErr := NewRESTClientWithOptions(credentials, bldr.url, bldr.http)
 	if clientErr == nil {
 		result.ll = bldr.ll
 		result.lg = bldr.lg
+		result.upcasters = bldr.upcasters
+		result.codecs = bldr.codecs
+		result.encryptor = bldr.encryptor
+		result.metadata = bldr.metadata
+		result.tracer = bldr.tracer
+		result.metrics = metricsOrNoop(bldr.metrics)
+		result.typeHints = bldr.typeHints.MakeCopy()
 	}
 
-	result.typeHints = bldr.typeHints.MakeCopy()
-
 	return result, clientErr
 }
 
@@ -126,8 +317,21 @@ func (bldr *ClientBuilderInstance) BuildSTOMP() (*StompClient, error) {
 	if clientErr == nil {
 		result.ll = bldr.ll
 		result.lg = bldr.lg
+		result.reconnectPolicy = bldr.reconnectPolicy
+		result.stateHandler = bldr.stateHandler
+		result.upcasters = bldr.upcasters
+		result.codecs = bldr.codecs
+		result.encryptor = bldr.encryptor
+		result.tracer = bldr.tracer
+		result.metrics = metricsOrNoop(bldr.metrics)
+		result.tlsConfig = bldr.stompTLSConfig
+		result.SetTimeouts(bldr.stompConnect, bldr.stompHandshake)
+		result.transport = bldr.stompTransport
+		result.typeHints = bldr.typeHints.MakeCopy()
+		if proxyErr := result.SetProxy(bldr.stompProxy); proxyErr != nil {
+			return nil, proxyErr
+		}
 	}
-	result.typeHints = bldr.typeHints.MakeCopy()
 
 	return result, clientErr
 }
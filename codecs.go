@@ -0,0 +1,192 @@
+package eventuate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+)
+
+// EventMetadataCodec is the key of the event metadata holding the name of the codec the event data is encoded with.
+// Events without it are JSON.
+const EventMetadataCodec = "codec"
+
+const jsonCodecName = "json"
+
+// EventCodec encodes events into the (string) event data stored by the server and decodes them back
+type EventCodec interface {
+	Name() string
+	Encode(event interface{}) (string, error)
+	Decode(eventData string, destination interface{}) error
+}
+
+// JSONCodec is the default codec, it may reject the fields unknown to the destination type
+type JSONCodec struct {
+	DisallowUnknownFields bool
+}
+
+func (codec JSONCodec) Name() string {
+	return jsonCodecName
+}
+
+func (codec JSONCodec) Encode(event interface{}) (string, error) {
+	result, err := json.Marshal(event)
+	return string(result), err
+}
+
+func (codec JSONCodec) Decode(eventData string, destination interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(eventData)))
+	if codec.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(destination)
+}
+
+type binaryCodec struct {
+	name      string
+	marshal   func(event interface{}) ([]byte, error)
+	unmarshal func(data []byte, destination interface{}) error
+}
+
+// NewBinaryCodec makes a codec named `name` out of a binary serialization (e.g. protobuf or msgpack),
+// the serialized events are stored base64-encoded
+func NewBinaryCodec(name string,
+	marshal func(event interface{}) ([]byte, error),
+	unmarshal func(data []byte, destination interface{}) error) EventCodec {
+	return &binaryCodec{
+		name:      name,
+		marshal:   marshal,
+		unmarshal: unmarshal}
+}
+
+func (codec *binaryCodec) Name() string {
+	return codec.name
+}
+
+func (codec *binaryCodec) Encode(event interface{}) (string, error) {
+	data, err := codec.marshal(event)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (codec *binaryCodec) Decode(eventData string, destination interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(eventData)
+	if err != nil {
+		return err
+	}
+	return codec.unmarshal(data, destination)
+}
+
+// EventCodecs selects the codec events are written with by their type, and the codec
+// they are read with by the name recorded in their metadata
+type EventCodecs struct {
+	sync.RWMutex
+	defaultCodec EventCodec
+	byEventType  map[string]EventCodec
+	byName       map[string]EventCodec
+}
+
+func NewEventCodecs() *EventCodecs {
+	return &EventCodecs{
+		defaultCodec: JSONCodec{},
+		byEventType:  make(map[string]EventCodec),
+		byName:       map[string]EventCodec{jsonCodecName: JSONCodec{}}}
+}
+
+// SetDefault sets the codec of the event types without a codec of their own
+func (codecs *EventCodecs) SetDefault(codec EventCodec) *EventCodecs {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	codecs.defaultCodec = codec
+	codecs.byName[codec.Name()] = codec
+	return codecs
+}
+
+// RegisterCodec makes events of `eventType` written with `codec`
+func (codecs *EventCodecs) RegisterCodec(eventType string, codec EventCodec) *EventCodecs {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	codecs.byEventType[eventType] = codec
+	codecs.byName[codec.Name()] = codec
+	return codecs
+}
+
+// RegisterDecoder makes events written with `codec` readable without writing any with it
+func (codecs *EventCodecs) RegisterDecoder(codec EventCodec) *EventCodecs {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	codecs.byName[codec.Name()] = codec
+	return codecs
+}
+
+// encode serializes the event of `eventType`, the name of a codec other than JSON is recorded in the metadata
+func (codecs *EventCodecs) encode(eventType string, event interface{}, metadata string) (string, string, error) {
+	codec := codecs.codecFor(eventType)
+	eventData, err := codec.Encode(event)
+	if err != nil {
+		return "", "", AppError("Cannot serialize Event (%v), %s Error: %v", event, codec.Name(), err)
+	}
+	if codec.Name() == jsonCodecName {
+		return eventData, metadata, nil
+	}
+	metadata, err = setEventMetadata(metadata, EventMetadataCodec, codec.Name())
+	return eventData, metadata, err
+}
+
+// decode deserializes the event data with the codec named in the metadata
+func (codecs *EventCodecs) decode(eventData, metadata string, destination interface{}) error {
+	name, hasName, err := eventMetadataValue(metadata, EventMetadataCodec)
+	if err != nil {
+		return err
+	}
+	if !hasName {
+		name = jsonCodecName
+	}
+
+	codec := codecs.codecNamed(name)
+	if codec == nil {
+		return AppError("No codec registered with the name `%s`", name)
+	}
+	return codec.Decode(eventData, destination)
+}
+
+func (codecs *EventCodecs) codecFor(eventType string) EventCodec {
+	if codecs == nil {
+		return JSONCodec{}
+	}
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	if codec, hasCodec := codecs.byEventType[eventType]; hasCodec {
+		return codec
+	}
+	return codecs.defaultCodec
+}
+
+func (codecs *EventCodecs) codecNamed(name string) EventCodec {
+	if codecs == nil {
+		if name == jsonCodecName {
+			return JSONCodec{}
+		}
+		return nil
+	}
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	return codecs.byName[name]
+}
+
+// SetEventCodecs sets the codecs the repositories made with this client serialize events with
+func (rest *RESTClient) SetEventCodecs(codecs *EventCodecs) {
+	rest.codecs = codecs
+}
+
+// SetEventCodecs sets the codecs the subscriptions made with this client deserialize events with
+func (stomp *StompClient) SetEventCodecs(codecs *EventCodecs) {
+	stomp.codecs = codecs
+}
@@ -0,0 +1,83 @@
+package eventuate_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGobCodec() eventuate.EventCodec {
+	return eventuate.NewBinaryCodec("gob",
+		func(event interface{}) ([]byte, error) {
+			var buffer bytes.Buffer
+			err := gob.NewEncoder(&buffer).Encode(event)
+			return buffer.Bytes(), err
+		},
+		func(data []byte, destination interface{}) error {
+			return gob.NewDecoder(bytes.NewReader(data)).Decode(destination)
+		})
+}
+
+func TestJSONCodec_DisallowUnknownFields(t *testing.T) {
+	var event CounterIncrementedEvent
+	assertNoError(t, eventuate.JSONCodec{}.Decode(`{"Amount":1,"Unknown":2}`, &event))
+	assert.Equal(t, 1, event.Amount)
+
+	assert.Error(t, eventuate.JSONCodec{DisallowUnknownFields: true}.Decode(`{"Amount":1,"Unknown":2}`, &event))
+}
+
+func TestAggregateRepository_EventCodecs(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+
+	crud := eventuate.NewInMemoryCrud()
+	codecs := eventuate.NewEventCodecs().RegisterCodec(COUNTER_INCREMENTED, newGobCodec())
+	repo := eventuate.NewAggregateRepository(crud, meta).SetEventCodecs(codecs)
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+
+	saved, err := repo.Save(&IncrementCommand{Amount: 2})
+	assertNoError(t, err)
+
+	_, err = crud.Update(eventuate.EntityIdAndType{EntityType: COUNTER_ENTITY, EntityId: saved.EntityId},
+		saved.EntityVersion,
+		[]eventuate.EventTypeAndData{{EventType: COUNTER_INCREMENTED, EventData: `{"Amount":3}`}},
+		nil)
+	assertNoError(t, err)
+
+	loaded, err := crud.Find(COUNTER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	if assert.Len(t, loaded.Events, 2) {
+		assert.JSONEq(t, `{"codec":"gob"}`, loaded.Events[0].Metadata)
+		assert.NotContains(t, loaded.Events[0].EventData, "Amount")
+	}
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, 5, found.EntityInstance.(*CounterAggregate).Total)
+
+	jsonOnly := eventuate.NewAggregateRepository(crud, meta)
+	assertNoError(t, jsonOnly.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+	_, err = jsonOnly.Find(saved.EntityId)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No codec registered with the name `gob`")
+	}
+}
+
+func TestTypedRepository_EventCodecs(t *testing.T) {
+	crud := eventuate.NewInMemoryCrud()
+	repo := newAccountRepository(t, crud).
+		SetEventCodecs(eventuate.NewEventCodecs().SetDefault(eventuate.JSONCodec{DisallowUnknownFields: true}))
+
+	saved, err := crud.Save("net.chrisrichardson.eventstore.example.Account", []eventuate.EventTypeAndData{{
+		EventType: "net.chrisrichardson.eventstore.example.AccountOpened",
+		EventData: `{"Deposit":100,"Currency":"EUR"}`}}, nil)
+	assertNoError(t, err)
+
+	_, err = repo.Find(saved.EntityId)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Currency")
+	}
+}
@@ -0,0 +1,89 @@
+package eventuate
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// InMemoryDeadLetterSink keeps the dead letters in memory, for tests and local development
+type InMemoryDeadLetterSink struct {
+	sync.Mutex
+	letters []DeadLetter
+}
+
+func NewInMemoryDeadLetterSink() *InMemoryDeadLetterSink {
+	return &InMemoryDeadLetterSink{}
+}
+
+func (sink *InMemoryDeadLetterSink) Put(letter DeadLetter) error {
+	sink.Lock()
+	defer sink.Unlock()
+	sink.letters = append(sink.letters, letter)
+	return nil
+}
+
+// Letters lists the dead letters in the order they were put
+func (sink *InMemoryDeadLetterSink) Letters() []DeadLetter {
+	sink.Lock()
+	defer sink.Unlock()
+	return append([]DeadLetter(nil), sink.letters...)
+}
+
+// FileDeadLetterSink appends the dead letters to a file, a JSON document per line
+type FileDeadLetterSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+// NewFileDeadLetterSink opens, or creates, the file `path` for appending
+func NewFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, AppError("Cannot open the dead letter file: %w", err)
+	}
+	return &FileDeadLetterSink{
+		file: file}, nil
+}
+
+func (sink *FileDeadLetterSink) Put(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	sink.Lock()
+	defer sink.Unlock()
+	if _, err = sink.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return sink.file.Sync()
+}
+
+func (sink *FileDeadLetterSink) Close() error {
+	sink.Lock()
+	defer sink.Unlock()
+	return sink.file.Close()
+}
+
+// ReadDeadLetters reads the file of a FileDeadLetterSink, e.g. to replay its events
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, scanner.Err()
+}
@@ -0,0 +1,44 @@
+package eventuate_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileDeadLetterSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+
+	sink, err := eventuate.NewFileDeadLetterSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	letter := eventuate.DeadLetter{
+		SubscriberId: "subscriber",
+		Event: eventuate.StompEvent{
+			Id:         eventuate.Int128FromString(EVENT_ID_1),
+			EventType:  EVENT_CREATED,
+			EventData:  EVENT_DATA_1,
+			EntityId:   eventuate.Int128FromString(ENTITY_ID),
+			EntityType: ENTITY_TYPE},
+		Attempts: 3,
+		Error:    "handler failed",
+		FailedAt: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)}
+	assert.Nil(t, sink.Put(letter))
+	assert.Nil(t, sink.Close())
+
+	// appended to on reopening
+	sink, err = eventuate.NewFileDeadLetterSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, sink.Put(letter))
+	assert.Nil(t, sink.Close())
+
+	letters, err := eventuate.ReadDeadLetters(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []eventuate.DeadLetter{letter, letter}, letters)
+}
@@ -1,26 +1,117 @@
 package eventuate
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
 	"github.com/eventuate-clients/eventuate-client-golang/future"
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
 )
 
 type DispatchingSubscription struct {
 	*Subscription
 	eventHandlers *EventResultHandlerMap
 	//subscription  *Subscription
-	typeHints TypeHintMapper
+	typeHints    TypeHintMapper
+	upcasters    *UpcasterRegistry
+	codecs       *EventCodecs
+	encryptor    *PIIEncryptor
+	tracer       Tracer
+	subscriberId string
+	failures     *FailurePolicy
+	handler      EventResultHandler // the one of the Subscription is cleared on unsubscribing
+	stopMu       sync.Mutex
+	stopErr      error
+	flightMu     sync.Mutex
+	inFlight     int  // the events dispatched whose handling is not over, retries included
+	draining     bool // no event is dispatched anymore
+	drained      chan struct{}
 	//mgr *subscriptionManager
 	//ll            loglib.LogLevelEnum
 	//lg            loglib.Logger
 }
 
 func (sub *DispatchingSubscription) dispatchEvent(evt StompEvent) {
+	if !sub.beginEvent() {
+		// shutting down, the server redelivers it to the next subscription
+		sub.lg.Debug("Dropping event, the subscription is shutting down", loglib.KeyEventId, evt.Id)
+		return
+	}
+	sub.dispatch(evt)
+}
+
+// beginEvent counts an event in flight, unless the subscription is shutting down
+func (sub *DispatchingSubscription) beginEvent() bool {
+	sub.flightMu.Lock()
+	defer sub.flightMu.Unlock()
+	if sub.draining {
+		return false
+	}
+	sub.inFlight++
+	return true
+}
+
+func (sub *DispatchingSubscription) endEvent() {
+	sub.flightMu.Lock()
+	defer sub.flightMu.Unlock()
+	sub.inFlight--
+	if sub.draining && sub.inFlight == 0 {
+		sub.closeDrained()
+	}
+}
+
+// closeDrained closes `drained` once, flightMu is held
+func (sub *DispatchingSubscription) closeDrained() {
+	select {
+	case <-sub.drained:
+	default:
+		close(sub.drained)
+	}
+}
+
+// awaitHandlers stops dispatching events and waits until the ones in flight are handled or `ctx` is done
+func (sub *DispatchingSubscription) awaitHandlers(ctx context.Context) error {
+	sub.flightMu.Lock()
+	if !sub.draining {
+		sub.draining = true
+		if sub.inFlight == 0 {
+			sub.closeDrained()
+		}
+	}
+	sub.flightMu.Unlock()
+
+	select {
+	case <-sub.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dispatch calls the handler of the event, its retries are made within the dispatcher (see retryMiddleware)
+func (sub *DispatchingSubscription) dispatch(evt StompEvent) {
 	//sub := sub.subscription
+	var (
+		span    Span = noopSpan{}
+		handled bool // the results are handled at most once, even if handling them panics
+	)
+	handleResults := func(val interface{}, err error) {
+		handled = true
+		sub.handleEventHandlerResults(&evt, val, err)
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			err := AppError(
 				"Recovered in event handler: %#v", r)
-			sub.handleEventHandlerResults(&evt, nil, err)
+			if handled {
+				sub.lg.Error("Handling the event results panicked", loglib.KeyEventId, evt.Id, loglib.KeyError, err)
+				sub.reportError(err)
+				return
+			}
+			span.End(err)
+			handleResults(nil, err)
 		}
 	}()
 
@@ -29,33 +120,175 @@ func (sub *DispatchingSubscription) dispatchEvent(evt StompEvent) {
 		evtHandler EventResultHandler
 	)
 
-	evtHandler = *sub.eventHandler
-	result = evtHandler(NewEventMetadataFromStomp(&evt, sub.typeHints))
+	evtData, evtMeta := NewEventMetadataFromStompWith(&evt, sub.typeHints, sub.upcasters, sub.codecs)
+	evtMeta.TraceContext, span = sub.startEventSpan(evtMeta)
+
+	evtData, decryptErr := sub.encryptor.decryptFields(evtMeta.EntityType, evtMeta.EntityId, evtData)
+	if decryptErr != nil {
+		span.End(decryptErr)
+		handleResults(nil, decryptErr)
+		return
+	}
+
+	evtHandler = sub.handler
+	result = evtHandler(evtData, evtMeta)
 
 	if result.IsSettled() {
 		val, err := result.GetValue()
-		sub.handleEventHandlerResults(&evt, val, err)
+		span.End(err)
+		handleResults(val, err)
 
 	} else {
-		go func() {
+		go func(span Span) {
 			val, err := result.GetValue() // blocks
+			span.End(err)
 			sub.handleEventHandlerResults(&evt, val, err)
-		}()
+		}(span)
+	}
+}
+
+// startEventSpan starts the span of handling the event, linked to the span the event was written in
+func (sub *DispatchingSubscription) startEventSpan(evtMeta *EventMetadata) (context.Context, Span) {
+	if sub.tracer == nil {
+		return context.Background(), noopSpan{}
+	}
+	attributes := entitySpanAttributes(evtMeta.EntityType, evtMeta.EntityId)
+	attributes[SpanAttributeEventType] = evtMeta.EventType
+	attributes[SpanAttributeEventId] = evtMeta.Id.String()
+	attributes[SpanAttributeSubscriberId] = sub.subscriberId
+	return sub.tracer.StartEventSpan(context.Background(), "eventuate.subscription.Handle", evtMeta.Headers, attributes)
+}
+
+// retryMiddleware calls a failing handler again after the backoff of `policy`. Being applied to the handlers of the
+// dispatcher, the retries hold the lane of the event: the events dispatched after it wait for the policy to resolve.
+// The final failure carries the number of attempts.
+func (sub *DispatchingSubscription) retryMiddleware(policy *RetryPolicy) HandlerMiddleware {
+	return func(next EventResultHandler) EventResultHandler {
+		next = RecoverMiddleware()(next) // a panic is a failure to retry as well
+		return func(data interface{}, meta *EventMetadata) future.Settler {
+			result := future.NewResult()
+			sub.retryAttempt(policy, next, data, meta, 1, result)
+			return result
+		}
 	}
 }
 
+// retryAttempt calls the handler for the `attempt`-th time, `result` is settled with the outcome of the last attempt
+func (sub *DispatchingSubscription) retryAttempt(policy *RetryPolicy, handler EventResultHandler,
+	data interface{}, meta *EventMetadata, attempt int, result *future.Result) {
+
+	onSettled(handler(data, meta), func(val interface{}, err error) {
+		if err == nil {
+			result.Settle(val, nil)
+			return
+		}
+		if attempt >= policy.maxAttempts() {
+			result.Settle(nil, &handlerAttemptsError{
+				attempts: attempt,
+				err:      err})
+			return
+		}
+
+		sub.lg.Warn("Event handler failed, retrying", loglib.KeyEntityType, meta.EntityType, loglib.KeyEntityId, meta.EntityId,
+			loglib.KeyEventType, meta.EventType, loglib.KeyEventId, meta.Id, "attempt", attempt, loglib.KeyError, err)
+		sub.metrics.CountEventsFailed(sub.subscriberId, 1)
+
+		go func() {
+			timer := time.NewTimer(policy.backoff(attempt))
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+				sub.retryAttempt(policy, handler, data, meta, attempt+1, result)
+			case <-sub.Done():
+				result.Settle(nil, &handlerAttemptsError{
+					attempts: attempt,
+					err:      err})
+			}
+		}()
+	})
+}
+
+// handlerAttemptsError is the failure of the last of the `attempts` calls of a handler
+type handlerAttemptsError struct {
+	attempts int
+	err      error
+}
+
+func (e *handlerAttemptsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *handlerAttemptsError) Unwrap() error {
+	return e.err
+}
+
 func (sub *DispatchingSubscription) handleEventHandlerResults(evt *StompEvent, val interface{}, err error) {
-	sub.lg.Printf("handleEventHandlerResults. subscription #%s, event: %v\nValue: %v\nError: %#v",
-		sub.Id, evt, val, err)
+	defer sub.endEvent()
 
-	if err != nil {
-		sub.lg.Printf("Failing event: %v\n", evt)
+	select {
+	case <-sub.Done():
+		// unsubscribed meanwhile, the event is redelivered to the next subscription
+		return
+	default:
+	}
 
-		sub.subscriptionErrors <- AppError(
-			"Failed handler for subscription #%s for event: %#v\nError: %#v",
-			sub.Id, evt, err)
+	if err == nil {
+		sub.AcknowledgeEvent(evt)
 		return
 	}
-	sub.lg.Printf("Acknowledging event: %v\n", evt)
-	sub.AcknowledgeEvent(evt)
+
+	attempt := 1
+	var attemptsErr *handlerAttemptsError
+	if errors.As(err, &attemptsErr) {
+		attempt, err = attemptsErr.attempts, attemptsErr.err
+	}
+
+	sub.lg.Warn("Event handler failed", loglib.KeyEntityType, evt.EntityType, loglib.KeyEntityId, evt.EntityId,
+		loglib.KeyEventType, evt.EventType, loglib.KeyEventId, evt.Id, "attempt", attempt, loglib.KeyError, err)
+	sub.metrics.CountEventsFailed(sub.subscriberId, 1)
+
+	failure := AppError(
+		"Failed handler for subscription #%s for event: %#v\nError: %#v",
+		sub.Id, evt, err)
+
+	switch sub.failures.action() {
+	case FailureDeadLetter:
+		putErr := sub.failures.DeadLetters.Put(DeadLetter{
+			SubscriberId: sub.subscriberId,
+			Event:        *evt,
+			Attempts:     attempt,
+			Error:        err.Error(),
+			FailedAt:     time.Now()})
+		if putErr != nil {
+			sub.lg.Error("Dead letter sink failed", loglib.KeyEventId, evt.Id, loglib.KeyError, putErr)
+			sub.reportError(AppError("Dead letter sink failed for event %s: %w", evt.Id, putErr))
+			return
+		}
+		sub.lg.Warn("Event sent to the dead letters", loglib.KeyEventId, evt.Id, "attempts", attempt)
+		sub.AcknowledgeEvent(evt)
+
+	case FailureStop:
+		sub.stopMu.Lock()
+		if sub.stopErr == nil {
+			sub.stopErr = failure
+		}
+		sub.stopMu.Unlock()
+
+		sub.lg.Error("Stopping the subscription on the failure of its handler", loglib.KeyEventId, evt.Id, "attempts", attempt)
+		sub.reportError(failure)
+		if unsubscribeErr := sub.Unsubscribe(); unsubscribeErr != nil {
+			sub.lg.Error("Unsubscribe failed", loglib.KeyError, unsubscribeErr)
+		}
+
+	default:
+		sub.reportError(failure)
+	}
+}
+
+// Err is the failure that stopped the subscription under the FailureStop action, nil otherwise
+func (sub *DispatchingSubscription) Err() error {
+	sub.stopMu.Lock()
+	defer sub.stopMu.Unlock()
+	return sub.stopErr
 }
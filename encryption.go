@@ -0,0 +1,352 @@
+package eventuate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// PIITag marks the string fields of events (and aggregates, for snapshots) encrypted with the data key of their entity:
+//
+//	Email string `eventuate:"pii"`
+const PIITag = "pii"
+
+// DefaultErasedPlaceholder is what encrypted fields read as once the data key of their entity is deleted
+const DefaultErasedPlaceholder = "[erased]"
+
+const piiCiphertextPrefix = "pii:v1:"
+
+// KeyStore holds the data keys of the entities. Deleting the key of an entity erases its encrypted fields.
+type KeyStore interface {
+	// GetOrCreateKey returns the key of the entity, creating it on first use.
+	// It fails with ErrKeyErased once the key is deleted, an erased entity never gets a new key.
+	GetOrCreateKey(entityType string, entityId Int128) ([]byte, error)
+	// GetKey fails with ErrKeyNotFound when the entity has no key (any more)
+	GetKey(entityType string, entityId Int128) ([]byte, error)
+	// DeleteKey deletes the key for good, leaving a tombstone behind
+	DeleteKey(entityType string, entityId Int128) error
+}
+
+func newDataKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// InMemoryKeyStore is a KeyStore for tests and local development
+type InMemoryKeyStore struct {
+	sync.Mutex
+	keys   map[EntityIdAndType][]byte
+	erased map[EntityIdAndType]bool
+}
+
+func NewInMemoryKeyStore() *InMemoryKeyStore {
+	return &InMemoryKeyStore{
+		keys:   make(map[EntityIdAndType][]byte),
+		erased: make(map[EntityIdAndType]bool)}
+}
+
+func (store *InMemoryKeyStore) GetOrCreateKey(entityType string, entityId Int128) ([]byte, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	idAndType := EntityIdAndType{EntityType: entityType, EntityId: entityId}
+	if key, hasKey := store.keys[idAndType]; hasKey {
+		return key, nil
+	}
+	if store.erased[idAndType] {
+		return nil, ErrKeyErased
+	}
+	key, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+	store.keys[idAndType] = key
+	return key, nil
+}
+
+func (store *InMemoryKeyStore) GetKey(entityType string, entityId Int128) ([]byte, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	key, hasKey := store.keys[EntityIdAndType{EntityType: entityType, EntityId: entityId}]
+	if !hasKey {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (store *InMemoryKeyStore) DeleteKey(entityType string, entityId Int128) error {
+	store.Lock()
+	defer store.Unlock()
+
+	idAndType := EntityIdAndType{EntityType: entityType, EntityId: entityId}
+	delete(store.keys, idAndType)
+	store.erased[idAndType] = true
+	return nil
+}
+
+// FileKeyStore keeps each data key in a file of its own, `<dir>/<entity type>/<entity id>.key`.
+// A deleted key is replaced by the tombstone `<entity id>.erased`.
+type FileKeyStore struct {
+	sync.Mutex
+	dir string
+}
+
+func NewFileKeyStore(dir string) (*FileKeyStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, AppError("NewFileKeyStore: %w", err)
+	}
+	return &FileKeyStore{dir: dir}, nil
+}
+
+func (store *FileKeyStore) GetOrCreateKey(entityType string, entityId Int128) ([]byte, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	key, err := store.readKey(entityType, entityId)
+	if !errors.Is(err, ErrKeyNotFound) {
+		return key, err
+	}
+	if _, err := os.Stat(store.tombstonePath(entityType, entityId)); err == nil {
+		return nil, ErrKeyErased
+	}
+
+	if key, err = newDataKey(); err != nil {
+		return nil, err
+	}
+	keyPath := store.keyPath(entityType, entityId)
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (store *FileKeyStore) GetKey(entityType string, entityId Int128) ([]byte, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	return store.readKey(entityType, entityId)
+}
+
+func (store *FileKeyStore) DeleteKey(entityType string, entityId Int128) error {
+	store.Lock()
+	defer store.Unlock()
+
+	tombstonePath := store.tombstonePath(entityType, entityId)
+	if err := os.MkdirAll(filepath.Dir(tombstonePath), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tombstonePath, nil, 0600); err != nil {
+		return err
+	}
+	err := os.Remove(store.keyPath(entityType, entityId))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (store *FileKeyStore) readKey(entityType string, entityId Int128) ([]byte, error) {
+	key, err := os.ReadFile(store.keyPath(entityType, entityId))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrKeyNotFound
+	}
+	return key, err
+}
+
+func (store *FileKeyStore) keyPath(entityType string, entityId Int128) string {
+	return filepath.Join(store.dir, url.PathEscape(entityType), entityId.String()+".key")
+}
+
+func (store *FileKeyStore) tombstonePath(entityType string, entityId Int128) string {
+	return filepath.Join(store.dir, url.PathEscape(entityType), entityId.String()+".erased")
+}
+
+// PIIEncryptor encrypts the fields tagged `eventuate:"pii"` with AES-GCM, using the data key of the entity
+type PIIEncryptor struct {
+	keys KeyStore
+	// ErasedPlaceholder replaces the fields of the entities whose key is deleted
+	ErasedPlaceholder string
+}
+
+func NewPIIEncryptor(keys KeyStore) *PIIEncryptor {
+	return &PIIEncryptor{
+		keys:              keys,
+		ErasedPlaceholder: DefaultErasedPlaceholder}
+}
+
+// Erase deletes the data key of the entity, its encrypted fields cannot be read afterwards
+func (enc *PIIEncryptor) Erase(entityType string, entityId Int128) error {
+	return enc.keys.DeleteKey(entityType, entityId)
+}
+
+// encryptFields returns a copy of `value` with the tagged fields encrypted, `value` itself is left intact.
+// The tagged fields of an erased entity are written as the placeholder.
+func (enc *PIIEncryptor) encryptFields(entityType string, entityId Int128, value interface{}) (interface{}, error) {
+	if enc == nil || !hasPIIFields(value) {
+		return value, nil
+	}
+
+	key, err := enc.keys.GetOrCreateKey(entityType, entityId)
+	if errors.Is(err, ErrKeyErased) {
+		return transformPIIFields(value, false, func(string) (string, error) {
+			return enc.ErasedPlaceholder, nil
+		})
+	}
+	if err != nil {
+		return nil, AppError("Cannot get the data key of %s/%s: %w", entityType, entityId, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	additionalData := []byte(entityType + "/" + entityId.String())
+
+	return transformPIIFields(value, false, func(plaintext string) (string, error) {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return "", err
+		}
+		sealed := aead.Seal(nonce, nonce, []byte(plaintext), additionalData)
+		return piiCiphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+	})
+}
+
+// decryptFields decrypts the tagged fields, in place when `value` is a pointer.
+// The fields of an entity without a data key read as the placeholder.
+func (enc *PIIEncryptor) decryptFields(entityType string, entityId Int128, value interface{}) (interface{}, error) {
+	if enc == nil || !hasPIIFields(value) {
+		return value, nil
+	}
+
+	var aead cipher.AEAD
+	key, err := enc.keys.GetKey(entityType, entityId)
+	if err == nil {
+		if aead, err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return nil, AppError("Cannot get the data key of %s/%s: %w", entityType, entityId, err)
+	}
+	additionalData := []byte(entityType + "/" + entityId.String())
+
+	return transformPIIFields(value, true, func(field string) (string, error) {
+		if !strings.HasPrefix(field, piiCiphertextPrefix) {
+			return field, nil
+		}
+		if aead == nil {
+			return enc.ErasedPlaceholder, nil
+		}
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(field, piiCiphertextPrefix))
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return "", AppError("Malformed encrypted field of %s/%s", entityType, entityId)
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+		if err != nil {
+			return "", AppError("Cannot decrypt field of %s/%s: %v", entityType, entityId, err)
+		}
+		return string(plaintext), nil
+	})
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, AppError("Invalid data key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func hasPIIFields(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	valueType := getUnderlyingType(reflect.TypeOf(value))
+	if valueType.Kind() != reflect.Struct {
+		return false
+	}
+	for idx := 0; idx < valueType.NumField(); idx++ {
+		if isPIIField(valueType.Field(idx)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPIIField(field reflect.StructField) bool {
+	return field.PkgPath == "" && field.Type.Kind() == reflect.String && field.Tag.Get("eventuate") == PIITag
+}
+
+// transformPIIFields applies `transform` to the tagged (top level) fields of the struct `value`,
+// on a copy unless `inPlace` is set and `value` is a pointer. The result has the shape of `value`.
+func transformPIIFields(value interface{}, inPlace bool, transform func(string) (string, error)) (interface{}, error) {
+	source := reflect.ValueOf(value)
+	isPtr := source.Kind() == reflect.Ptr
+	if isPtr && source.IsNil() {
+		return value, nil
+	}
+
+	target := source
+	if !isPtr || !inPlace {
+		target = reflect.New(getUnderlyingType(source.Type()))
+		target.Elem().Set(reflect.Indirect(source))
+	}
+
+	structValue := target.Elem()
+	for idx := 0; idx < structValue.NumField(); idx++ {
+		if !isPIIField(structValue.Type().Field(idx)) {
+			continue
+		}
+		field := structValue.Field(idx)
+		transformed, err := transform(field.String())
+		if err != nil {
+			return nil, err
+		}
+		field.SetString(transformed)
+	}
+
+	if isPtr {
+		return target.Interface(), nil
+	}
+	return target.Elem().Interface(), nil
+}
+
+// encryptEvents returns the events with their tagged fields encrypted for the entity
+func encryptEvents(encryptor *PIIEncryptor, entityType string, entityId Int128, events []Event) ([]Event, error) {
+	if encryptor == nil {
+		return events, nil
+	}
+	encrypted := make([]Event, len(events))
+	for idx, event := range events {
+		var err error
+		if encrypted[idx], err = encryptor.encryptFields(entityType, entityId, event); err != nil {
+			return nil, err
+		}
+	}
+	return encrypted, nil
+}
+
+// SetPIIEncryptor sets the encryptor the repositories made with this client protect events with
+func (rest *RESTClient) SetPIIEncryptor(encryptor *PIIEncryptor) {
+	rest.encryptor = encryptor
+}
+
+// SetPIIEncryptor sets the encryptor the subscriptions made with this client decrypt events with
+func (stomp *StompClient) SetPIIEncryptor(encryptor *PIIEncryptor) {
+	stomp.encryptor = encryptor
+}
@@ -0,0 +1,246 @@
+package eventuate_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+const CUSTOMER_ENTITY = "net.chrisrichardson.eventstore.example.CustomerEntity"
+const CUSTOMER_REGISTERED = "net.chrisrichardson.eventstore.example.CustomerRegisteredEvent"
+const CUSTOMER_RENAMED = "net.chrisrichardson.eventstore.example.CustomerRenamedEvent"
+
+type CustomerAggregate struct {
+	Name string `eventuate:"pii"`
+	Tier string
+}
+
+type RegisterCustomerCommand struct {
+	Name string
+	Tier string
+}
+
+type RenameCustomerCommand struct {
+	Name string
+}
+
+type CustomerRegisteredEvent struct {
+	Name string `eventuate:"pii"`
+	Tier string
+}
+
+type CustomerRenamedEvent struct {
+	Name string `eventuate:"pii"`
+}
+
+func NewCustomerAggregate() *CustomerAggregate {
+	return &CustomerAggregate{}
+}
+
+func (customer *CustomerAggregate) ProcessRegisterCustomerCommand(cmd *RegisterCustomerCommand) []eventuate.Event {
+	return []eventuate.Event{&CustomerRegisteredEvent{Name: cmd.Name, Tier: cmd.Tier}}
+}
+
+func (customer *CustomerAggregate) ProcessRenameCustomerCommand(cmd *RenameCustomerCommand) []eventuate.Event {
+	return []eventuate.Event{&CustomerRenamedEvent{Name: cmd.Name}}
+}
+
+func (customer *CustomerAggregate) ApplyCustomerRegisteredEvent(evt *CustomerRegisteredEvent) *CustomerAggregate {
+	customer.Name = evt.Name
+	customer.Tier = evt.Tier
+	return customer
+}
+
+func (customer *CustomerAggregate) ApplyCustomerRenamedEvent(evt *CustomerRenamedEvent) *CustomerAggregate {
+	customer.Name = evt.Name
+	return customer
+}
+
+func TestAggregateRepository_PIIEncryption(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCustomerAggregate, CUSTOMER_ENTITY)
+	assertNoError(t, err)
+	meta.SetSnapshotStrategy(eventuate.NewEveryNEventsSnapshotStrategy(2))
+
+	crud := eventuate.NewInMemoryCrud()
+	encryptor := eventuate.NewPIIEncryptor(eventuate.NewInMemoryKeyStore())
+	repo := eventuate.NewAggregateRepository(crud, meta).SetPIIEncryptor(encryptor)
+	assertNoError(t, repo.RegisterEventType(CUSTOMER_REGISTERED, CustomerRegisteredEvent{}))
+	assertNoError(t, repo.RegisterEventType(CUSTOMER_RENAMED, CustomerRenamedEvent{}))
+
+	saved, err := repo.Save(&RegisterCustomerCommand{Name: "Arthur Dent", Tier: "gold"})
+	assertNoError(t, err)
+
+	_, err = repo.Update(saved.EntityId, &RenameCustomerCommand{Name: "Ford Prefect"})
+	assertNoError(t, err)
+
+	loaded, err := crud.Find(CUSTOMER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	assert.Len(t, loaded.Events, 0)
+	if assert.NotNil(t, loaded.Snapshot) {
+		assert.Contains(t, loaded.Snapshot.SerializedSnapshot.Json, "gold")
+		assert.NotContains(t, loaded.Snapshot.SerializedSnapshot.Json, "Ford Prefect")
+	}
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, &CustomerAggregate{Name: "Ford Prefect", Tier: "gold"}, found.EntityInstance)
+
+	assertNoError(t, encryptor.Erase(CUSTOMER_ENTITY, saved.EntityId))
+
+	found, err = repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, &CustomerAggregate{Name: eventuate.DefaultErasedPlaceholder, Tier: "gold"}, found.EntityInstance)
+
+	// no new key is made for the erased entity, its later fields are written erased
+	_, err = repo.Update(saved.EntityId, &RenameCustomerCommand{Name: "Zaphod Beeblebrox"})
+	assertNoError(t, err)
+	_, err = repo.Update(saved.EntityId, &RenameCustomerCommand{Name: "Zaphod Beeblebrox"})
+	assertNoError(t, err)
+
+	loaded, err = crud.Find(CUSTOMER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	if assert.NotNil(t, loaded.Snapshot) {
+		assert.NotContains(t, loaded.Snapshot.SerializedSnapshot.Json, "Zaphod")
+	}
+	found, err = repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, &CustomerAggregate{Name: eventuate.DefaultErasedPlaceholder, Tier: "gold"}, found.EntityInstance)
+}
+
+func TestTypedRepository_PIIEncryption(t *testing.T) {
+	crud := eventuate.NewInMemoryCrud()
+	encryptor := eventuate.NewPIIEncryptor(eventuate.NewInMemoryKeyStore())
+	encryptor.ErasedPlaceholder = "<erased>"
+
+	repo := eventuate.NewTypedRepository[CustomerAggregate](crud, CUSTOMER_ENTITY, nil).SetPIIEncryptor(encryptor)
+	eventuate.RegisterCommand(repo, func(customer *CustomerAggregate, cmd RegisterCustomerCommand) ([]eventuate.Event, error) {
+		return []eventuate.Event{CustomerRegisteredEvent{Name: cmd.Name, Tier: cmd.Tier}}, nil
+	})
+	assertNoError(t, eventuate.RegisterEvent(repo, CUSTOMER_REGISTERED,
+		func(customer *CustomerAggregate, evt CustomerRegisteredEvent) {
+			customer.Name = evt.Name
+			customer.Tier = evt.Tier
+		}))
+
+	saved, err := repo.Save(RegisterCustomerCommand{Name: "Arthur Dent", Tier: "gold"})
+	assertNoError(t, err)
+
+	loaded, err := crud.Find(CUSTOMER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	if assert.Len(t, loaded.Events, 1) {
+		assert.NotContains(t, loaded.Events[0].EventData, "Arthur Dent")
+	}
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, "Arthur Dent", found.Name)
+
+	assertNoError(t, encryptor.Erase(CUSTOMER_ENTITY, saved.EntityId))
+
+	found, err = repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, &CustomerAggregate{Name: "<erased>", Tier: "gold"}, found)
+
+	_, err = repo.Update(saved.EntityId, RegisterCustomerCommand{Name: "Zaphod Beeblebrox", Tier: "silver"})
+	assertNoError(t, err)
+
+	found, err = repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, &CustomerAggregate{Name: "<erased>", Tier: "silver"}, found)
+}
+
+func TestFileKeyStore(t *testing.T) {
+	dir := t.TempDir()
+	entityId := eventuate.Int128FromString(EVENT_ID_2)
+
+	store, err := eventuate.NewFileKeyStore(dir)
+	assertNoError(t, err)
+
+	_, err = store.GetKey(CUSTOMER_ENTITY, entityId)
+	assert.True(t, errors.Is(err, eventuate.ErrKeyNotFound))
+
+	key, err := store.GetOrCreateKey(CUSTOMER_ENTITY, entityId)
+	assertNoError(t, err)
+	assert.Len(t, key, 32)
+
+	reopened, err := eventuate.NewFileKeyStore(dir)
+	assertNoError(t, err)
+	sameKey, err := reopened.GetOrCreateKey(CUSTOMER_ENTITY, entityId)
+	assertNoError(t, err)
+	assert.Equal(t, key, sameKey)
+
+	assertNoError(t, reopened.DeleteKey(CUSTOMER_ENTITY, entityId))
+	_, err = store.GetKey(CUSTOMER_ENTITY, entityId)
+	assert.True(t, errors.Is(err, eventuate.ErrKeyNotFound))
+	_, err = store.GetOrCreateKey(CUSTOMER_ENTITY, entityId)
+	assert.True(t, errors.Is(err, eventuate.ErrKeyErased))
+	assertNoError(t, store.DeleteKey(CUSTOMER_ENTITY, entityId))
+}
+
+func TestInMemoryKeyStore(t *testing.T) {
+	store := eventuate.NewInMemoryKeyStore()
+	entityId := eventuate.Int128FromString(EVENT_ID_2)
+
+	key, err := store.GetOrCreateKey(CUSTOMER_ENTITY, entityId)
+	assertNoError(t, err)
+	assert.Len(t, key, 32)
+
+	assertNoError(t, store.DeleteKey(CUSTOMER_ENTITY, entityId))
+	_, err = store.GetKey(CUSTOMER_ENTITY, entityId)
+	assert.True(t, errors.Is(err, eventuate.ErrKeyNotFound))
+	_, err = store.GetOrCreateKey(CUSTOMER_ENTITY, entityId)
+	assert.True(t, errors.Is(err, eventuate.ErrKeyErased))
+}
+
+func TestSubscribeAndDispatch_PII(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	type MyEntity struct {
+		Name string `eventuate:"pii"`
+	}
+	type CreateMyEntity struct {
+		Name string
+	}
+	type MyEntityWasCreatedPIIEvent struct {
+		Name string `json:"name" eventuate:"pii"`
+	}
+
+	encryptor := eventuate.NewPIIEncryptor(eventuate.NewInMemoryKeyStore())
+	repoClient := buildREST(t, srv.ClientBuilder().WithPIIEncryptor(encryptor))
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedPIIEvent{}).
+		WithPIIEncryptor(encryptor))
+
+	repo := eventuate.NewTypedRepository[MyEntity](repoClient, ENTITY_TYPE, nil)
+	eventuate.RegisterCommand(repo, func(entity *MyEntity, cmd CreateMyEntity) ([]eventuate.Event, error) {
+		return []eventuate.Event{MyEntityWasCreatedPIIEvent{Name: cmd.Name}}, nil
+	})
+	assert.Nil(t, eventuate.RegisterEvent(repo, EVENT_CREATED, func(entity *MyEntity, evt MyEntityWasCreatedPIIEvent) {
+		entity.Name = evt.Name
+	}))
+
+	received := make(chan interface{}, 1)
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			received <- data
+			return future.NewSuccess(true)
+		})
+
+	_, err := stomp.SubscribeAndDispatch("pii-subscriber", handlers, nil, false)
+	assert.Nil(t, err)
+
+	saved, err := repo.Save(CreateMyEntity{Name: "Marvin"})
+	assert.Nil(t, err)
+
+	loaded, err := repoClient.Find(ENTITY_TYPE, saved.EntityId, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, loaded.Events, 1) {
+		assert.NotContains(t, loaded.Events[0].EventData, "Marvin")
+	}
+
+	assert.Equal(t, &MyEntityWasCreatedPIIEvent{Name: "Marvin"}, receive(t, received))
+}
@@ -1,6 +1,7 @@
 package eventuate
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -15,6 +16,15 @@ type EventMetadata struct {
 	SwimLane     int
 	Offset       int
 	EventContext EventContext
+	// CorrelationId, CausationId and UserId are taken from the metadata the event was written with
+	CorrelationId string
+	CausationId   string
+	UserId        string
+	// Headers holds the whole metadata the event was written with
+	Headers map[string]string
+	// TraceContext carries the span of handling the event (when the subscription is traced),
+	// pass it on to the repository calls made by the handler
+	TraceContext context.Context
 }
 
 func (meta *EventMetadata) String() string {
@@ -38,28 +48,49 @@ func (meta *EventMetadata) String() string {
 }
 
 
+// NewEventMetadataFromStomp deserializes the event with the JSON codec, leaving it in the shape it was stored in,
+// the event data is nil when the type is unknown or the event cannot be read
 func NewEventMetadataFromStomp(evt *StompEvent, hintsMap TypeHintMapper) (interface{}, *EventMetadata) {
+	return NewEventMetadataFromStompWith(evt, hintsMap, nil, nil)
+}
+
+// NewEventMetadataFromStompWith deserializes the event upcast with `upcasters` to the current shape of its type
+// and decoded with `codecs`, the event data is nil when the type is unknown or the event cannot be read
+func NewEventMetadataFromStompWith(evt *StompEvent, hintsMap TypeHintMapper, upcasters *UpcasterRegistry, codecs *EventCodecs) (interface{}, *EventMetadata) {
 
 	var evtData interface{}
 
-	if hintsMap.HasEventType(evt.EventType) {
-		source := []byte(evt.EventData)
-		destination := reflect.New(getUnderlyingType(hintsMap.GetEventType(evt.EventType))).Interface()
-		evtDataErr := json.Unmarshal(source, destination)
+	eventType, eventData, upcastErr := upcasters.Upcast(evt.EventType, evt.EventData, evt.Metadata)
+	if upcastErr != nil {
+		eventType = evt.EventType
+	}
+
+	if upcastErr == nil && hintsMap.HasEventType(eventType) {
+		destination := reflect.New(getUnderlyingType(hintsMap.GetEventType(eventType))).Interface()
+		evtDataErr := codecs.decode(eventData, evt.Metadata, destination)
 		if evtDataErr == nil {
 			evtData = destination
 		}
 	}
 
+	headers, headersErr := parseEventMetadata(evt.Metadata)
+	if headersErr != nil {
+		headers = nil
+	}
+
 	entityTypeParts := strings.Split(evt.EntityType, "/")
 	return evtData, &EventMetadata{
-		Id:           evt.Id,
-		EntityId:     evt.EntityId,
-		EntityType:   entityTypeParts[len(entityTypeParts)-1],
-		EventType:    evt.EventType,
-		SwimLane:     evt.Swimlane,
-		Offset:       evt.Offset,
-		EventContext: EventContext(evt.EventToken)}
+		Id:            evt.Id,
+		EntityId:      evt.EntityId,
+		EntityType:    entityTypeParts[len(entityTypeParts)-1],
+		EventType:     eventType,
+		SwimLane:      evt.Swimlane,
+		Offset:        evt.Offset,
+		EventContext:  EventContext(evt.EventToken),
+		CorrelationId: headers[EventMetadataCorrelationId],
+		CausationId:   headers[EventMetadataCausationId],
+		UserId:        headers[EventMetadataUserId],
+		Headers:       headers}
 }
 
 // StompEvent is the struct for stomp Event
@@ -67,6 +98,7 @@ type StompEvent struct {
 	Id         Int128 `json:"id"`
 	EventType  string `json:"eventType"`
 	EventData  string `json:"eventData"`
+	Metadata   string `json:"metadata,omitempty"`
 	EntityId   Int128 `json:"entityId"`
 	EntityType string `json:"entityType"`
 	EventToken string `json:"eventToken"`
@@ -108,6 +140,7 @@ type EntityIdAndVersion struct {
 }
 
 type AggregateCrudSaveOptions struct {
+	// EventMetadata is a JSON object added to the metadata of every event
 	EventMetadata   *string
 	TriggeringEvent *EventContext
 	EntityId        Int128
@@ -119,10 +152,29 @@ type AggregateCrudFindOptions struct {
 
 type AggregateCrudUpdateOptions struct {
 	TriggeringEvent    *EventContext
+	// EventMetadata is a JSON object added to the metadata of every event
 	EventMetadata      *string
 	SerializedSnapshot *SerializedSnapshot
 }
 
+// SaveOptions are the options of AggregateRepository.SaveWithOptions
+type SaveOptions struct {
+	// TriggeringEvent is the EventMetadata.EventContext of the event being handled
+	TriggeringEvent *EventContext
+	// EventMetadata is added to the metadata of the new events, over the one of the repository's provider
+	EventMetadata map[string]string
+}
+
+// UpdateOptions are the options of AggregateRepository.UpdateWithOptions
+type UpdateOptions struct {
+	// Retry re-runs the update on optimistic locking failures, nil disables retries
+	Retry *RetryPolicy
+	// TriggeringEvent is the EventMetadata.EventContext of the event being handled
+	TriggeringEvent *EventContext
+	// EventMetadata is added to the metadata of the new events, over the one of the repository's provider
+	EventMetadata map[string]string
+}
+
 // TODO: implement
 type Snapshot interface{}
 
@@ -145,6 +197,14 @@ type SubscriberOptions struct {
 	Durability            SubscriberDurability      // DURABLE
 	ReadFrom              SubscriberInitialPosition // BEGINNING
 	ProgressNotifications bool                      // false
+	// ProgressHandler receives the progress notifications, it is called from the subscription go-routine
+	ProgressHandler ProgressNotificationHandler
+	// FailurePolicy handles the failures of the handlers of SubscribeAndDispatch, they are only reported if nil
+	FailurePolicy *FailurePolicy
+	// Swimlanes makes SubscribeAndDispatch dispatch the events over swimlanes configured this way
+	Swimlanes *SwimlaneOptions
+	// Middleware wraps the handlers of SubscribeAndDispatch, the first one outermost
+	Middleware []HandlerMiddleware
 }
 
 type SubscriberDurability int
@@ -160,14 +220,69 @@ const (
 	END
 )
 
+func (durability SubscriberDurability) String() string {
+	if durability == TRANSIENT {
+		return "TRANSIENT"
+	}
+	return "DURABLE"
+}
+
+func (position SubscriberInitialPosition) String() string {
+	if position == END {
+		return "END"
+	}
+	return "BEGINNING"
+}
+
+// ProgressNotificationHeader marks MESSAGE frames that carry a ProgressNotification instead of a StompEvent
+const ProgressNotificationHeader = "progress-notification"
+
+// ProgressNotification reports the position a subscriber has reached in a swimlane,
+// including events that were not delivered because the subscriber is not interested in them
+type ProgressNotification struct {
+	Swimlane int `json:"swimlane"`
+	Offset   int `json:"offset"`
+}
+
+type ProgressNotificationHandler func(*ProgressNotification)
+
 type SerializedSnapshotWithVersion struct {
 	SerializedSnapshot *SerializedSnapshot
 	EntityVersion      Int128
 }
 
+// serializedSnapshotWithVersionJson is the flat wire format of SerializedSnapshotWithVersion
+type serializedSnapshotWithVersionJson struct {
+	SnapshotType  string `json:"snapshotType"`
+	Json          string `json:"json"`
+	EntityVersion Int128 `json:"entityVersion"`
+}
+
+func (snapshot SerializedSnapshotWithVersion) MarshalJSON() ([]byte, error) {
+	tmp := serializedSnapshotWithVersionJson{
+		EntityVersion: snapshot.EntityVersion}
+	if snapshot.SerializedSnapshot != nil {
+		tmp.SnapshotType = snapshot.SerializedSnapshot.SnapshotType
+		tmp.Json = snapshot.SerializedSnapshot.Json
+	}
+	return json.Marshal(tmp)
+}
+
+func (snapshot *SerializedSnapshotWithVersion) UnmarshalJSON(data []byte) error {
+	var tmp serializedSnapshotWithVersionJson
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	snapshot.SerializedSnapshot = &SerializedSnapshot{
+		SnapshotType: tmp.SnapshotType,
+		Json:         tmp.Json}
+	snapshot.EntityVersion = tmp.EntityVersion
+	return nil
+}
+
 type SerializedSnapshot struct {
-	SnapshotType string
-	Json         string
+	SnapshotType string `json:"snapshotType"`
+	Json         string `json:"json"`
 }
 
 // EventIdTypeAndData is the struct for EventID, EventType and EventData
@@ -184,13 +299,16 @@ func (evt EventIdTypeAndData) String() string {
 func (rcv *EventIdTypeAndData) ToEventTypeAndData() EventTypeAndData {
 	return EventTypeAndData{
 		EventType: rcv.EventType,
-		EventData: rcv.EventData}
+		EventData: rcv.EventData,
+		Metadata:  rcv.Metadata}
 }
 
 // EventTypeAndData is the struct for EventType and EventData
 type EventTypeAndData struct {
 	EventType string `json:"eventType"`
 	EventData string `json:"eventData"`
+	// Metadata is a JSON object, see EventMetadataSchemaVersion
+	Metadata string `json:"metadata,omitempty"`
 }
 
 // CreateEntityRequest is the struct for create entity request
@@ -218,9 +336,27 @@ type EntityIdVersionAndEventIds struct {
 
 // SubscriptionRequest is the struct for subscription request
 type SubscriptionRequest struct {
-	EntityTypesAndEvents interface{} `json:"entityTypesAndEvents"`
-	SubscriberID         string      `json:"subscriberId"`
-	Space                string      `json:"space"`
+	EntityTypesAndEvents interface{}                 `json:"entityTypesAndEvents"`
+	SubscriberID         string                      `json:"subscriberId"`
+	Space                string                      `json:"space"`
+	Options              *SubscriptionRequestOptions `json:"options,omitempty"`
+}
+
+// SubscriptionRequestOptions is the wire format of SubscriberOptions
+type SubscriptionRequestOptions struct {
+	Durability            string `json:"durability"`
+	ReadFrom              string `json:"readFrom"`
+	ProgressNotifications bool   `json:"progressNotifications"`
+}
+
+func newSubscriptionRequestOptions(options *SubscriberOptions) *SubscriptionRequestOptions {
+	if options == nil {
+		return nil
+	}
+	return &SubscriptionRequestOptions{
+		Durability:            options.Durability.String(),
+		ReadFrom:              options.ReadFrom.String(),
+		ProgressNotifications: options.ProgressNotifications}
 }
 
 // CreateResponse is the struct for create response
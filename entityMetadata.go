@@ -9,6 +9,8 @@ type EntityMetadata struct {
 	HasEntity      bool               `json:"-"`
 	EntityInstance interface{}        `json:"-"`
 	metadata       *AggregateMetadata `json:"-"`
+	// AlreadyProcessed reports that the triggering event of the call was already processed
+	AlreadyProcessed bool `json:"-"`
 }
 
 func (entity *EntityMetadata) ApplyEvent(event Event) (*EntityMetadata, error) {
@@ -37,7 +39,7 @@ func (entity *EntityMetadata) ApplyEvent(event Event) (*EntityMetadata, error) {
 
 	nextInstanceInterface := values[0].Interface()
 	if !checkUnderlyingType(nextInstanceInterface, meta.UnderlyingType) {
-		return entity, AppError("Signature mismatch. Method: %s is either missing or returned unexpected type: %T, not %v", eventMethodName, nextInstanceInterface, meta.UnderlyingType)
+		return entity, SignatureMismatchError("Signature mismatch. Method: %s is either missing or returned unexpected type: %T, not %v", eventMethodName, nextInstanceInterface, meta.UnderlyingType)
 	}
 
 	return &EntityMetadata{
@@ -51,9 +53,9 @@ func (entity *EntityMetadata) ApplyEvent(event Event) (*EntityMetadata, error) {
 func (entity *EntityMetadata) applyEvents(events []interface{}) (*EntityMetadata, error) {
 	result := entity
 	for _, event := range events {
-		nextEntity, err := entity.ApplyEvent(event)
+		nextEntity, err := result.ApplyEvent(event)
 		if err != nil {
-			return nil, AppError("Event Application Error: %v", err)
+			return nil, AppError("Event Application Error: %w", err)
 		}
 		result = nextEntity
 	}
@@ -2,9 +2,34 @@ package eventuate
 
 import "fmt"
 import (
+	"errors"
 	"net/http"
 )
 
+// Sentinel errors to tell failures apart with `errors.Is`
+var (
+	ErrOptimisticLock               = errors.New("optimistic locking failure")
+	ErrEntityExists                 = errors.New("entity already exists")
+	ErrDuplicateEvent               = errors.New("duplicate triggering event")
+	ErrEntityTemporarilyUnavailable = errors.New("entity temporarily unavailable")
+	ErrNotFound                     = errors.New("resource is not found")
+	ErrUnauthorized                 = errors.New("not authorized")
+	ErrMethodNotFound               = errors.New("method not found")
+	ErrSignatureMismatch            = errors.New("signatures mismatch")
+	ErrKeyNotFound                  = errors.New("data key is not found")
+	ErrKeyErased                    = errors.New("data key is erased")
+	ErrDispatcherClosed             = errors.New("dispatcher is closed")
+	ErrHandlerTimeout               = errors.New("event handler timed out")
+	ErrClientClosed                 = errors.New("client is shut down")
+)
+
+// conflictErrors maps the conflict codes of the Eventuate server to the sentinel errors
+var conflictErrors = map[string]error{
+	"optimistic_lock_error":          ErrOptimisticLock,
+	"entity_exists":                  ErrEntityExists,
+	"duplicate_event":                ErrDuplicateEvent,
+	"entity_temporarily_unavailable": ErrEntityTemporarilyUnavailable}
+
 type appErrCode int
 
 const (
@@ -22,10 +47,15 @@ type appError struct {
 	args         []interface{}
 }
 
-type appRestError struct {
+// RestError is a failed call to the Eventuate server (or InMemoryCrud).
+// The entity fields are set when the failing call refers to an entity.
+type RestError struct {
 	appError
-	httpCode int
-	conflict string
+	HttpCode        int
+	Conflict        string // Eventuate conflict code of a 409 response (e.g. `optimistic_lock_error`)
+	EntityType      string
+	EntityId        Int128
+	ExpectedVersion Int128
 }
 
 func (e *appError) Error() string {
@@ -52,8 +82,26 @@ func (e *appError) Error() string {
 	if len(e.args) == 0 {
 		return fmt.Sprintf("%v: %v", prefix, e.shortMessage)
 	}
-	return fmt.Sprintf("%v: %v", prefix,
-		fmt.Sprintf(e.shortMessage, e.args...))
+	return fmt.Sprintf("%v: %v", prefix, e.message())
+}
+
+// message formats the arguments like fmt.Errorf, hence `%w` wraps an error
+func (e *appError) message() string {
+	return fmt.Errorf(e.shortMessage, e.args...).Error()
+}
+
+func (e *appError) Unwrap() error {
+	return errors.Unwrap(fmt.Errorf(e.shortMessage, e.args...))
+}
+
+func (e *appError) Is(target error) bool {
+	switch e.code {
+	case appErrSignaturesMismatch, appErrSignaturesMismatchExpRefGotVal, appErrSignaturesMismatchExpValGotRef:
+		return target == ErrSignatureMismatch
+	case appErrMethodNotFound:
+		return target == ErrMethodNotFound
+	}
+	return false
 }
 
 func appErrorWithCode(code appErrCode, shortMessage string, args ...interface{}) *appError {
@@ -80,21 +128,44 @@ func AppError(shortMessage string, args ...interface{}) *appError {
 		shortMessage, args}
 }
 
-func RestError(httpCode int, conflict string, shortMessage string, args ...interface{}) *appRestError {
-	return &appRestError{
-		appError{
+// NewRestError replaces the former `RestError(...)` constructor, whose name is now taken by the
+// exported type; the constructor returned an unexported type, callers only relying on `error` just need the new name.
+func NewRestError(httpCode int, conflict string, shortMessage string, args ...interface{}) *RestError {
+	return &RestError{
+		appError: appError{
 			appErrRestErrors,
 			shortMessage, args},
-		httpCode,
-		conflict}
+		HttpCode: httpCode,
+		Conflict: conflict}
+}
+
+// forEntity records the entity the failing call refers to
+func (e *RestError) forEntity(entityType string, entityId Int128, expectedVersion Int128) *RestError {
+	e.EntityType = entityType
+	e.EntityId = entityId
+	e.ExpectedVersion = expectedVersion
+	return e
+}
+
+func (e *RestError) Is(target error) bool {
+	switch e.HttpCode {
+	case http.StatusConflict:
+		conflictErr, isKnown := conflictErrors[e.Conflict]
+		return isKnown && target == conflictErr
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	}
+	return false
 }
 
-func (e *appRestError) Error() string {
+func (e *RestError) Error() string {
 	var msg string
 
 	prefix := "REST API Error: "
-	commonErrorBody := fmt.Sprintf(e.shortMessage, e.args...)
-	switch e.httpCode {
+	commonErrorBody := e.message()
+	switch e.HttpCode {
 	case http.StatusInternalServerError:
 		msg = fmt.Sprintf("Eventuate Server exception: \n%v",
 			commonErrorBody)
@@ -110,14 +181,17 @@ func (e *appRestError) Error() string {
 
 	case http.StatusConflict:
 		{
-			switch e.conflict {
+			switch e.Conflict {
 			case "":
 				msg = "Status Conflict general Error: unknown response"
 			case "entity_exists":
 				msg = "Status Conflict Error: entity already exists"
 			case "optimistic_lock_error":
-				// todo: add here entityIdAndType, entityVersion
 				msg = "Status Conflict Error: optimistic locking"
+				if e.EntityType != "" {
+					msg = fmt.Sprintf("%s (entity: %s/%s, expected version: %s)",
+						msg, e.EntityType, e.EntityId, e.ExpectedVersion)
+				}
 			case "duplicate_event":
 				msg = "Status Conflict Error: duplicate triggering Event"
 			case "entity_temporarily_unavailable":
@@ -128,7 +202,23 @@ func (e *appRestError) Error() string {
 			//err = AppError("An optimistic locking failure or Event has already been processed")
 		}
 	default:
-		msg = fmt.Sprintf("Unrecognized response status: %d", e.httpCode)
+		msg = fmt.Sprintf("Unrecognized response status: %d", e.HttpCode)
 	}
 	return fmt.Sprintf("%v %v\n%v", prefix, msg, commonErrorBody)
 }
+
+// RetryExhaustedError is returned by AggregateRepository.UpdateWithOptions
+// when every attempt allowed by the RetryPolicy failed on optimistic locking
+type RetryExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("Retries exhausted after %d attempt(s): %v",
+		e.Attempts, e.Err)
+}
+
+func (e *RetryExhaustedError) Unwrap() error {
+	return e.Err
+}
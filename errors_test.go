@@ -0,0 +1,61 @@
+package eventuate_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestError_Is(t *testing.T) {
+	for conflict, sentinel := range map[string]error{
+		"optimistic_lock_error":          eventuate.ErrOptimisticLock,
+		"entity_exists":                  eventuate.ErrEntityExists,
+		"duplicate_event":                eventuate.ErrDuplicateEvent,
+		"entity_temporarily_unavailable": eventuate.ErrEntityTemporarilyUnavailable} {
+
+		err := eventuate.NewRestError(http.StatusConflict, conflict, "conflict")
+		assert.True(t, errors.Is(err, sentinel), conflict)
+		assert.False(t, errors.Is(err, eventuate.ErrNotFound), conflict)
+	}
+
+	assert.True(t, errors.Is(eventuate.NewRestError(http.StatusNotFound, "", "missing"), eventuate.ErrNotFound))
+	assert.True(t, errors.Is(eventuate.NewRestError(http.StatusUnauthorized, "", "denied"), eventuate.ErrUnauthorized))
+	assert.False(t, errors.Is(eventuate.NewRestError(http.StatusConflict, "", "unknown"), eventuate.ErrOptimisticLock))
+}
+
+func TestAppError_Is(t *testing.T) {
+	assert.True(t, errors.Is(eventuate.SignatureMismatchError("mismatch"), eventuate.ErrSignatureMismatch))
+	assert.True(t, errors.Is(eventuate.SignatureMismatchExpRefGotValError("mismatch"), eventuate.ErrSignatureMismatch))
+	assert.True(t, errors.Is(eventuate.MethodNotFoundError("missing"), eventuate.ErrMethodNotFound))
+	assert.False(t, errors.Is(eventuate.AppError("general"), eventuate.ErrMethodNotFound))
+
+	cause := eventuate.NewRestError(http.StatusNotFound, "", "missing")
+	wrapped := eventuate.AppError("Repository search exception (Find): %w", cause)
+	assert.True(t, errors.Is(wrapped, eventuate.ErrNotFound))
+	assert.Equal(t, "Eventuate General Error: Repository search exception (Find): "+cause.Error(), wrapped.Error())
+}
+
+func TestAggregateRepository_Errors(t *testing.T) {
+	repo, _ := newRacingRepository(t, 1)
+
+	saved, err := repo.Save(&IncrementCommand{Amount: 1})
+	assertNoError(t, err)
+
+	_, err = repo.Update(saved.EntityId, &IncrementCommand{Amount: 2})
+	assert.True(t, errors.Is(err, eventuate.ErrOptimisticLock))
+
+	var restErr *eventuate.RestError
+	if assert.True(t, errors.As(err, &restErr)) {
+		assert.Equal(t, http.StatusConflict, restErr.HttpCode)
+		assert.Equal(t, "optimistic_lock_error", restErr.Conflict)
+		assert.Equal(t, COUNTER_ENTITY, restErr.EntityType)
+		assert.Equal(t, saved.EntityId, restErr.EntityId)
+		assert.Equal(t, saved.EntityVersion, restErr.ExpectedVersion)
+	}
+
+	_, err = repo.Find(eventuate.Int128FromString(EVENT_ID_2))
+	assert.True(t, errors.Is(err, eventuate.ErrNotFound))
+}
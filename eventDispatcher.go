@@ -5,7 +5,7 @@ import loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
 
 type EventDispatcher struct {
 	handlers *EventResultHandlerMap
-	lg       loglib.Logger
+	lg       loglib.LeveledLogger
 }
 
 func NewEventDispatcher(eventHandlers *EventResultHandlerMap) (Dispatcher, error) {
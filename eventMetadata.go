@@ -0,0 +1,129 @@
+package eventuate
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Keys of the event metadata EventMetadata exposes as fields of their own
+const (
+	EventMetadataCorrelationId = "correlationId"
+	EventMetadataCausationId   = "causationId"
+	EventMetadataUserId        = "userId"
+)
+
+// EventMetadataProvider supplies the metadata of the events written by a repository call,
+// e.g. the correlation id or the user of the request `ctx` belongs to
+type EventMetadataProvider func(ctx context.Context) map[string]string
+
+// CausedMetadata is the metadata of the events written while handling this event:
+// they share its correlation id (its own id, if it has none) and are caused by it
+func (meta *EventMetadata) CausedMetadata() map[string]string {
+	correlationId := meta.CorrelationId
+	if correlationId == "" {
+		correlationId = meta.Id.String()
+	}
+	result := map[string]string{
+		EventMetadataCorrelationId: correlationId,
+		EventMetadataCausationId:   meta.Id.String()}
+	if meta.UserId != "" {
+		result[EventMetadataUserId] = meta.UserId
+	}
+	return result
+}
+
+// callEventMetadata combines the metadata of the provider and the trace context with the one given to the call,
+// the latter wins
+func callEventMetadata(ctx context.Context, provider EventMetadataProvider, tracer Tracer, explicit map[string]string) (*string, error) {
+	headers := make(map[string]string)
+	if provider != nil {
+		for key, value := range provider(ctx) {
+			headers[key] = value
+		}
+	}
+	if tracer != nil {
+		tracer.Inject(ctx, headers)
+	}
+	for key, value := range explicit {
+		headers[key] = value
+	}
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	metadata, err := json.Marshal(headers)
+	if err != nil {
+		return nil, AppError("Cannot serialize event metadata, json Error: %v", err)
+	}
+	result := string(metadata)
+	return &result, nil
+}
+
+// withEventMetadata returns copies of the events with `metadata` (the EventMetadata of the Crud options)
+// added to their own, the keys of the event take precedence
+func withEventMetadata(events []EventTypeAndData, metadata *string) ([]EventTypeAndData, error) {
+	if metadata == nil || *metadata == "" {
+		return events, nil
+	}
+	headers, err := parseEventMetadata(*metadata)
+	if err != nil {
+		return nil, AppError("Invalid event metadata `%s`: %v", *metadata, err)
+	}
+
+	result := make([]EventTypeAndData, len(events))
+	for idx, event := range events {
+		own, err := parseEventMetadata(event.Metadata)
+		if err != nil {
+			return nil, AppError("Invalid metadata of Event of type `%s`: %v", event.EventType, err)
+		}
+		merged := make(map[string]string, len(headers)+len(own))
+		for key, value := range headers {
+			merged[key] = value
+		}
+		for key, value := range own {
+			merged[key] = value
+		}
+		serialized, err := json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+		result[idx] = event
+		result[idx].Metadata = string(serialized)
+	}
+	return result, nil
+}
+
+func parseEventMetadata(metadata string) (map[string]string, error) {
+	fields := make(map[string]string)
+	if metadata == "" {
+		return fields, nil
+	}
+	if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func eventMetadataValue(metadata, key string) (string, bool, error) {
+	fields, err := parseEventMetadata(metadata)
+	if err != nil {
+		return "", false, err
+	}
+	value, hasValue := fields[key]
+	return value, hasValue, nil
+}
+
+func setEventMetadata(metadata, key, value string) (string, error) {
+	fields, err := parseEventMetadata(metadata)
+	if err != nil {
+		return "", err
+	}
+	fields[key] = value
+	result, err := json.Marshal(fields)
+	return string(result), err
+}
+
+// SetEventMetadataProvider sets the provider of the repositories made with this client
+func (rest *RESTClient) SetEventMetadataProvider(provider EventMetadataProvider) {
+	rest.metadata = provider
+}
@@ -0,0 +1,104 @@
+package eventuate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+type userKey struct{}
+
+func TestAggregateRepository_EventMetadata(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+
+	crud := eventuate.NewInMemoryCrud()
+	repo := eventuate.NewAggregateRepository(crud, meta).
+		SetUpcasters(newCounterUpcasters()).
+		SetEventMetadataProvider(func(ctx context.Context) map[string]string {
+			userId, _ := ctx.Value(userKey{}).(string)
+			return map[string]string{
+				eventuate.EventMetadataUserId:        userId,
+				eventuate.EventMetadataCorrelationId: "request-1"}
+		})
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+
+	ctx := context.WithValue(context.Background(), userKey{}, "zaphod")
+	saved, err := repo.SaveCtx(ctx, &IncrementCommand{Amount: 2}, nil)
+	assertNoError(t, err)
+
+	_, err = repo.UpdateCtx(ctx, saved.EntityId, &IncrementCommand{Amount: 3}, &eventuate.UpdateOptions{
+		EventMetadata: map[string]string{
+			eventuate.EventMetadataCorrelationId: "request-2",
+			"tenant":                             "heart-of-gold"}})
+	assertNoError(t, err)
+
+	loaded, err := crud.Find(COUNTER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	if assert.Len(t, loaded.Events, 2) {
+		assert.JSONEq(t, `{"userId":"zaphod","correlationId":"request-1","schemaVersion":"2"}`, loaded.Events[0].Metadata)
+		assert.JSONEq(t, `{"userId":"zaphod","correlationId":"request-2","tenant":"heart-of-gold","schemaVersion":"2"}`,
+			loaded.Events[1].Metadata)
+	}
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, 5, found.EntityInstance.(*CounterAggregate).Total)
+}
+
+func TestEventMetadata_CausedMetadata(t *testing.T) {
+	_, meta := eventuate.NewEventMetadataFromStomp(&eventuate.StompEvent{
+		Id:         eventuate.Int128FromString(EVENT_ID_2),
+		EventType:  COUNTER_INCREMENTED,
+		EventData:  `{"Amount":1}`,
+		Metadata:   `{"correlationId":"request-1","userId":"zaphod","tenant":"heart-of-gold"}`,
+		EntityType: COUNTER_ENTITY}, eventuate.NewTypeHintsMap())
+
+	assert.Equal(t, "request-1", meta.CorrelationId)
+	assert.Equal(t, "zaphod", meta.UserId)
+	assert.Equal(t, "heart-of-gold", meta.Headers["tenant"])
+	assert.Equal(t, map[string]string{
+		eventuate.EventMetadataCorrelationId: "request-1",
+		eventuate.EventMetadataCausationId:   EVENT_ID_2,
+		eventuate.EventMetadataUserId:        "zaphod"}, meta.CausedMetadata())
+}
+
+func TestSubscribeAndDispatch_EventMetadata(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	repoClient := buildREST(t, srv.ClientBuilder())
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}))
+
+	received := make(chan *eventuate.EventMetadata, 1)
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			received <- meta
+			return future.NewSuccess(true)
+		})
+
+	_, err := stomp.SubscribeAndDispatch("metadata-subscriber", handlers, nil, false)
+	assert.Nil(t, err)
+
+	metadata := `{"correlationId":"request-42","userId":"trillian"}`
+	saved, err := repoClient.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Trillian"}`}}, &eventuate.AggregateCrudSaveOptions{
+		EventMetadata: &metadata})
+	assert.Nil(t, err)
+
+	loaded, err := repoClient.Find(ENTITY_TYPE, saved.EntityId, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, loaded.Events, 1) {
+		assert.JSONEq(t, metadata, loaded.Events[0].Metadata)
+	}
+
+	meta := receive(t, received)
+	assert.Equal(t, "request-42", meta.CorrelationId)
+	assert.Equal(t, "trillian", meta.UserId)
+}
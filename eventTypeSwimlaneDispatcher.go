@@ -1,86 +1,271 @@
 package eventuate
 
 import (
+	"hash/fnv"
+	"sync"
+
 	"github.com/eventuate-clients/eventuate-client-golang/future"
 	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
 )
 
+// DefaultSwimlaneQueueSize is the number of events a lane queues when SwimlaneOptions.QueueSize is not set
+const DefaultSwimlaneQueueSize = 16
+
+// SwimlaneOptions configures the lanes of an EventTypeSwimlaneDispatcher.
+// The events of a lane are handled one at a time, in the order they were dispatched.
+type SwimlaneOptions struct {
+	// Lanes partitions the events by the hash of their entity into that many lanes, shared by the entity types.
+	// The lanes are the swimlanes of the server, per entity type, when zero.
+	Lanes int
+	// MaxInFlight bounds the events queued or being handled over all lanes, Dispatch blocks when it is reached.
+	// Unbounded when zero.
+	MaxInFlight int
+	QueueSize   int
+}
+
 type eventPack struct {
 	eventData interface{}
 	eventMeta *EventMetadata
 	fr        *future.Result
 }
 
-type eventLane chan eventPack
+// eventLane queues the events of a lane. Its lock is held while queueing, Close must not close the queue meanwhile.
+type eventLane struct {
+	sync.Mutex
+	queue  chan eventPack
+	closed bool
+}
+
+// laneKey identifies a lane, `entityType` is empty for the lanes partitioned by entity
+type laneKey struct {
+	entityType string
+	swimlane   int
+}
 
 type EventTypeSwimlaneDispatcher struct {
 	EventDispatcher
-	queues map[string]map[int]eventLane
-	ll     loglib.LogLevelEnum
-	lg     loglib.Logger
+	sync.Mutex
+	options      SwimlaneOptions
+	queues       map[laneKey]*eventLane
+	inFlight     chan struct{}
+	closing      chan struct{}
+	closed       bool
+	lanes        sync.WaitGroup
+	done         chan struct{}
+	metrics      MetricsRecorder
+	subscriberId string
+	ll           loglib.LogLevelEnum
+	lg           loglib.LeveledLogger
 }
 
 func NewEventTypeSwimlaneDispatcher(eventHandlers *EventResultHandlerMap) (Dispatcher, error) {
+	return NewEventTypeSwimlaneDispatcherWithOptions(eventHandlers, SwimlaneOptions{})
+}
+
+func NewEventTypeSwimlaneDispatcherWithOptions(eventHandlers *EventResultHandlerMap, options SwimlaneOptions) (Dispatcher, error) {
+	if options.Lanes < 0 || options.MaxInFlight < 0 || options.QueueSize < 0 {
+		return nil, AppError("NewEventTypeSwimlaneDispatcher: negative option in %+v", options)
+	}
+	if options.QueueSize == 0 {
+		options.QueueSize = DefaultSwimlaneQueueSize
+	}
+
 	result := &EventTypeSwimlaneDispatcher{
 		EventDispatcher: EventDispatcher{
 			handlers: eventHandlers,
 		},
-		queues: make(map[string]map[int]eventLane),
-		lg:     loglib.NewNilLogger()}
+		options: options,
+		queues:  make(map[laneKey]*eventLane),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+		metrics: NoopMetricsRecorder{},
+		lg:      loglib.NewNilLogger()}
+	if options.MaxInFlight > 0 {
+		result.inFlight = make(chan struct{}, options.MaxInFlight)
+	}
 	return result, nil
 }
 
+// SwimlaneDispatcherMaker makes the DispatcherMaker of the swimlane dispatchers configured by `options`
+func SwimlaneDispatcherMaker(options SwimlaneOptions) DispatcherMaker {
+	return func(eventHandlers *EventResultHandlerMap) (Dispatcher, error) {
+		return NewEventTypeSwimlaneDispatcherWithOptions(eventHandlers, options)
+	}
+}
+
 func (dsp *EventTypeSwimlaneDispatcher) SetLogLevel(level loglib.LogLevelEnum) *EventTypeSwimlaneDispatcher {
 	dsp.ll = level
 	dsp.lg = loglib.NewLogger(level)
 	return dsp
 }
 
-func (dsp *EventTypeSwimlaneDispatcher) Dispatch(data interface{}, evt *EventMetadata) future.Settler {
-	swimlaneQ, haveSwimalneQ := dsp.queues[evt.EntityType]
-	if !haveSwimalneQ {
-		swimlaneQ = make(map[int]eventLane)
-		dsp.queues[evt.EntityType] = swimlaneQ
+func (dsp *EventTypeSwimlaneDispatcher) SetLogger(lg loglib.LeveledLogger) *EventTypeSwimlaneDispatcher {
+	dsp.lg = lg
+	return dsp
+}
+
+// SetMetricsRecorder makes the dispatcher report the depth of its swimlane queues as the ones of `subscriberId`
+func (dsp *EventTypeSwimlaneDispatcher) SetMetricsRecorder(recorder MetricsRecorder, subscriberId string) *EventTypeSwimlaneDispatcher {
+	dsp.metrics = metricsOrNoop(recorder)
+	dsp.subscriberId = subscriberId
+	return dsp
+}
+
+func (dsp *EventTypeSwimlaneDispatcher) laneOf(evt *EventMetadata) laneKey {
+	if dsp.options.Lanes == 0 {
+		return laneKey{
+			entityType: evt.EntityType,
+			swimlane:   evt.SwimLane}
 	}
 
-	q, haveQ := dsp.queues[evt.EntityType][evt.SwimLane]
-	if !haveQ {
-		q = make(eventLane, 16)
-		dsp.queues[evt.EntityType][evt.SwimLane] = q
-
-		go func() {
-			defer func() {
-				close(q)
-				delete(dsp.queues[evt.EntityType], evt.SwimLane)
-			}()
-			for {
-				pack := <-q
-				fr := pack.fr
-				meta := pack.eventMeta
-				data := pack.eventData
-				if fr == nil || evt == nil {
-					return
-				}
-
-				dsp.lg.Println("go EventTypeSwimlaneDispatcher.Dispatch (go). Before calling a handler for EntityType-EventType", evt.EntityType, evt.EventType)
-				rslt := dsp.EventDispatcher.Dispatch(data, meta)
-				dsp.lg.Println("go EventTypeSwimlaneDispatcher.Dispatch (go). FR await ", rslt, " => ", *fr)
-				value, err := rslt.GetValue() // blocking here
-				dsp.lg.Println("go EventTypeSwimlaneDispatcher.Dispatch (go). Result ", rslt, " received ")
-				(*fr).Settle(value, err)
-				dsp.lg.Println("go EventTypeSwimlaneDispatcher.Dispatch (go). resuming loop")
-			}
-		}()
+	hash := fnv.New32a()
+	hash.Write([]byte(evt.EntityType))
+	hash.Write([]byte{0})
+	hash.Write([]byte(evt.EntityId.String()))
+	return laneKey{
+		swimlane: int(hash.Sum32() % uint32(dsp.options.Lanes))}
+}
+
+func (dsp *EventTypeSwimlaneDispatcher) Dispatch(data interface{}, evt *EventMetadata) future.Settler {
+	if dsp.inFlight != nil {
+		select {
+		case dsp.inFlight <- struct{}{}:
+		case <-dsp.closing:
+			return future.NewFailure(ErrDispatcherClosed)
+		}
 	}
 
+	key := dsp.laneOf(evt)
 	result := future.NewResult()
-	result.SetLogLevel(dsp.ll)
-	dsp.lg.Println("EventTypeSwimlaneDispatcher.Dispatch. New: ", result)
+	result.SetLogger(dsp.lg)
+
+	lane := dsp.lane(key)
+	if lane == nil {
+		dsp.release()
+		return future.NewFailure(ErrDispatcherClosed)
+	}
+
+	// only the lane is locked while its queue is full, the other lanes keep being dispatched to
+	lane.Lock()
+	defer lane.Unlock()
 
-	q <- eventPack{
-		eventData:data,
+	if lane.closed {
+		dsp.release()
+		return future.NewFailure(ErrDispatcherClosed)
+	}
+
+	dsp.lg.Debug("Queueing event", loglib.KeyEntityType, evt.EntityType, loglib.KeySwimlane, key.swimlane,
+		loglib.KeyEventId, evt.Id)
+
+	select {
+	case lane.queue <- eventPack{
+		eventData: data,
 		eventMeta: evt,
-		fr:    result}
+		fr:        result}:
+	case <-dsp.closing:
+		dsp.release()
+		return future.NewFailure(ErrDispatcherClosed)
+	}
+	dsp.metrics.SetSwimlaneQueueDepth(dsp.subscriberId, key.entityType, key.swimlane, len(lane.queue))
 
 	return future.Settler(result)
 }
+
+// lane is the lane of `key`, started on its first event. Nil once the dispatcher is closed.
+func (dsp *EventTypeSwimlaneDispatcher) lane(key laneKey) *eventLane {
+	dsp.Lock()
+	defer dsp.Unlock()
+
+	if dsp.closed {
+		return nil
+	}
+
+	lane, haveLane := dsp.queues[key]
+	if !haveLane {
+		lane = &eventLane{
+			queue: make(chan eventPack, dsp.options.QueueSize)}
+		dsp.queues[key] = lane
+
+		dsp.lanes.Add(1)
+		go dsp.runLane(key, lane.queue)
+	}
+	return lane
+}
+
+// runLane handles the events of a lane until the dispatcher is closed, the events still queued then are failed
+func (dsp *EventTypeSwimlaneDispatcher) runLane(key laneKey, q <-chan eventPack) {
+	defer dsp.lanes.Done()
+
+	for pack := range q {
+		dsp.metrics.SetSwimlaneQueueDepth(dsp.subscriberId, key.entityType, key.swimlane, len(q))
+
+		meta := pack.eventMeta
+		select {
+		case <-dsp.closing:
+			pack.fr.Settle(nil, ErrDispatcherClosed)
+			dsp.release()
+			continue
+		default:
+		}
+
+		lg := dsp.lg.With(loglib.KeyEntityType, meta.EntityType, loglib.KeySwimlane, key.swimlane,
+			loglib.KeyEventType, meta.EventType, loglib.KeyEventId, meta.Id)
+		lg.Debug("Calling the event handler")
+		value, err := dsp.handle(pack)
+		if err != nil {
+			lg.Debug("Event handler failed", loglib.KeyError, err)
+		} else {
+			lg.Debug("Event handler succeeded")
+		}
+		pack.fr.Settle(value, err)
+		dsp.release()
+	}
+}
+
+// handle calls the handler of the event and waits for its result, a panic of the handler is its failure
+func (dsp *EventTypeSwimlaneDispatcher) handle(pack eventPack) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = AppError("Recovered in event handler: %#v", r)
+		}
+	}()
+	rslt := dsp.EventDispatcher.Dispatch(pack.eventData, pack.eventMeta)
+	return rslt.GetValue() // blocking here
+}
+
+func (dsp *EventTypeSwimlaneDispatcher) release() {
+	if dsp.inFlight != nil {
+		<-dsp.inFlight
+	}
+}
+
+// Close stops the lanes once their current events are handled, the events still queued and the ones dispatched
+// afterwards fail with ErrDispatcherClosed. The subscriptions close their dispatchers on unsubscribing.
+func (dsp *EventTypeSwimlaneDispatcher) Close() {
+	dsp.Lock()
+	if dsp.closed {
+		dsp.Unlock()
+		return
+	}
+	dsp.closed = true
+	// unblocks the Dispatch calls waiting for room in a lane, before their lane is locked
+	close(dsp.closing)
+	for key, lane := range dsp.queues {
+		lane.Lock()
+		lane.closed = true
+		close(lane.queue)
+		lane.Unlock()
+		dsp.metrics.SetSwimlaneQueueDepth(dsp.subscriberId, key.entityType, key.swimlane, 0)
+	}
+	dsp.Unlock()
+
+	go func() {
+		dsp.lanes.Wait()
+		close(dsp.done)
+	}()
+}
+
+// Done is closed once the dispatcher is closed and its lanes are stopped
+func (dsp *EventTypeSwimlaneDispatcher) Done() <-chan struct{} {
+	return dsp.done
+}
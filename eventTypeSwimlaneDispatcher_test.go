@@ -1,11 +1,16 @@
 package eventuate_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
 	"github.com/eventuate-clients/eventuate-client-golang/future"
 	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+	"github.com/stretchr/testify/assert"
 	"math"
+	"math/rand"
+	"sync"
 	"testing"
 	"time"
 )
@@ -170,6 +175,218 @@ func TestEventTypeSwimlaneDispatcher_Dispatch_4(t *testing.T) {
 
 }
 
+func TestEventTypeSwimlaneDispatcher_QueueDepth(t *testing.T) {
+	release := make(chan struct{})
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(AGG_TYPE, EVT_TYPE_A,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			<-release
+			return future.NewSuccess(data)
+		})
+	dispatcher, _ := eventuate.NewEventTypeSwimlaneDispatcher(handlers)
+	recorder := eventuatetest.NewMetricsRecorder()
+	dispatcher.(*eventuate.EventTypeSwimlaneDispatcher).SetMetricsRecorder(recorder, "subscriber")
+
+	results := make([]future.Settler, 3)
+	for idx := range results {
+		evt := newEvent(AGG_TYPE, EVT_TYPE_A, idx, 1)
+		results[idx] = dispatcher.Dispatch(evt.data, evt.meta)
+	}
+
+	// the first event is taken off the queue by the blocked handler, at most
+	eventually(t, func() bool {
+		return recorder.SwimlaneQueueDepth("subscriber", AGG_TYPE, 1) == 2
+	})
+	if depth := recorder.SwimlaneQueueDepth("subscriber", AGG_TYPE, 1); depth != 2 {
+		t.Errorf("Queue depth %d, expected 2", depth)
+	}
+
+	close(release)
+	for _, result := range results {
+		result.GetValue()
+	}
+	if depth := recorder.SwimlaneQueueDepth("subscriber", AGG_TYPE, 1); depth != 0 {
+		t.Errorf("Queue depth %d, expected 0", depth)
+	}
+}
+
+func TestEventTypeSwimlaneDispatcher_EntityLanes(t *testing.T) {
+	var (
+		mutex       sync.Mutex
+		handled     = make(map[eventuate.Int128][]int)
+		running     int
+		maxRunning  int
+		entityCount = 6
+		eventCount  = 5
+	)
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(AGG_TYPE, EVT_TYPE_A,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+			mutex.Lock()
+			running--
+			handled[meta.EntityId] = append(handled[meta.EntityId], data.(int))
+			mutex.Unlock()
+			return future.NewSuccess(data)
+		})
+	dispatcher, err := eventuate.NewEventTypeSwimlaneDispatcherWithOptions(handlers, eventuate.SwimlaneOptions{Lanes: 3})
+	assert.Nil(t, err)
+
+	entityIds := make([]eventuate.Int128, entityCount)
+	for idx := range entityIds {
+		entityIds[idx] = eventuate.Int128Random()
+	}
+	var results []future.Settler
+	for seq := 0; seq < eventCount; seq++ {
+		for _, entityId := range entityIds {
+			evt := newEvent(AGG_TYPE, EVT_TYPE_A, seq, 1)
+			evt.meta.EntityId = entityId
+			results = append(results, dispatcher.Dispatch(evt.data, evt.meta))
+		}
+	}
+	for _, result := range results {
+		_, err := result.GetValue()
+		assert.Nil(t, err)
+	}
+
+	// in order per entity, one event per lane at a time
+	for _, entityId := range entityIds {
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, handled[entityId])
+	}
+	assert.True(t, maxRunning <= 3, "%d events handled at once over 3 lanes", maxRunning)
+}
+
+func TestEventTypeSwimlaneDispatcher_MaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(AGG_TYPE, EVT_TYPE_A,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			<-release
+			return future.NewSuccess(data)
+		})
+	dispatcher, _ := eventuate.NewEventTypeSwimlaneDispatcherWithOptions(handlers,
+		eventuate.SwimlaneOptions{Lanes: 4, MaxInFlight: 2})
+
+	dispatched := make(chan future.Settler, 3)
+	go func() {
+		for idx := 0; idx < 3; idx++ {
+			evt := newEvent(AGG_TYPE, EVT_TYPE_A, idx, 1)
+			evt.meta.EntityId = eventuate.Int128Random()
+			dispatched <- dispatcher.Dispatch(evt.data, evt.meta)
+		}
+	}()
+
+	first, second := <-dispatched, <-dispatched
+	select {
+	case <-dispatched:
+		t.Fatal("a third event was dispatched while two were in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	third := <-dispatched
+	for _, result := range []future.Settler{first, second, third} {
+		_, err := result.GetValue()
+		assert.Nil(t, err)
+	}
+}
+
+func TestEventTypeSwimlaneDispatcher_Close(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(AGG_TYPE, EVT_TYPE_A,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			started <- struct{}{}
+			<-release
+			return future.NewSuccess(data)
+		})
+	dispatcher, _ := eventuate.NewEventTypeSwimlaneDispatcher(handlers)
+	swimlaneDispatcher := dispatcher.(*eventuate.EventTypeSwimlaneDispatcher)
+
+	results := make([]future.Settler, 3)
+	for idx := range results {
+		evt := newEvent(AGG_TYPE, EVT_TYPE_A, idx, 1)
+		results[idx] = dispatcher.Dispatch(evt.data, evt.meta)
+	}
+	<-started
+	swimlaneDispatcher.Close()
+	close(release)
+
+	// the event being handled completes, the queued ones are dropped
+	_, err := results[0].GetValue()
+	assert.Nil(t, err)
+	for _, result := range results[1:] {
+		_, err := result.GetValue()
+		assert.True(t, errors.Is(err, eventuate.ErrDispatcherClosed))
+	}
+
+	select {
+	case <-swimlaneDispatcher.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lanes were not stopped")
+	}
+
+	evt := newEvent(AGG_TYPE, EVT_TYPE_A, 3, 1)
+	_, err = dispatcher.Dispatch(evt.data, evt.meta).GetValue()
+	assert.True(t, errors.Is(err, eventuate.ErrDispatcherClosed))
+}
+
+func TestEventTypeSwimlaneDispatcher_FullLane(t *testing.T) {
+	release := make(chan struct{})
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(AGG_TYPE, EVT_TYPE_A,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			<-release
+			return future.NewSuccess(data)
+		})
+	dispatcher, _ := eventuate.NewEventTypeSwimlaneDispatcherWithOptions(handlers,
+		eventuate.SwimlaneOptions{QueueSize: 1})
+	swimlaneDispatcher := dispatcher.(*eventuate.EventTypeSwimlaneDispatcher)
+
+	// the lane is full, the last event waits for room
+	dispatched := make(chan future.Settler, 3)
+	go func() {
+		for idx := 0; idx < 3; idx++ {
+			evt := newEvent(AGG_TYPE, EVT_TYPE_A, idx, 1)
+			dispatched <- dispatcher.Dispatch(evt.data, evt.meta)
+		}
+	}()
+	<-dispatched
+
+	// the other lanes are not held up meanwhile
+	other := make(chan future.Settler, 1)
+	go func() {
+		evt := newEvent(AGG_TYPE, EVT_TYPE_A, "other", 2)
+		other <- dispatcher.Dispatch(evt.data, evt.meta)
+	}()
+	select {
+	case <-other:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatching to another lane blocked on the full lane")
+	}
+
+	// closing does not wait for room either
+	swimlaneDispatcher.Close()
+	close(release)
+	var closedErrs int
+	for idx := 0; idx < 2; idx++ {
+		if _, err := (<-dispatched).GetValue(); errors.Is(err, eventuate.ErrDispatcherClosed) {
+			closedErrs++
+		}
+	}
+	assert.True(t, closedErrs >= 1, "the waiting event was not failed")
+
+	select {
+	case <-swimlaneDispatcher.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lanes were not stopped")
+	}
+}
+
 func getCommonDispatcher(eventCount int, sleepA, sleepB int) eventuate.Dispatcher {
 	var (
 		wgCount int
@@ -0,0 +1,111 @@
+package eventuatetest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+)
+
+// MetricsRecorder is an eventuate.MetricsRecorder that keeps the counters and the last value of the gauges in memory
+type MetricsRecorder struct {
+	sync.Mutex
+	counters map[string]int
+	gauges   map[string]int
+}
+
+var _ eventuate.MetricsRecorder = (*MetricsRecorder)(nil)
+
+func NewMetricsRecorder() *MetricsRecorder {
+	return &MetricsRecorder{
+		counters: make(map[string]int),
+		gauges:   make(map[string]int)}
+}
+
+func (rec *MetricsRecorder) ObserveRequest(operation, status string, duration time.Duration) {
+	rec.add("request", 1, operation, status)
+}
+
+func (rec *MetricsRecorder) CountConflict(operation, conflict string) {
+	rec.add("conflict", 1, operation, conflict)
+}
+
+func (rec *MetricsRecorder) CountRetry(operation string) {
+	rec.add("retry", 1, operation)
+}
+
+func (rec *MetricsRecorder) CountEventsReceived(subscriberId string, count int) {
+	rec.add("received", count, subscriberId)
+}
+
+func (rec *MetricsRecorder) CountEventsAcked(subscriberId string, count int) {
+	rec.add("acked", count, subscriberId)
+}
+
+func (rec *MetricsRecorder) CountEventsFailed(subscriberId string, count int) {
+	rec.add("failed", count, subscriberId)
+}
+
+func (rec *MetricsRecorder) SetPendingAcks(subscriberId string, count int) {
+	rec.set("pending", count, subscriberId)
+}
+
+func (rec *MetricsRecorder) SetSwimlaneQueueDepth(subscriberId, entityType string, swimlane, depth int) {
+	rec.set("depth", depth, subscriberId, entityType, swimlane)
+}
+
+// Requests is the number of REST calls of `operation` that ended with `status`
+func (rec *MetricsRecorder) Requests(operation, status string) int {
+	return rec.get(rec.counters, "request", operation, status)
+}
+
+func (rec *MetricsRecorder) Conflicts(operation, conflict string) int {
+	return rec.get(rec.counters, "conflict", operation, conflict)
+}
+
+func (rec *MetricsRecorder) Retries(operation string) int {
+	return rec.get(rec.counters, "retry", operation)
+}
+
+func (rec *MetricsRecorder) EventsReceived(subscriberId string) int {
+	return rec.get(rec.counters, "received", subscriberId)
+}
+
+func (rec *MetricsRecorder) EventsAcked(subscriberId string) int {
+	return rec.get(rec.counters, "acked", subscriberId)
+}
+
+func (rec *MetricsRecorder) EventsFailed(subscriberId string) int {
+	return rec.get(rec.counters, "failed", subscriberId)
+}
+
+func (rec *MetricsRecorder) PendingAcks(subscriberId string) int {
+	return rec.get(rec.gauges, "pending", subscriberId)
+}
+
+func (rec *MetricsRecorder) SwimlaneQueueDepth(subscriberId, entityType string, swimlane int) int {
+	return rec.get(rec.gauges, "depth", subscriberId, entityType, swimlane)
+}
+
+func (rec *MetricsRecorder) add(name string, count int, labels ...interface{}) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.counters[metricKey(name, labels)] += count
+}
+
+func (rec *MetricsRecorder) set(name string, value int, labels ...interface{}) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.gauges[metricKey(name, labels)] = value
+}
+
+func (rec *MetricsRecorder) get(values map[string]int, name string, labels ...interface{}) int {
+	rec.Lock()
+	defer rec.Unlock()
+	return values[metricKey(name, labels)]
+}
+
+func metricKey(name string, labels []interface{}) string {
+	return fmt.Sprint(name, labels)
+}
@@ -0,0 +1,188 @@
+package eventuatetest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+)
+
+type createRequest struct {
+	EntityTypeName       string                       `json:"entityTypeName"`
+	Events               []eventuate.EventTypeAndData `json:"events"`
+	EntityId             *eventuate.Int128            `json:"entityId"`
+	TriggeringEventToken *eventuate.EventContext      `json:"triggeringEventToken"`
+}
+
+type updateRequest struct {
+	Events               []eventuate.EventTypeAndData  `json:"events"`
+	EntityVersion        eventuate.Int128              `json:"entityVersion"`
+	TriggeringEventToken *eventuate.EventContext       `json:"triggeringEventToken"`
+	Snapshot             *eventuate.SerializedSnapshot `json:"snapshot"`
+}
+
+type errorResponse struct {
+	Error       string `json:"Error,omitempty"` // the conflict code, under the key the server uses
+	Explanation string `json:"explanation"`
+}
+
+type restHandler struct {
+	srv *Server
+}
+
+func newRestHandler(srv *Server) http.Handler {
+	return &restHandler{
+		srv: srv}
+}
+
+// ServeHTTP routes `/entity/{space}` and `/entity/{space}/{entityType}/{entityId}`
+func (handler *restHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if user, password, hasAuth := req.BasicAuth(); !hasAuth || len(user) == 0 || len(password) == 0 {
+		handler.writeError(w, http.StatusUnauthorized, "", "Credentials are missing")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "entity" {
+		handler.writeError(w, http.StatusNotFound, "", "Unknown resource: "+req.URL.Path)
+		return
+	}
+
+	sp := handler.srv.getSpace(parts[1])
+
+	switch {
+	case len(parts) == 2 && req.Method == http.MethodPost:
+		handler.create(w, req, sp)
+
+	case len(parts) == 4 && req.Method == http.MethodGet:
+		handler.find(w, req, sp, parts[2], eventuate.Int128FromString(parts[3]))
+
+	case len(parts) == 4 && req.Method == http.MethodPost:
+		handler.update(w, req, sp, eventuate.EntityIdAndType{
+			EntityType: parts[2],
+			EntityId:   eventuate.Int128FromString(parts[3])})
+
+	default:
+		handler.writeError(w, http.StatusNotFound, "", "Unknown resource: "+req.Method+" "+req.URL.Path)
+	}
+}
+
+func (handler *restHandler) create(w http.ResponseWriter, req *http.Request, sp *space) {
+	var request createRequest
+	if !handler.readRequest(w, req, createRequestSchema, &request) {
+		return
+	}
+
+	options := &eventuate.AggregateCrudSaveOptions{
+		TriggeringEvent: request.TriggeringEventToken}
+	if request.EntityId != nil {
+		options.EntityId = *request.EntityId
+	}
+
+	sp.Lock()
+	result, err := sp.crud.Save(request.EntityTypeName, request.Events, options)
+	if err == nil {
+		sp.publish(request.EntityTypeName, result.EntityId, request.Events, result.EventIds)
+	}
+	sp.Unlock()
+
+	if err != nil {
+		handler.writeCrudError(w, err)
+		return
+	}
+	handler.writeResponse(w, createResponseSchema, eventuate.CreateResponse(*result))
+}
+
+func (handler *restHandler) find(w http.ResponseWriter, req *http.Request, sp *space, entityType string, entityId eventuate.Int128) {
+	options := &eventuate.AggregateCrudFindOptions{}
+	if token := req.URL.Query().Get("triggeringEventToken"); len(token) > 0 {
+		triggeringEvent := eventuate.EventContext(token)
+		options.TriggeringEvent = &triggeringEvent
+	}
+
+	result, err := sp.crud.Find(entityType, entityId, options)
+	if err != nil {
+		handler.writeCrudError(w, err)
+		return
+	}
+	handler.writeResponse(w, getResponseSchema, eventuate.GetResponse(*result))
+}
+
+func (handler *restHandler) update(w http.ResponseWriter, req *http.Request, sp *space, idAndType eventuate.EntityIdAndType) {
+	var request updateRequest
+	if !handler.readRequest(w, req, updateRequestSchema, &request) {
+		return
+	}
+
+	options := &eventuate.AggregateCrudUpdateOptions{
+		TriggeringEvent:    request.TriggeringEventToken,
+		SerializedSnapshot: request.Snapshot}
+
+	sp.Lock()
+	result, err := sp.crud.Update(idAndType, request.EntityVersion, request.Events, options)
+	if err == nil {
+		sp.publish(idAndType.EntityType, idAndType.EntityId, request.Events, result.EventIds)
+	}
+	sp.Unlock()
+
+	if err != nil {
+		handler.writeCrudError(w, err)
+		return
+	}
+	handler.writeResponse(w, updateResponseSchema, eventuate.UpdateResponse(*result))
+}
+
+func (handler *restHandler) readRequest(w http.ResponseWriter, req *http.Request, schema string, request interface{}) bool {
+	body, readErr := ioutil.ReadAll(req.Body)
+	if readErr != nil {
+		handler.writeError(w, http.StatusBadRequest, "", readErr.Error())
+		return false
+	}
+
+	if err := handler.srv.schemas.validateJson(schema, body); err != nil {
+		handler.writeError(w, http.StatusBadRequest, "", err.Error())
+		return false
+	}
+
+	if err := json.Unmarshal(body, request); err != nil {
+		handler.writeError(w, http.StatusBadRequest, "", err.Error())
+		return false
+	}
+	return true
+}
+
+func (handler *restHandler) writeCrudError(w http.ResponseWriter, err error) {
+	var crudErr *eventuate.RestError
+	if errors.As(err, &crudErr) {
+		handler.writeError(w, crudErr.HttpCode, crudErr.Conflict, err.Error())
+		return
+	}
+	handler.writeError(w, http.StatusInternalServerError, "", err.Error())
+}
+
+func (handler *restHandler) writeError(w http.ResponseWriter, status int, conflict, explanation string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Error:       conflict,
+		Explanation: explanation})
+}
+
+func (handler *restHandler) writeResponse(w http.ResponseWriter, schema string, response interface{}) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		handler.writeError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	if err := handler.srv.schemas.validateJson(schema, body); err != nil {
+		handler.writeError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,137 @@
+package eventuatetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	createRequestSchema     = "json_schemas/rest/create-request.json"
+	createResponseSchema    = "json_schemas/rest/create-response.json"
+	updateRequestSchema     = "json_schemas/rest/update-request.json"
+	updateResponseSchema    = "json_schemas/rest/update-response.json"
+	getResponseSchema       = "json_schemas/rest/get-response.json"
+	destinationHeaderSchema = "json_schema_test_suite/stomp/destination-header.json"
+	stompEventSchema        = "json_schema_test_suite/stomp/stomp-event.json"
+)
+
+// schemaExtensions are the properties the client sends beyond a schema shipped with it,
+// of the document and of each of its events
+type schemaExtensions struct {
+	document []string
+	event    []string
+}
+
+// clientExtensions are left out of the validation, the schemas describe the base protocol only
+var clientExtensions = map[string]schemaExtensions{
+	createRequestSchema: {
+		document: []string{"entityId", "triggeringEventToken"},
+		event:    []string{"metadata"}},
+	updateRequestSchema: {
+		document: []string{"snapshot"},
+		event:    []string{"metadata"}},
+	getResponseSchema: {
+		event: []string{"metadata"}},
+	stompEventSchema: {
+		document: []string{"metadata"}}}
+
+// schemaValidator checks the emulator traffic against the schemas shipped with the client
+type schemaValidator struct {
+	schemas map[string]*gojsonschema.Schema
+}
+
+func newSchemaValidator() (*schemaValidator, error) {
+	result := &schemaValidator{
+		schemas: make(map[string]*gojsonschema.Schema)}
+
+	_, sourceFile, _, ok := runtime.Caller(0)
+	if !ok {
+		return result, nil
+	}
+	rootDir := filepath.Dir(filepath.Dir(sourceFile))
+
+	for _, name := range []string{
+		createRequestSchema,
+		createResponseSchema,
+		updateRequestSchema,
+		updateResponseSchema,
+		getResponseSchema,
+		destinationHeaderSchema,
+		stompEventSchema} {
+
+		schemaPath := filepath.Join(rootDir, filepath.FromSlash(name))
+		if _, statErr := os.Stat(schemaPath); statErr != nil {
+			// schemas are not available outside of a source checkout, validation is skipped
+			continue
+		}
+
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(schemaPath)))
+		if err != nil {
+			return nil, fmt.Errorf("eventuatetest: cannot load schema %s: %v", name, err)
+		}
+		result.schemas[name] = schema
+	}
+
+	return result, nil
+}
+
+func (validator *schemaValidator) validateJson(name string, document []byte) error {
+	extensions, hasExtensions := clientExtensions[name]
+	if !hasExtensions {
+		return validator.validate(name, gojsonschema.NewStringLoader(string(document)))
+	}
+
+	var value map[string]interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		// reported by the validation
+		return validator.validate(name, gojsonschema.NewStringLoader(string(document)))
+	}
+	for _, property := range extensions.document {
+		delete(value, property)
+	}
+	if events, isList := value["events"].([]interface{}); isList {
+		for _, evt := range events {
+			if evtValue, isObject := evt.(map[string]interface{}); isObject {
+				for _, property := range extensions.event {
+					delete(evtValue, property)
+				}
+			}
+		}
+	}
+	return validator.validate(name, gojsonschema.NewGoLoader(value))
+}
+
+func (validator *schemaValidator) validateValue(name string, document interface{}) error {
+	body, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return validator.validateJson(name, body)
+}
+
+func (validator *schemaValidator) validate(name string, loader gojsonschema.JSONLoader) error {
+	schema, hasSchema := validator.schemas[name]
+	if !hasSchema {
+		return nil
+	}
+
+	result, err := schema.Validate(loader)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	if !result.Valid() {
+		messages := make([]string, len(result.Errors()))
+		for idx, resultErr := range result.Errors() {
+			messages[idx] = resultErr.String()
+		}
+		return fmt.Errorf("%s: %s", name, strings.Join(messages, "; "))
+	}
+	return nil
+}
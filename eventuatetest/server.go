@@ -0,0 +1,298 @@
+// Package eventuatetest provides a process-local emulator of the Eventuate server.
+// It speaks the same REST and STOMP protocols as api.eventuate.io, so REST and STOMP
+// clients built with eventuate.ClientBuilder() can be pointed at it in offline tests.
+package eventuatetest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	apiKeyId     = "eventuatetest"
+	apiKeySecret = "eventuatetest"
+	swimlanes    = 8
+
+	stompWebSocketPath     = "/stomp"
+	stompWebSocketProtocol = "v12.stomp"
+)
+
+// Server is an embedded Eventuate server emulator.
+// URL and StompURL are suitable for ClientBuilder's WithUrl and WithStompUrl,
+// StompWebSocketURL is the STOMP endpoint over WebSocket.
+type Server struct {
+	sync.Mutex
+	URL               string
+	StompURL          string
+	StompWebSocketURL string
+	httpServer        *httptest.Server
+	listener          net.Listener
+	schemas           *schemaValidator
+	spaces            map[string]*space
+	conns             map[*stompConnection]bool
+	closed            bool
+	wg                sync.WaitGroup
+}
+
+// NewServer starts the REST and STOMP endpoints on random local ports
+func NewServer() (*Server, error) {
+	return newServer(false)
+}
+
+// NewTLSServer is NewServer with both endpoints over TLS, see ClientTLSConfig
+func NewTLSServer() (*Server, error) {
+	return newServer(true)
+}
+
+func newServer(useTLS bool) (*Server, error) {
+	schemas, schemasErr := newSchemaValidator()
+	if schemasErr != nil {
+		return nil, schemasErr
+	}
+
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		return nil, listenErr
+	}
+
+	srv := &Server{
+		StompURL: fmt.Sprintf("stomp://%s", listener.Addr()),
+		listener: listener,
+		schemas:  schemas,
+		spaces:   make(map[string]*space),
+		conns:    make(map[*stompConnection]bool)}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", newRestHandler(srv))
+	mux.Handle(stompWebSocketPath, srv.stompWebSocketHandler())
+
+	srv.httpServer = httptest.NewUnstartedServer(mux)
+	if useTLS {
+		srv.httpServer.StartTLS()
+		srv.listener = tls.NewListener(listener, &tls.Config{
+			Certificates: srv.httpServer.TLS.Certificates})
+		srv.StompURL = fmt.Sprintf("stomp+ssl://%s", listener.Addr())
+		srv.StompWebSocketURL = fmt.Sprintf("wss://%s%s", srv.httpServer.Listener.Addr(), stompWebSocketPath)
+	} else {
+		srv.httpServer.Start()
+		srv.StompWebSocketURL = fmt.Sprintf("ws://%s%s", srv.httpServer.Listener.Addr(), stompWebSocketPath)
+	}
+	srv.URL = srv.httpServer.URL
+
+	srv.wg.Add(1)
+	go srv.acceptStompConnections()
+
+	return srv, nil
+}
+
+// ClientTLSConfig trusts the certificate of a server made with NewTLSServer
+func (srv *Server) ClientTLSConfig() *tls.Config {
+	roots := x509.NewCertPool()
+	if cert := srv.httpServer.Certificate(); cert != nil {
+		roots.AddCert(cert)
+	}
+	return &tls.Config{RootCAs: roots}
+}
+
+// ClientBuilder returns a builder already pointed at this server
+func (srv *Server) ClientBuilder() *eventuate.ClientBuilderInstance {
+	builder := eventuate.ClientBuilder().
+		WithUrl(srv.URL).
+		WithStompUrl(srv.StompURL).
+		WithCredentials(apiKeyId, apiKeySecret)
+	if srv.httpServer.TLS != nil {
+		builder.WithTLSConfig(srv.ClientTLSConfig()).
+			WithStompTLSConfig(srv.ClientTLSConfig())
+	}
+	return builder
+}
+
+// Close shuts both endpoints down and severs all STOMP connections
+func (srv *Server) Close() {
+	srv.Lock()
+	if srv.closed {
+		srv.Unlock()
+		return
+	}
+	srv.closed = true
+	conns := make([]*stompConnection, 0, len(srv.conns))
+	for conn := range srv.conns {
+		conns = append(conns, conn)
+	}
+	srv.Unlock()
+
+	srv.listener.Close()
+	for _, conn := range conns {
+		conn.close()
+	}
+	srv.httpServer.Close()
+	srv.wg.Wait()
+}
+
+// DropStompConnections severs the current STOMP connections, the endpoint keeps accepting new ones
+func (srv *Server) DropStompConnections() {
+	srv.Lock()
+	conns := make([]*stompConnection, 0, len(srv.conns))
+	for conn := range srv.conns {
+		conns = append(conns, conn)
+	}
+	srv.Unlock()
+
+	for _, conn := range conns {
+		conn.close()
+	}
+}
+
+// AwaitAcks waits until the subscription has sent all the acks it was asked for, up to `timeout`
+func AwaitAcks(sub *eventuate.Subscription, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for pending := sub.FetchPendingsCount(); pending != 0; pending = sub.FetchPendingsCount() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("eventuatetest: %d events not acknowledged within %v", pending, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func (srv *Server) getSpace(name string) *space {
+	if len(name) == 0 {
+		name = "default"
+	}
+
+	srv.Lock()
+	defer srv.Unlock()
+
+	result, hasSpace := srv.spaces[name]
+	if !hasSpace {
+		result = newSpace()
+		srv.spaces[name] = result
+	}
+	return result
+}
+
+func (srv *Server) acceptStompConnections() {
+	defer srv.wg.Done()
+	for {
+		netConn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		srv.wg.Add(1)
+		go func() {
+			defer srv.wg.Done()
+			srv.serveStompConnection(netConn)
+		}()
+	}
+}
+
+// serveStompConnection serves the STOMP frames of `netConn` until it is closed
+func (srv *Server) serveStompConnection(netConn net.Conn) {
+	conn := newStompConnection(srv, netConn)
+
+	srv.Lock()
+	if srv.closed {
+		srv.Unlock()
+		netConn.Close()
+		return
+	}
+	srv.conns[conn] = true
+	srv.Unlock()
+
+	conn.serve()
+
+	srv.Lock()
+	delete(srv.conns, conn)
+	srv.Unlock()
+}
+
+// stompWebSocketHandler serves STOMP over the WebSocket connections, in the `v12.stomp` subprotocol
+func (srv *Server) stompWebSocketHandler() http.Handler {
+	return websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			for _, protocol := range config.Protocol {
+				if protocol == stompWebSocketProtocol {
+					config.Protocol = []string{protocol}
+					return nil
+				}
+			}
+			config.Protocol = nil
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			srv.serveStompConnection(ws)
+		}}
+}
+
+// space is the per-namespace event store together with its event log for subscribers
+type space struct {
+	sync.Mutex
+	cond        *sync.Cond
+	crud        *eventuate.InMemoryCrud
+	log         []eventuate.StompEvent
+	offsets     map[int]int
+	subscribers map[string]*subscriberState
+}
+
+type subscriberState struct {
+	acked map[eventuate.Int128]bool
+	// log position new subscriptions start reading from
+	startCursor int
+}
+
+func newSpace() *space {
+	result := &space{
+		crud:        eventuate.NewInMemoryCrud(),
+		offsets:     make(map[int]int),
+		subscribers: make(map[string]*subscriberState)}
+	result.cond = sync.NewCond(&result.Mutex)
+	return result
+}
+
+// publish appends persisted events to the log. Must be called with the space locked.
+func (sp *space) publish(entityType string, entityId eventuate.Int128, events []eventuate.EventTypeAndData, eventIds []eventuate.Int128) {
+	swimlane := int((entityId.FirstPart() ^ entityId.LastPart()) % swimlanes)
+	for idx, event := range events {
+		sp.offsets[swimlane]++
+		sp.log = append(sp.log, eventuate.StompEvent{
+			Id:         eventIds[idx],
+			EventType:  event.EventType,
+			EventData:  event.EventData,
+			Metadata:   event.Metadata,
+			EntityId:   entityId,
+			EntityType: entityType,
+			EventToken: eventIds[idx].String(),
+			Swimlane:   swimlane,
+			Offset:     sp.offsets[swimlane]})
+	}
+	sp.cond.Broadcast()
+}
+
+// getSubscriber returns the state of a subscriber. Durable subscribers keep their state
+// between subscriptions, transient ones start afresh. Must be called with the space locked.
+func (sp *space) getSubscriber(subscriberId string, options *eventuate.SubscriptionRequestOptions) *subscriberState {
+	isTransient := options != nil && options.Durability == eventuate.TRANSIENT.String()
+
+	state, hasState := sp.subscribers[subscriberId]
+	if !hasState || isTransient {
+		state = &subscriberState{
+			acked: make(map[eventuate.Int128]bool)}
+		if options != nil && options.ReadFrom == eventuate.END.String() {
+			state.startCursor = len(sp.log)
+		}
+		if !isTransient {
+			sp.subscribers[subscriberId] = state
+		}
+	}
+	return state
+}
@@ -0,0 +1,458 @@
+package eventuatetest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/gmallard/stompngo"
+	"github.com/stretchr/testify/assert"
+)
+
+const ENTITY_TYPE = "net.chrisrichardson.eventstore.example.MyEntity"
+const EVENT_CREATED = "net.chrisrichardson.eventstore.example.MyEntityWasCreated"
+const EVENT_CHANGED = "net.chrisrichardson.eventstore.example.MyEntityNameChanged"
+
+type MyEntityWasCreatedEvent struct {
+	Name string `json:"name"`
+}
+
+func newServer(t *testing.T) *eventuatetest.Server {
+	srv, err := eventuatetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestServer_REST(t *testing.T) {
+	srv := newServer(t)
+	defer srv.Close()
+
+	client, err := srv.ClientBuilder().WithSpace("rest").BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := client.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Arthur Dent"}`}}, nil)
+	assert.Nil(t, err)
+
+	idAndType := eventuate.EntityIdAndType{
+		EntityType: ENTITY_TYPE,
+		EntityId:   created.EntityId}
+	changes := []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CHANGED,
+			EventData: `{"name":"Zaphod Beeblebrox"}`}}
+
+	updated, err := client.Update(idAndType, created.EntityVersion, changes, nil)
+	assert.Nil(t, err)
+
+	_, err = client.Update(idAndType, created.EntityVersion, changes, nil)
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "optimistic locking"), err.Error())
+	}
+
+	loaded, err := client.Find(ENTITY_TYPE, created.EntityId, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, loaded.Events, 2) {
+		assert.Equal(t, updated.EntityVersion, loaded.Events[1].EventId)
+		assert.Equal(t, EVENT_CHANGED, loaded.Events[1].EventType)
+	}
+
+	triggeringEvent := eventuate.EventContext(updated.EventIds[0].String())
+	_, err = client.Save(ENTITY_TYPE, changes, &eventuate.AggregateCrudSaveOptions{
+		TriggeringEvent: &triggeringEvent})
+	assert.Nil(t, err)
+
+	_, err = client.Save(ENTITY_TYPE, changes, &eventuate.AggregateCrudSaveOptions{
+		TriggeringEvent: &triggeringEvent})
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "duplicate triggering Event"), err.Error())
+	}
+
+	_, err = client.Find(ENTITY_TYPE, eventuate.Int128FromString("0-1"), nil)
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "Resource is not found"), err.Error())
+	}
+}
+
+func TestServer_STOMPRedelivery(t *testing.T) {
+	srv := newServer(t)
+	defer srv.Close()
+
+	rest, err := srv.ClientBuilder().BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stomp, err := srv.ClientBuilder().BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Arthur Dent"}`}}, nil)
+	assert.Nil(t, err)
+
+	subscribe := func() *eventuate.Subscription {
+		sub, err := stomp.Subscribe("redelivery-subscriber",
+			map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sub
+	}
+
+	// not acknowledged, hence redelivered
+	sub := subscribe()
+	evt, err := sub.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, first.EventIds[0], evt.Id)
+	assert.Equal(t, first.EntityId, evt.EntityId)
+	assert.Nil(t, sub.Unsubscribe())
+
+	sub = subscribe()
+	evt, err = sub.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, first.EventIds[0], evt.Id)
+	sub.AcknowledgeEvent(evt)
+	assert.Nil(t, eventuatetest.AwaitAcks(sub, 5*time.Second))
+	assert.Nil(t, sub.Unsubscribe())
+
+	second, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Ford Prefect"}`}}, nil)
+	assert.Nil(t, err)
+
+	sub = subscribe()
+	evt, err = sub.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, second.EventIds[0], evt.Id)
+	assert.Equal(t, `{"name":"Ford Prefect"}`, evt.EventData)
+}
+
+func TestServer_STOMPNack(t *testing.T) {
+	srv := newServer(t)
+	defer srv.Close()
+
+	rest, err := srv.ClientBuilder().BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := func(name string) eventuate.Int128 {
+		created, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+			{
+				EventType: EVENT_CREATED,
+				EventData: `{"name":"` + name + `"}`}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.EventIds[0]
+	}
+
+	netConn, err := net.Dial("tcp", strings.TrimPrefix(srv.StompURL, "stomp://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := stompngo.Connect(netConn, stompngo.Headers{
+		stompngo.HK_ACCEPT_VERSION, "1.2",
+		stompngo.HK_HOST, "localhost",
+		stompngo.HK_LOGIN, "id",
+		stompngo.HK_PASSCODE, "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Disconnect(stompngo.Headers{"noreceipt", "true"})
+
+	messages, err := conn.Subscribe(stompngo.Headers{
+		"id", "nack-subscription",
+		"destination", `{"subscriberId":"nack-subscriber","entityTypesAndEvents":{"` + ENTITY_TYPE + `":["` + EVENT_CREATED + `"]}}`,
+		"ack", stompngo.AckModeClientIndividual})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := func() (eventuate.Int128, string) {
+		select {
+		case md := <-messages:
+			if md.Error != nil {
+				t.Fatal(md.Error)
+			}
+			var evt eventuate.StompEvent
+			if err := json.Unmarshal(md.Message.Body, &evt); err != nil {
+				t.Fatal(err)
+			}
+			return evt.Id, md.Message.Headers.Value("ack")
+		case <-time.After(5 * time.Second):
+			t.Fatal("event was not delivered")
+			return eventuate.Int128{}, ""
+		}
+	}
+
+	first := save("Arthur Dent")
+	id, ack := receive()
+	assert.Equal(t, first, id)
+	assert.Nil(t, conn.Nack(stompngo.Headers{"id", ack}))
+
+	// nacked, hence redelivered within the subscription
+	id, ack = receive()
+	assert.Equal(t, first, id)
+	assert.Nil(t, conn.Ack(stompngo.Headers{"id", ack}))
+
+	second := save("Ford Prefect")
+	id, _ = receive()
+	assert.Equal(t, second, id)
+}
+
+func TestServer_SubscriberOptions(t *testing.T) {
+	srv := newServer(t)
+	defer srv.Close()
+
+	rest, err := srv.ClientBuilder().BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stomp, err := srv.ClientBuilder().BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	save := func(eventType, name string) *eventuate.EntityIdVersionAndEventIds {
+		result, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+			{
+				EventType: eventType,
+				EventData: `{"name":"` + name + `"}`}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	aggregatesAndEvents := map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}
+
+	past := save(EVENT_CREATED, "Arthur Dent")
+
+	progress := make(chan *eventuate.ProgressNotification, 16)
+	live, err := stomp.Subscribe("live-subscriber", aggregatesAndEvents, &eventuate.SubscriberOptions{
+		Durability:            eventuate.TRANSIENT,
+		ReadFrom:              eventuate.END,
+		ProgressNotifications: true,
+		ProgressHandler: func(notification *eventuate.ProgressNotification) {
+			progress <- notification
+		}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscription is registered asynchronously, events skipped by it are reported as progress
+	deadline := time.After(5 * time.Second)
+	for notified := false; !notified; {
+		skipped := save(EVENT_CHANGED, "Zaphod Beeblebrox")
+		select {
+		case notification := <-progress:
+			notified = true
+			assert.Equal(t, int((skipped.EntityId.FirstPart()^skipped.EntityId.LastPart())%8), notification.Swimlane)
+			assert.True(t, notification.Offset > 0)
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("progress notification was not received")
+		}
+	}
+
+	current := save(EVENT_CREATED, "Ford Prefect")
+	evt, err := live.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, current.EventIds[0], evt.Id)
+
+	replay, err := stomp.Subscribe("replay-subscriber", aggregatesAndEvents, &eventuate.SubscriberOptions{
+		Durability: eventuate.DURABLE,
+		ReadFrom:   eventuate.BEGINNING}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt, err = replay.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, past.EventIds[0], evt.Id)
+}
+
+func TestServer_Reconnection(t *testing.T) {
+	srv := newServer(t)
+	closed := false
+	defer func() {
+		if !closed {
+			srv.Close()
+		}
+	}()
+
+	rest, err := srv.ClientBuilder().BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := make(chan eventuate.ConnectionState, 16)
+	stomp, err := srv.ClientBuilder().
+		WithReconnectPolicy(eventuate.NewRetryPolicy(3, 10*time.Millisecond)).
+		WithConnectionStateHandler(func(state eventuate.ConnectionState, err error) {
+			states <- state
+		}).
+		BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	awaitState := func(expected eventuate.ConnectionState) {
+		select {
+		case state := <-states:
+			assert.Equal(t, expected, state)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("connection state %v was not reported", expected)
+		}
+	}
+	save := func(name string) *eventuate.EntityIdVersionAndEventIds {
+		result, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+			{
+				EventType: EVENT_CREATED,
+				EventData: `{"name":"` + name + `"}`}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	first := save("Arthur Dent")
+
+	sub, err := stomp.Subscribe("reconnecting-subscriber",
+		map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt, err := sub.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, first.EventIds[0], evt.Id)
+
+	srv.DropStompConnections()
+	awaitState(eventuate.RECONNECTING)
+	awaitState(eventuate.CONNECTED)
+
+	// not acknowledged before the connection was lost, hence redelivered
+	evt, err = sub.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, first.EventIds[0], evt.Id)
+	sub.AcknowledgeEvent(evt)
+	assert.Nil(t, eventuatetest.AwaitAcks(sub, 5*time.Second))
+
+	second := save("Ford Prefect")
+	evt, err = sub.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, second.EventIds[0], evt.Id)
+
+	srv.Close()
+	closed = true
+	awaitState(eventuate.RECONNECTING)
+	awaitState(eventuate.FAILED)
+
+	_, err = sub.ReadEvent()
+	assert.Error(t, err)
+	assert.False(t, sub.IsActive())
+}
+
+func TestServer_SubscribeCtx(t *testing.T) {
+	srv := newServer(t)
+	defer srv.Close()
+
+	rest, err := srv.ClientBuilder().BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stomp, err := srv.ClientBuilder().BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := stomp.SubscribeCtx(ctx, "ctx-subscriber",
+		map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := rest.SaveCtx(ctx, ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Marvin"}`}}, nil)
+	assert.Nil(t, err)
+
+	evt, err := sub.ReadEventCtx(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, saved.EventIds[0], evt.Id)
+
+	cancel()
+	select {
+	case <-sub.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not unsubscribed")
+	}
+	assert.False(t, sub.IsActive())
+	_, err = sub.ReadEvent()
+	assert.Error(t, err)
+
+	_, err = rest.FindCtx(ctx, ENTITY_TYPE, saved.EntityId, nil)
+	assert.Error(t, err)
+
+	_, err = stomp.SubscribeCtx(ctx, "ctx-subscriber",
+		map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}, nil, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestServer_SubscribeAndDispatch(t *testing.T) {
+	srv := newServer(t)
+	defer srv.Close()
+
+	repoClient, err := srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}).
+		BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stomp, err := srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}).
+		BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan interface{}, 1)
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			received <- data
+			return future.NewSuccess(true)
+		})
+
+	_, err = stomp.SubscribeAndDispatch("dispatch-subscriber", handlers, nil, false)
+	assert.Nil(t, err)
+
+	_, err = repoClient.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Trillian"}`}}, nil)
+	assert.Nil(t, err)
+
+	select {
+	case data := <-received:
+		assert.Equal(t, &MyEntityWasCreatedEvent{Name: "Trillian"}, data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not dispatched")
+	}
+}
@@ -0,0 +1,100 @@
+package eventuatetest
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+)
+
+// traceMetadataKey is the event metadata key SpanRecorder propagates the producing span with
+const traceMetadataKey = "eventuatetest-span"
+
+type spanKey struct{}
+
+// RecordedSpan is a span ended under a SpanRecorder
+type RecordedSpan struct {
+	Id         string
+	ParentId   string
+	LinkedId   string
+	Name       string
+	Attributes map[string]string
+	Err        error
+}
+
+// SpanRecorder is an eventuate.Tracer that keeps the ended spans in memory, for tests of the instrumentation
+type SpanRecorder struct {
+	sync.Mutex
+	lastId int
+	spans  []RecordedSpan
+}
+
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+func (rec *SpanRecorder) StartSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, eventuate.Span) {
+	parentId, _ := ctx.Value(spanKey{}).(string)
+	return rec.start(ctx, name, parentId, "", attributes)
+}
+
+func (rec *SpanRecorder) Inject(ctx context.Context, metadata map[string]string) {
+	if spanId, hasSpan := ctx.Value(spanKey{}).(string); hasSpan {
+		metadata[traceMetadataKey] = spanId
+	}
+}
+
+func (rec *SpanRecorder) StartEventSpan(ctx context.Context, name string, metadata map[string]string, attributes map[string]string) (context.Context, eventuate.Span) {
+	parentId, _ := ctx.Value(spanKey{}).(string)
+	return rec.start(ctx, name, parentId, metadata[traceMetadataKey], attributes)
+}
+
+// Spans lists the ended spans in the order they ended
+func (rec *SpanRecorder) Spans() []RecordedSpan {
+	rec.Lock()
+	defer rec.Unlock()
+
+	return append([]RecordedSpan(nil), rec.spans...)
+}
+
+// SpansNamed lists the ended spans named `name`
+func (rec *SpanRecorder) SpansNamed(name string) []RecordedSpan {
+	var result []RecordedSpan
+	for _, span := range rec.Spans() {
+		if span.Name == name {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+func (rec *SpanRecorder) start(ctx context.Context, name, parentId, linkedId string, attributes map[string]string) (context.Context, eventuate.Span) {
+	rec.Lock()
+	rec.lastId++
+	spanId := strconv.Itoa(rec.lastId)
+	rec.Unlock()
+
+	span := &recordingSpan{
+		recorder: rec,
+		span: RecordedSpan{
+			Id:         spanId,
+			ParentId:   parentId,
+			LinkedId:   linkedId,
+			Name:       name,
+			Attributes: attributes}}
+	return context.WithValue(ctx, spanKey{}, spanId), span
+}
+
+type recordingSpan struct {
+	recorder *SpanRecorder
+	span     RecordedSpan
+}
+
+func (span *recordingSpan) End(err error) {
+	span.span.Err = err
+
+	span.recorder.Lock()
+	defer span.recorder.Unlock()
+	span.recorder.spans = append(span.recorder.spans, span.span)
+}
@@ -0,0 +1,504 @@
+package eventuatetest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+)
+
+const (
+	ackModeAuto             = "auto"
+	ackModeClient           = "client"
+	ackModeClientIndividual = "client-individual"
+)
+
+type stompFrame struct {
+	command string
+	headers map[string]string
+	body    []byte
+}
+
+type subscriptionRequest struct {
+	EntityTypesAndEvents map[string][]string                   `json:"entityTypesAndEvents"`
+	SubscriberID         string                                `json:"subscriberId"`
+	Space                string                                `json:"space"`
+	Options              *eventuate.SubscriptionRequestOptions `json:"options"`
+}
+
+// stompConnection serves a single STOMP 1.2 client
+type stompConnection struct {
+	sync.Mutex
+	srv           *Server
+	netConn       net.Conn
+	reader        *bufio.Reader
+	wmu           sync.Mutex
+	subscriptions map[string]*stompSubscription
+	pendings      map[string]*pendingMessage
+	messageSeq    int
+	closeOnce     sync.Once
+}
+
+type stompSubscription struct {
+	id           string
+	subscriberId string
+	ackMode      string
+	filter       map[string][]string
+	space        *space
+	state        *subscriberState
+	cursor       int
+	closed       bool
+	// nacked events, delivered again before the rest of the log
+	redeliveries []eventuate.StompEvent
+	// progress notifications are sent once the subscription catches up with the log
+	progressNotifications bool
+	notifiedCursor        int
+}
+
+type pendingMessage struct {
+	subscription *stompSubscription
+	event        eventuate.StompEvent
+	seq          int
+}
+
+func newStompConnection(srv *Server, netConn net.Conn) *stompConnection {
+	return &stompConnection{
+		srv:           srv,
+		netConn:       netConn,
+		reader:        bufio.NewReader(netConn),
+		subscriptions: make(map[string]*stompSubscription),
+		pendings:      make(map[string]*pendingMessage)}
+}
+
+func (conn *stompConnection) serve() {
+	defer conn.close()
+
+	frame, err := conn.readFrame()
+	if err != nil {
+		return
+	}
+	if !conn.handleConnect(frame) {
+		return
+	}
+
+	for {
+		frame, err := conn.readFrame()
+		if err != nil {
+			return
+		}
+
+		switch frame.command {
+		case "SUBSCRIBE":
+			err = conn.handleSubscribe(frame)
+		case "UNSUBSCRIBE":
+			err = conn.handleUnsubscribe(frame)
+		case "ACK":
+			err = conn.handleAck(frame, true)
+		case "NACK":
+			err = conn.handleAck(frame, false)
+		case "DISCONNECT":
+			conn.sendReceipt(frame)
+			return
+		default:
+			err = fmt.Errorf("Unsupported frame: %s", frame.command)
+		}
+
+		if err != nil {
+			conn.sendError(err.Error())
+			return
+		}
+		conn.sendReceipt(frame)
+	}
+}
+
+func (conn *stompConnection) handleConnect(frame *stompFrame) bool {
+	if frame.command != "CONNECT" && frame.command != "STOMP" {
+		conn.sendError("Expected CONNECT frame, got: " + frame.command)
+		return false
+	}
+
+	versions := strings.Split(frame.headers["accept-version"], ",")
+	if !hasValue(versions, "1.2") {
+		conn.sendError("Supported protocol version is 1.2")
+		return false
+	}
+
+	if len(frame.headers["login"]) == 0 || len(frame.headers["passcode"]) == 0 {
+		conn.sendError("Credentials are missing")
+		return false
+	}
+
+	return conn.writeFrame("CONNECTED", []string{
+		"version", "1.2",
+		"heart-beat", "0,0",
+		"session", fmt.Sprintf("session-%p", conn),
+		"server", "eventuatetest"}, nil) == nil
+}
+
+func (conn *stompConnection) handleSubscribe(frame *stompFrame) error {
+	id, hasId := frame.headers["id"]
+	if !hasId {
+		return fmt.Errorf("SUBSCRIBE requires the `id` header")
+	}
+
+	destination := []byte(frame.headers["destination"])
+	if err := conn.srv.schemas.validateJson(destinationHeaderSchema, destination); err != nil {
+		return err
+	}
+
+	var request subscriptionRequest
+	if err := json.Unmarshal(destination, &request); err != nil {
+		return err
+	}
+
+	ackMode := frame.headers["ack"]
+	if len(ackMode) == 0 {
+		ackMode = ackModeAuto
+	}
+
+	sp := conn.srv.getSpace(request.Space)
+	sp.Lock()
+	state := sp.getSubscriber(request.SubscriberID, request.Options)
+	sub := &stompSubscription{
+		id:                    id,
+		subscriberId:          request.SubscriberID,
+		ackMode:               ackMode,
+		filter:                request.EntityTypesAndEvents,
+		space:                 sp,
+		state:                 state,
+		cursor:                state.startCursor,
+		progressNotifications: request.Options != nil && request.Options.ProgressNotifications,
+		notifiedCursor:        state.startCursor}
+	sp.Unlock()
+
+	conn.Lock()
+	if _, isDuplicate := conn.subscriptions[id]; isDuplicate {
+		conn.Unlock()
+		return fmt.Errorf("Duplicate subscription id: %s", id)
+	}
+	conn.subscriptions[id] = sub
+	conn.Unlock()
+
+	go conn.deliver(sub)
+
+	return nil
+}
+
+func (conn *stompConnection) handleUnsubscribe(frame *stompFrame) error {
+	id := frame.headers["id"]
+
+	conn.Lock()
+	sub, hasSub := conn.subscriptions[id]
+	delete(conn.subscriptions, id)
+	conn.Unlock()
+
+	if !hasSub {
+		return fmt.Errorf("Unknown subscription id: %s", id)
+	}
+	sub.close()
+	return nil
+}
+
+// handleAck settles a delivered message. Nacked messages are redelivered within the subscription,
+// unsettled ones to the next subscription of the same subscriber.
+func (conn *stompConnection) handleAck(frame *stompFrame, isAck bool) error {
+	id := frame.headers["id"]
+
+	conn.Lock()
+	pending, hasPending := conn.pendings[id]
+	if !hasPending {
+		conn.Unlock()
+		return fmt.Errorf("Unknown message id: %s", id)
+	}
+
+	settled := []*pendingMessage{pending}
+	delete(conn.pendings, id)
+	if pending.subscription.ackMode == ackModeClient {
+		for key, other := range conn.pendings {
+			if other.subscription == pending.subscription && other.seq < pending.seq {
+				settled = append(settled, other)
+				delete(conn.pendings, key)
+			}
+		}
+	}
+	conn.Unlock()
+
+	sort.Slice(settled, func(i, j int) bool {
+		return settled[i].seq < settled[j].seq
+	})
+
+	sp := pending.subscription.space
+	sp.Lock()
+	defer sp.Unlock()
+	for _, message := range settled {
+		if isAck {
+			message.subscription.state.acked[message.event.Id] = true
+		} else {
+			message.subscription.redeliveries = append(message.subscription.redeliveries, message.event)
+		}
+	}
+	if !isAck {
+		sp.cond.Broadcast()
+	}
+	return nil
+}
+
+// deliver streams the space's event log to the subscription until it is closed
+func (conn *stompConnection) deliver(sub *stompSubscription) {
+	sp := sub.space
+	sp.Lock()
+	defer sp.Unlock()
+
+	for {
+		for !sub.closed && len(sub.redeliveries) == 0 && sub.cursor >= len(sp.log) {
+			if sub.progressNotifications && sub.notifiedCursor < sub.cursor {
+				last := sp.log[sub.cursor-1]
+				sub.notifiedCursor = sub.cursor
+
+				sp.Unlock()
+				err := conn.sendProgressNotification(sub, &last)
+				sp.Lock()
+
+				if err != nil {
+					return
+				}
+				continue
+			}
+			sp.cond.Wait()
+		}
+		if sub.closed {
+			return
+		}
+
+		var evt eventuate.StompEvent
+		if len(sub.redeliveries) > 0 {
+			evt = sub.redeliveries[0]
+			sub.redeliveries = sub.redeliveries[1:]
+		} else {
+			evt = sp.log[sub.cursor]
+			sub.cursor++
+		}
+
+		if !sub.matches(&evt) || sub.state.acked[evt.Id] {
+			continue
+		}
+		if sub.ackMode == ackModeAuto {
+			sub.state.acked[evt.Id] = true
+		}
+
+		sp.Unlock()
+		err := conn.sendMessage(sub, &evt)
+		sp.Lock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (conn *stompConnection) sendMessage(sub *stompSubscription, evt *eventuate.StompEvent) error {
+	if err := conn.srv.schemas.validateValue(stompEventSchema, evt); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	conn.Lock()
+	conn.messageSeq++
+	messageId := fmt.Sprintf("message-%d", conn.messageSeq)
+	if sub.ackMode != ackModeAuto {
+		conn.pendings[messageId] = &pendingMessage{
+			subscription: sub,
+			event:        *evt,
+			seq:          conn.messageSeq}
+	}
+	conn.Unlock()
+
+	return conn.writeFrame("MESSAGE", []string{
+		"subscription", sub.id,
+		"message-id", messageId,
+		"ack", messageId,
+		"content-type", "application/json"}, body)
+}
+
+func (conn *stompConnection) sendProgressNotification(sub *stompSubscription, last *eventuate.StompEvent) error {
+	body, err := json.Marshal(eventuate.ProgressNotification{
+		Swimlane: last.Swimlane,
+		Offset:   last.Offset})
+	if err != nil {
+		return err
+	}
+
+	conn.Lock()
+	conn.messageSeq++
+	messageId := fmt.Sprintf("message-%d", conn.messageSeq)
+	conn.Unlock()
+
+	return conn.writeFrame("MESSAGE", []string{
+		"subscription", sub.id,
+		"message-id", messageId,
+		eventuate.ProgressNotificationHeader, "true",
+		"content-type", "application/json"}, body)
+}
+
+func (conn *stompConnection) sendReceipt(frame *stompFrame) {
+	if receipt, hasReceipt := frame.headers["receipt"]; hasReceipt {
+		conn.writeFrame("RECEIPT", []string{"receipt-id", receipt}, nil)
+	}
+}
+
+func (conn *stompConnection) sendError(message string) {
+	conn.writeFrame("ERROR", []string{
+		"message", message,
+		"content-type", "text/plain"}, []byte(message))
+}
+
+func (conn *stompConnection) close() {
+	conn.closeOnce.Do(func() {
+		conn.netConn.Close()
+
+		conn.Lock()
+		subscriptions := conn.subscriptions
+		conn.subscriptions = make(map[string]*stompSubscription)
+		conn.Unlock()
+
+		for _, sub := range subscriptions {
+			sub.close()
+		}
+	})
+}
+
+func (conn *stompConnection) readFrame() (*stompFrame, error) {
+	var command string
+	for len(command) == 0 { // empty lines are heart-beats
+		line, err := conn.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		command = strings.TrimRight(strings.TrimLeft(line, "\x00"), "\r\n")
+	}
+
+	frame := &stompFrame{
+		command: command,
+		headers: make(map[string]string)}
+	shouldDecode := command != "CONNECT" && command != "STOMP"
+
+	for {
+		line, err := conn.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			break
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Malformed header: %s", line)
+		}
+		if shouldDecode {
+			parts[0], parts[1] = decodeHeader(parts[0]), decodeHeader(parts[1])
+		}
+		if _, isRepeated := frame.headers[parts[0]]; !isRepeated {
+			frame.headers[parts[0]] = parts[1]
+		}
+	}
+
+	if contentLength, hasLength := frame.headers["content-length"]; hasLength {
+		length, err := strconv.Atoi(strings.TrimSpace(contentLength))
+		if err != nil {
+			return nil, err
+		}
+		frame.body = make([]byte, length)
+		if _, err := io.ReadFull(conn.reader, frame.body); err != nil {
+			return nil, err
+		}
+		if _, err := conn.reader.ReadBytes(0); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	body, err := conn.reader.ReadBytes(0)
+	if err != nil {
+		return nil, err
+	}
+	frame.body = body[:len(body)-1]
+	return frame, nil
+}
+
+func (conn *stompConnection) writeFrame(command string, headers []string, body []byte) error {
+	var buffer bytes.Buffer
+	shouldEncode := command != "CONNECTED"
+
+	buffer.WriteString(command)
+	buffer.WriteByte('\n')
+	for i := 0; i < len(headers); i += 2 {
+		key, value := headers[i], headers[i+1]
+		if shouldEncode {
+			key, value = encodeHeader(key), encodeHeader(value)
+		}
+		buffer.WriteString(key)
+		buffer.WriteByte(':')
+		buffer.WriteString(value)
+		buffer.WriteByte('\n')
+	}
+	if body != nil {
+		fmt.Fprintf(&buffer, "content-length:%d\n", len(body))
+	}
+	buffer.WriteByte('\n')
+	buffer.Write(body)
+	buffer.WriteByte(0)
+
+	conn.wmu.Lock()
+	defer conn.wmu.Unlock()
+	_, err := conn.netConn.Write(buffer.Bytes())
+	return err
+}
+
+func (sub *stompSubscription) matches(evt *eventuate.StompEvent) bool {
+	eventTypes, hasEntityType := sub.filter[evt.EntityType]
+	if !hasEntityType {
+		return false
+	}
+	return len(eventTypes) == 0 || hasValue(eventTypes, evt.EventType)
+}
+
+func (sub *stompSubscription) close() {
+	sub.space.Lock()
+	sub.closed = true
+	sub.space.cond.Broadcast()
+	sub.space.Unlock()
+}
+
+var headerEncoder = strings.NewReplacer("\\", "\\\\", "\r", "\\r", "\n", "\\n", ":", "\\c")
+var headerDecoder = strings.NewReplacer("\\\\", "\\", "\\r", "\r", "\\n", "\n", "\\c", ":")
+
+func encodeHeader(value string) string {
+	return headerEncoder.Replace(value)
+}
+
+func decodeHeader(value string) string {
+	return headerDecoder.Replace(value)
+}
+
+func hasValue(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.TrimSpace(candidate) == value {
+			return true
+		}
+	}
+	return false
+}
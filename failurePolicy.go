@@ -0,0 +1,70 @@
+package eventuate
+
+import "time"
+
+// FailureAction is what a DispatchingSubscription does with an event its handler kept failing on
+type FailureAction int
+
+const (
+	// FailureReport reports the failure on the subscription errors and leaves the event unacknowledged,
+	// which holds back the acks of the events received after it
+	FailureReport FailureAction = iota
+	// FailureDeadLetter puts the event into the DeadLetterSink of the policy and acknowledges it
+	FailureDeadLetter
+	// FailureStop unsubscribes, the event is redelivered to the next subscription of the subscriber
+	FailureStop
+)
+
+func (action FailureAction) String() string {
+	switch action {
+	case FailureDeadLetter:
+		return "DEAD_LETTER"
+	case FailureStop:
+		return "STOP"
+	}
+	return "REPORT"
+}
+
+// FailurePolicy is how a DispatchingSubscription handles the failures of its event handlers.
+// The handler is called again according to `Retry` (not at all if nil), `Action` is taken once the attempts are exhausted.
+type FailurePolicy struct {
+	Retry       *RetryPolicy
+	Action      FailureAction
+	DeadLetters DeadLetterSink
+}
+
+// DeadLetter is an event its handler failed on, with the last error
+type DeadLetter struct {
+	SubscriberId string     `json:"subscriberId"`
+	Event        StompEvent `json:"event"`
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"error"`
+	FailedAt     time.Time  `json:"failedAt"`
+}
+
+// DeadLetterSink keeps the events of the FailureDeadLetter action. They are acknowledged once Put succeeds.
+type DeadLetterSink interface {
+	Put(letter DeadLetter) error
+}
+
+// validate rejects the policies that cannot be applied, such as dead-lettering without a sink
+func (policy *FailurePolicy) validate() error {
+	if policy != nil && policy.Action == FailureDeadLetter && policy.DeadLetters == nil {
+		return AppError("FailurePolicy: the %s action needs a DeadLetters sink", policy.Action)
+	}
+	return nil
+}
+
+func (policy *FailurePolicy) maxAttempts() int {
+	if policy == nil || policy.Retry == nil {
+		return 1
+	}
+	return policy.Retry.maxAttempts()
+}
+
+func (policy *FailurePolicy) action() FailureAction {
+	if policy == nil {
+		return FailureReport
+	}
+	return policy.Action
+}
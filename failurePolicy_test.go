@@ -0,0 +1,140 @@
+package eventuate_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailurePolicy(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	rest := buildREST(t, srv.ClientBuilder())
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}))
+
+	var attempts int32
+	handled := make(chan string, 10)
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			name := data.(*MyEntityWasCreatedEvent).Name
+			if name == "Marvin" {
+				atomic.AddInt32(&attempts, 1)
+				return future.NewFailure(eventuate.AppError("Marvin is too depressed"))
+			}
+			handled <- name
+			return future.NewSuccess(true)
+		})
+
+	save := func(name string) {
+		_, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+			{
+				EventType: EVENT_CREATED,
+				EventData: `{"name":"` + name + `"}`}}, nil)
+		assert.Nil(t, err)
+	}
+	save("Marvin")
+	save("Trillian")
+
+	// dead-lettering needs a sink
+	_, err := stomp.SubscribeAndDispatch("sinkless-subscriber", handlers, &eventuate.SubscriberOptions{
+		FailurePolicy: &eventuate.FailurePolicy{
+			Action: eventuate.FailureDeadLetter}}, false)
+	assert.Error(t, err)
+
+	// retried, then dead-lettered and acked, which lets the later events be acked
+	deadLetters := eventuate.NewInMemoryDeadLetterSink()
+	sub, err := stomp.SubscribeAndDispatch("dead-letter-subscriber", handlers, &eventuate.SubscriberOptions{
+		FailurePolicy: &eventuate.FailurePolicy{
+			Retry:       eventuate.NewRetryPolicy(3, time.Millisecond),
+			Action:      eventuate.FailureDeadLetter,
+			DeadLetters: deadLetters}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Trillian", <-handled)
+	awaitAcks(t, sub.Subscription)
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	letters := deadLetters.Letters()
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, "dead-letter-subscriber", letters[0].SubscriberId)
+		assert.Equal(t, `{"name":"Marvin"}`, letters[0].Event.EventData)
+		assert.Equal(t, 3, letters[0].Attempts)
+		assert.Contains(t, letters[0].Error, "Marvin is too depressed")
+	}
+	assert.Nil(t, sub.Unsubscribe())
+
+	// stopped on the first failure
+	atomic.StoreInt32(&attempts, 0)
+	sub, err = stomp.SubscribeAndDispatch("stopping-subscriber", handlers, &eventuate.SubscriberOptions{
+		FailurePolicy: &eventuate.FailurePolicy{
+			Action: eventuate.FailureStop}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sub.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not stopped")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	assert.Error(t, sub.Err())
+	assert.False(t, sub.IsActive())
+}
+
+func TestFailurePolicy_RetriesHoldTheLane(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	rest := buildREST(t, srv.ClientBuilder())
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}).
+		WithTypeHintPair(EVENT_CHANGED, MyEntityWasCreatedEvent{}))
+
+	var failures int32
+	handled := make(chan string, 10)
+	handler := func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+		if meta.EventType == EVENT_CREATED && atomic.AddInt32(&failures, 1) <= 2 {
+			return future.NewFailure(eventuate.AppError("not yet"))
+		}
+		handled <- meta.EventType
+		return future.NewSuccess(true)
+	}
+	handlers := eventuate.NewEventResultHandlerMap().
+		AddHandler(ENTITY_TYPE, EVENT_CREATED, handler).
+		AddHandler(ENTITY_TYPE, EVENT_CHANGED, handler)
+
+	created, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Arthur Dent"}`}}, nil)
+	assert.Nil(t, err)
+	_, err = rest.Update(eventuate.EntityIdAndType{
+		EntityType: ENTITY_TYPE,
+		EntityId:   created.EntityId}, created.EntityVersion, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CHANGED,
+			EventData: `{"name":"Zaphod Beeblebrox"}`}}, nil)
+	assert.Nil(t, err)
+
+	// the change waits in the lane of the entity until its creation is handled
+	sub, err := stomp.SubscribeAndDispatch("retrying-subscriber", handlers, &eventuate.SubscriberOptions{
+		FailurePolicy: &eventuate.FailurePolicy{
+			Retry: eventuate.NewRetryPolicy(3, 20*time.Millisecond)},
+		Swimlanes: &eventuate.SwimlaneOptions{
+			Lanes: 4}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, EVENT_CREATED, <-handled)
+	assert.Equal(t, EVENT_CHANGED, <-handled)
+	awaitAcks(t, sub.Subscription)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&failures))
+	assert.Nil(t, sub.Unsubscribe())
+}
@@ -1,10 +1,12 @@
 package future
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,7 +21,7 @@ type Thenable interface {
 	Then(ThenCallback) Settler
 }
 
-var idCounter int
+var idCounter int64 // incremented atomically, results are made from concurrent go-routines
 
 type Result struct {
 	sync.WaitGroup
@@ -29,8 +31,9 @@ type Result struct {
 	lastError    error
 	id           int
 	pendingCount int
+	settled      chan struct{} // closed once settled, made on demand by GetValueCtx
 	ll           loglib.LogLevelEnum
-	lg           loglib.Logger
+	lg           loglib.LeveledLogger
 	tl           bool
 }
 
@@ -39,13 +42,13 @@ func (fr Result) String() string {
 }
 
 func NewResult() *Result {
-	idCounter++
+	id := atomic.AddInt64(&idCounter, 1)
 
 	fr := Result{
 		settleFlag: false,
 		lastValue:  nil,
 		lastError:  nil,
-		id:         idCounter,
+		id:         int(id),
 		lg:         loglib.NewNilLogger()}
 
 	return &fr
@@ -60,14 +63,11 @@ func (fr *Result) Timed(wait time.Duration, val interface{}, err error) *Result
 		return fr
 	}
 
-	fr.log("NewTimedResult created")
+	fr.log("Timed result created", "wait", wait)
 	go func() {
-		fr.log("NewTimedResult (go) Sleeping for ", time.Duration(wait)*time.Millisecond)
 		time.Sleep(wait) // time.Duration(wait) * time.Millisecond)
-		fr.log("NewTimedResult (go) Un-sleeping from ", wait)
-		fr.log("NewTimedResult (go) Settling.. ", fr, val, err)
+		fr.log("Timed result settling", "error", err)
 		fr.Settle(val, err)
-		fr.log("NewTimedResult (go) Settled.. ", fr, val, err)
 	}()
 	return fr
 }
@@ -77,8 +77,16 @@ func (fr *Result) SetLogLevel(level loglib.LogLevelEnum) {
 	fr.ll = level
 }
 
-func (fr *Result) log(args ...interface{}) {
-	fr.lg.Println(args...)
+func (fr *Result) SetLogger(lg loglib.LeveledLogger) {
+	fr.lg = lg
+}
+
+// log writes a debug message about the result, NewSuccess and NewFailure make results without a logger
+func (fr *Result) log(msg string, keysAndValues ...interface{}) {
+	if fr.lg == nil {
+		return
+	}
+	fr.lg.Debug(msg, append([]interface{}{"result", fr.id}, keysAndValues...)...)
 }
 
 func NewFailure(err error) *Result {
@@ -107,25 +115,29 @@ func (fr *Result) Settle(val interface{}, err error) {
 	if fr.IsSettled() {
 		return
 	}
-	fr.lg.Println("Result.Settle() Resolving for.. ", *fr)
+	fr.log("Settling result")
 
 	fr.Lock()
 	defer fr.Unlock()
-	defer fr.lg.Println("Result.Settle() .Unlock() called")
 
 	fr.lastError = err
 	if err == nil {
 		fr.lastValue = val
 	}
 	fr.settleFlag = true
-	fr.lg.Println("Result.Settle() Resolved. wg.Count: ", fr.pendingCount)
+	if fr.settled != nil {
+		select {
+		case <-fr.settled:
+		default:
+			close(fr.settled)
+		}
+	}
+	fr.log("Result settled", "waiting", fr.pendingCount)
 	if fr.pendingCount > 0 {
 		defer func(count int) {
 			fr.Add(count)
-			fr.lg.Println("Result.Settle() wg.Add()", count)
 		}(-fr.pendingCount)
 		fr.pendingCount = 0
-		fr.lg.Println("Result.Settle() wg.Count reset: ", fr.pendingCount)
 	}
 }
 
@@ -137,20 +149,41 @@ func (fr *Result) GetValue() (val interface{}, err error) {
 	}
 	fr.RUnlock()
 
-	fr.lg.Println("Result.GetValue() Expecting a value for.. ", *fr)
-
 	fr.Lock()
 	fr.pendingCount++
 	fr.Add(1)
-	fr.lg.Println("Result.GetValue() Increasing pendings, now: ", fr.pendingCount)
+	fr.log("Waiting for result", "waiting", fr.pendingCount)
 	fr.Unlock()
 
-	fr.lg.Println("Result.GetValue() Blocked at the fr.Wait() ", *fr)
 	fr.Wait()
-	fr.lg.Println("Result.GetValue() Received a value for.. ", *fr, val, err)
+	fr.log("Result received")
 	return fr.lastValue, fr.lastError
 }
 
+// GetValueCtx is GetValue that gives up with `ctx.Err()` once the context is done.
+// The Result itself is not affected and can still be settled later.
+func (fr *Result) GetValueCtx(ctx context.Context) (interface{}, error) {
+	select {
+	case <-fr.settledChannel():
+		return fr.GetValue()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// settledChannel is closed once the result is settled, no go-routine is left waiting for it
+func (fr *Result) settledChannel() <-chan struct{} {
+	fr.Lock()
+	defer fr.Unlock()
+	if fr.settled == nil {
+		fr.settled = make(chan struct{})
+		if fr.settleFlag {
+			close(fr.settled)
+		}
+	}
+	return fr.settled
+}
+
 func (fr *Result) Then(cb ThenCallback) Settler {
 	newFr := NewResult()
 	go func(fr1, fr2 *Result) {
@@ -1,6 +1,7 @@
 package future_test
 
 import (
+	"context"
 	"errors"
 	"github.com/eventuate-clients/eventuate-client-golang/future"
 	"reflect"
@@ -329,6 +330,33 @@ func TestResult_GetValue2(t *testing.T) {
 		t.Fail()
 	}
 }
+func TestResult_GetValueCtx(t *testing.T) {
+	fr := future.NewResult()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := fr.GetValueCtx(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fail()
+		return
+	}
+
+	resultMsg := "resulting message"
+	fr.Settle(resultMsg, nil)
+
+	val, err := fr.GetValueCtx(context.Background())
+	if err != nil || !reflect.DeepEqual(val, resultMsg) {
+		t.Fail()
+	}
+	// settled while waiting
+	fr = future.NewResult()
+	go fr.Settle(resultMsg, nil)
+	val, err = fr.GetValueCtx(context.Background())
+	if err != nil || !reflect.DeepEqual(val, resultMsg) {
+		t.Fail()
+	}
+}
 func TestResult_GetValue3(t *testing.T) {
 	fr := future.NewResult()
 	resultMsg := "resulting message"
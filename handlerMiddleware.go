@@ -0,0 +1,226 @@
+package eventuate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+)
+
+// HandlerMiddleware wraps an EventResultHandler with logic shared by the handlers
+type HandlerMiddleware func(next EventResultHandler) EventResultHandler
+
+// ChainMiddleware wraps `handler` with `middleware`, the first one outermost
+func ChainMiddleware(handler EventResultHandler, middleware ...HandlerMiddleware) EventResultHandler {
+	for idx := len(middleware) - 1; idx >= 0; idx-- {
+		handler = middleware[idx](handler)
+	}
+	return handler
+}
+
+// WithMiddleware is a copy of the map whose handlers are wrapped with `middleware`, the first one outermost
+func (handlersMap *EventResultHandlerMap) WithMiddleware(middleware ...HandlerMiddleware) *EventResultHandlerMap {
+	result := NewEventResultHandlerMap()
+	for aggType, handlers := range *handlersMap {
+		for eventType, handler := range handlers {
+			result.AddHandler(aggType, eventType, ChainMiddleware(handler, middleware...))
+		}
+	}
+	return result
+}
+
+// MiddlewareDispatcherMaker makes the dispatchers of `maker` with their handlers wrapped with `middleware`
+func MiddlewareDispatcherMaker(maker DispatcherMaker, middleware ...HandlerMiddleware) DispatcherMaker {
+	return func(eventHandlers *EventResultHandlerMap) (Dispatcher, error) {
+		return maker(eventHandlers.WithMiddleware(middleware...))
+	}
+}
+
+// onSettled calls `fn` with the outcome of `result`, at once if it is settled, from a go-routine otherwise
+func onSettled(result future.Settler, fn func(val interface{}, err error)) {
+	if result.IsSettled() {
+		fn(result.GetValue())
+		return
+	}
+	go func() {
+		fn(result.GetValue()) // blocks
+	}()
+}
+
+// RecoverMiddleware turns the panics of the handlers, and the nil results they return, into failed results
+func RecoverMiddleware() HandlerMiddleware {
+	return func(next EventResultHandler) EventResultHandler {
+		return func(data interface{}, meta *EventMetadata) (result future.Settler) {
+			defer func() {
+				if r := recover(); r != nil {
+					result = future.NewFailure(AppError("Recovered in event handler: %#v", r))
+				}
+			}()
+			result = next(data, meta)
+			if result == nil {
+				return future.NewFailure(AppError("Event handler returned no result for event %s", meta.Id))
+			}
+			return result
+		}
+	}
+}
+
+// TimeoutMiddleware fails with ErrHandlerTimeout the results not settled within `timeout` after the handler returned them.
+// The handler is not interrupted.
+func TimeoutMiddleware(timeout time.Duration) HandlerMiddleware {
+	return func(next EventResultHandler) EventResultHandler {
+		return func(data interface{}, meta *EventMetadata) future.Settler {
+			result := next(data, meta)
+			if result.IsSettled() {
+				return result
+			}
+
+			timed := future.NewResult()
+			var once sync.Once
+			settle := func(val interface{}, err error) {
+				once.Do(func() {
+					timed.Settle(val, err)
+				})
+			}
+			timer := time.AfterFunc(timeout, func() {
+				settle(nil, AppError("Event %s: %w", meta.Id, ErrHandlerTimeout))
+			})
+			onSettled(result, func(val interface{}, err error) {
+				timer.Stop()
+				settle(val, err)
+			})
+			return timed
+		}
+	}
+}
+
+// DedupeMiddleware skips the events handled successfully among the last `capacity` ones,
+// such as the ones redelivered after a lost connection. A copy of an event being handled gets its result.
+func DedupeMiddleware(capacity int) HandlerMiddleware {
+	handled := newEventIdRing(capacity)
+	return func(next EventResultHandler) EventResultHandler {
+		return func(data interface{}, meta *EventMetadata) future.Settler {
+			shared, isFirst, isHandled := handled.begin(meta.Id)
+			if isHandled {
+				return future.NewSuccess(nil)
+			}
+			if !isFirst {
+				return shared
+			}
+
+			settle := func(val interface{}, err error) {
+				handled.end(meta.Id, err == nil)
+				shared.Settle(val, err)
+			}
+			defer func() {
+				if r := recover(); r != nil {
+					settle(nil, AppError("Recovered in event handler: %#v", r))
+					panic(r)
+				}
+			}()
+			result := next(data, meta)
+			if result == nil {
+				settle(nil, AppError("Event handler returned no result for event %s", meta.Id))
+				return shared
+			}
+			onSettled(result, settle)
+			return result
+		}
+	}
+}
+
+// FilterMiddleware succeeds without handling the events `accept` rejects, e.g. the ones of other tenants
+func FilterMiddleware(accept func(meta *EventMetadata) bool) HandlerMiddleware {
+	return func(next EventResultHandler) EventResultHandler {
+		return func(data interface{}, meta *EventMetadata) future.Settler {
+			if !accept(meta) {
+				return future.NewSuccess(nil)
+			}
+			return next(data, meta)
+		}
+	}
+}
+
+// LoggingMiddleware logs the events handled, with the duration of their handling, and the failures
+func LoggingMiddleware(lg loglib.LeveledLogger) HandlerMiddleware {
+	return func(next EventResultHandler) EventResultHandler {
+		return func(data interface{}, meta *EventMetadata) future.Settler {
+			eventLg := lg.With(loglib.KeyEntityType, meta.EntityType, loglib.KeyEntityId, meta.EntityId,
+				loglib.KeyEventType, meta.EventType, loglib.KeyEventId, meta.Id)
+			start := time.Now()
+			eventLg.Debug("Handling event")
+
+			result := next(data, meta)
+			onSettled(result, func(val interface{}, err error) {
+				if err != nil {
+					eventLg.Warn("Event handler failed", "duration", time.Since(start), loglib.KeyError, err)
+				} else {
+					eventLg.Debug("Event handled", "duration", time.Since(start))
+				}
+			})
+			return result
+		}
+	}
+}
+
+// eventIdRing is a set of the last event ids added to it, and of the ids of the events being handled
+type eventIdRing struct {
+	sync.Mutex
+	ids      map[Int128]bool
+	ring     []Int128
+	next     int
+	count    int
+	inFlight map[Int128]*future.Result
+}
+
+func newEventIdRing(capacity int) *eventIdRing {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &eventIdRing{
+		ids:      make(map[Int128]bool, capacity),
+		ring:     make([]Int128, capacity),
+		inFlight: make(map[Int128]*future.Result)}
+}
+
+// begin tells whether the event was handled. Otherwise it is marked in flight, unless it already was (`first` is false),
+// `inFlight` is settled with the result of its handling.
+func (ring *eventIdRing) begin(id Int128) (inFlight *future.Result, first bool, handled bool) {
+	ring.Lock()
+	defer ring.Unlock()
+	if ring.ids[id] {
+		return nil, false, true
+	}
+	if result, isInFlight := ring.inFlight[id]; isInFlight {
+		return result, false, false
+	}
+	inFlight = future.NewResult()
+	ring.inFlight[id] = inFlight
+	return inFlight, true, false
+}
+
+// end unmarks the event in flight, it is added once handled
+func (ring *eventIdRing) end(id Int128, handled bool) {
+	ring.Lock()
+	defer ring.Unlock()
+	delete(ring.inFlight, id)
+	if handled {
+		ring.add(id)
+	}
+}
+
+// add is called with the lock held
+func (ring *eventIdRing) add(id Int128) {
+	if ring.ids[id] {
+		return
+	}
+	if ring.count == len(ring.ring) {
+		delete(ring.ids, ring.ring[ring.next])
+	} else {
+		ring.count++
+	}
+	ring.ring[ring.next] = id
+	ring.ids[id] = true
+	ring.next = (ring.next + 1) % len(ring.ring)
+}
@@ -0,0 +1,211 @@
+package eventuate_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func tracingMiddleware(name string, calls *[]string) eventuate.HandlerMiddleware {
+	return func(next eventuate.EventResultHandler) eventuate.EventResultHandler {
+		return func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			*calls = append(*calls, name+" before")
+			result := next(data, meta)
+			*calls = append(*calls, name+" after")
+			return result
+		}
+	}
+}
+
+func TestMiddlewareDispatcherMaker_Order(t *testing.T) {
+	var calls []string
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(AGG_TYPE, EVT_TYPE_A,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			calls = append(calls, "handler")
+			return future.NewSuccess(data)
+		})
+
+	maker := eventuate.MiddlewareDispatcherMaker(eventuate.NewEventDispatcher,
+		tracingMiddleware("first", &calls), tracingMiddleware("second", &calls))
+	dispatcher, err := maker(handlers)
+	assert.Nil(t, err)
+
+	evt := newEvent(AGG_TYPE, EVT_TYPE_A, "data", 1)
+	val, err := dispatcher.Dispatch(evt.data, evt.meta).GetValue()
+	assert.Nil(t, err)
+	assert.Equal(t, "data", val)
+	assert.Equal(t, []string{"first before", "second before", "handler", "second after", "first after"}, calls)
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := eventuate.ChainMiddleware(
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			panic("don't panic")
+		},
+		eventuate.RecoverMiddleware())
+
+	evt := newEvent(AGG_TYPE, EVT_TYPE_A, "data", 1)
+	_, err := handler(evt.data, evt.meta).GetValue()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "don't panic")
+
+	// a handler returning no result fails
+	handler = eventuate.ChainMiddleware(
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			return nil
+		},
+		eventuate.RecoverMiddleware())
+	result := handler(evt.data, evt.meta)
+	if assert.NotNil(t, result) {
+		_, err = result.GetValue()
+		assert.Error(t, err)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := eventuate.ChainMiddleware(
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			return future.NewTimedResult(time.Duration(data.(int))*time.Millisecond, "done", nil)
+		},
+		eventuate.TimeoutMiddleware(100*time.Millisecond))
+
+	fast := newEvent(AGG_TYPE, EVT_TYPE_A, 10, 1)
+	val, err := handler(fast.data, fast.meta).GetValue()
+	assert.Nil(t, err)
+	assert.Equal(t, "done", val)
+
+	slow := newEvent(AGG_TYPE, EVT_TYPE_A, 1000, 1)
+	_, err = handler(slow.data, slow.meta).GetValue()
+	assert.True(t, errors.Is(err, eventuate.ErrHandlerTimeout))
+}
+
+func TestDedupeMiddleware(t *testing.T) {
+	var mutex sync.Mutex
+	counts := make(map[eventuate.Int128]int)
+	handler := eventuate.ChainMiddleware(
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			mutex.Lock()
+			defer mutex.Unlock()
+			counts[meta.Id]++
+			if data == "fail" {
+				return future.NewFailure(fmt.Errorf("failed"))
+			}
+			return future.NewSuccess(data)
+		},
+		eventuate.DedupeMiddleware(2))
+
+	dispatch := func(id eventuate.Int128, data string) {
+		evt := newEvent(AGG_TYPE, EVT_TYPE_A, data, 1)
+		evt.meta.Id = id
+		handler(evt.data, evt.meta).GetValue()
+	}
+	first, second, third := eventuate.Int128Random(), eventuate.Int128Random(), eventuate.Int128Random()
+	failed := eventuate.Int128Random()
+
+	dispatch(first, "ok")
+	dispatch(first, "ok")
+	dispatch(failed, "fail")
+	dispatch(failed, "fail")
+	dispatch(second, "ok")
+	dispatch(third, "ok")
+	// forgotten, beyond the capacity
+	dispatch(first, "ok")
+
+	assert.Equal(t, 2, counts[first])
+	assert.Equal(t, 2, counts[failed])
+	assert.Equal(t, 1, counts[second])
+	assert.Equal(t, 1, counts[third])
+}
+
+func TestDedupeMiddleware_InFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	handler := eventuate.ChainMiddleware(
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			atomic.AddInt32(&calls, 1)
+			result := future.NewResult()
+			go func() {
+				<-release
+				result.Settle(data, nil)
+			}()
+			return result
+		},
+		eventuate.DedupeMiddleware(2))
+
+	// the copy redelivered while the event is handled gets its result
+	evt := newEvent(AGG_TYPE, EVT_TYPE_A, "data", 1)
+	first := handler(evt.data, evt.meta)
+	copied := handler(evt.data, evt.meta)
+	close(release)
+
+	for _, result := range []future.Settler{first, copied} {
+		val, err := result.GetValue()
+		assert.Nil(t, err)
+		assert.Equal(t, "data", val)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDedupeMiddleware_NoResult(t *testing.T) {
+	handler := eventuate.ChainMiddleware(
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			return nil
+		},
+		eventuate.TimeoutMiddleware(100*time.Millisecond),
+		eventuate.DedupeMiddleware(2))
+
+	evt := newEvent(AGG_TYPE, EVT_TYPE_A, "data", 1)
+	result := handler(evt.data, evt.meta)
+	if assert.NotNil(t, result) {
+		_, err := result.GetValue()
+		assert.NotNil(t, err)
+		assert.False(t, errors.Is(err, eventuate.ErrHandlerTimeout))
+	}
+}
+
+func TestFilterAndLoggingMiddleware(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		messages []string
+		handled  []interface{}
+	)
+	lg := loglib.NewLeveledLogger(loglib.Debug, loglib.Options{
+		Backend: loglib.BackendFunc(func(level loglib.LogLevelEnum, msg string, keysAndValues []interface{}) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			messages = append(messages, level.String()+" "+msg)
+		})})
+
+	handler := eventuate.ChainMiddleware(
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			handled = append(handled, data)
+			return future.NewSuccess(data)
+		},
+		eventuate.FilterMiddleware(func(meta *eventuate.EventMetadata) bool {
+			return meta.EventType == EVT_TYPE_A
+		}),
+		eventuate.LoggingMiddleware(lg))
+
+	for _, evt := range []*eventDataAndMeta{
+		newEvent(AGG_TYPE, EVT_TYPE_A, 1, 1),
+		newEvent(AGG_TYPE, EVT_TYPE_B, 2, 1),
+		newEvent(AGG_TYPE, EVT_TYPE_A, 3, 1)} {
+		_, err := handler(evt.data, evt.meta).GetValue()
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, []interface{}{1, 3}, handled)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{
+		"DEBUG Handling event", "DEBUG Event handled",
+		"DEBUG Handling event", "DEBUG Event handled"}, messages)
+}
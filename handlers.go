@@ -2,6 +2,13 @@ package eventuate
 
 import "github.com/eventuate-clients/eventuate-client-golang/future"
 
+// AnyEntityType and AnyEventType register the handlers of the events of any entity type or any event type,
+// see AddEntityHandler and SetDefaultHandler
+const (
+	AnyEntityType = "*"
+	AnyEventType  = "*"
+)
+
 type EventResultHandler func(interface{}, *EventMetadata) future.Settler
 type EventResultHandlerMap map[string]map[string]EventResultHandler
 type DispatcherMaker func(eventHandlers *EventResultHandlerMap) (Dispatcher, error)
@@ -21,31 +28,66 @@ func (handlersMap *EventResultHandlerMap) AddHandler(aggType, eventType string,
 	return handlersMap
 }
 
+// AddEntityHandler registers the handler of the events of `aggType` having no handler of their own.
+// The subscriptions then receive all the events of `aggType`, whether their types are registered as type hints or not.
+func (handlersMap *EventResultHandlerMap) AddEntityHandler(aggType string, handler EventResultHandler) *EventResultHandlerMap {
+	return handlersMap.AddHandler(aggType, AnyEventType, handler)
+}
+
+// SetDefaultHandler registers the handler of the events having no other handler.
+// The subscriptions then receive all the events of the entity types registered with AddHandler or AddEntityHandler.
+func (handlersMap *EventResultHandlerMap) SetDefaultHandler(handler EventResultHandler) *EventResultHandlerMap {
+	return handlersMap.AddHandler(AnyEntityType, AnyEventType, handler)
+}
+
+// GetHandler looks the handler of the event type up, then the one of the entity type,
+// the one of the event type for any entity type and the default one
 func (handlersMap *EventResultHandlerMap) GetHandler(aggType, eventType string) (*EventResultHandler, error) {
-	_, hasEntityType := (*handlersMap)[aggType]
-	if !hasEntityType {
-		return nil, AppError("Event handler for entity type %s is not registered", aggType)
-	}
+	for _, key := range [][2]string{
+		{aggType, eventType},
+		{aggType, AnyEventType},
+		{AnyEntityType, eventType},
+		{AnyEntityType, AnyEventType}} {
 
-	handler, hasEventType := (*handlersMap)[aggType][eventType]
-	if !hasEventType {
-		return nil, AppError("Event handler for entity type/event type %s / %s is not registered",
-			aggType, eventType)
+		if handler, hasHandler := (*handlersMap)[key[0]][key[1]]; hasHandler {
+			return &handler, nil
+		}
 	}
 
-	return &handler, nil
+	if _, hasEntityType := (*handlersMap)[aggType]; !hasEntityType {
+		return nil, AppError("Event handler for entity type %s is not registered", aggType)
+	}
+	return nil, AppError("Event handler for entity type/event type %s / %s is not registered",
+		aggType, eventType)
 }
 
+// transformToEntityEventGroups lists the event types to subscribe to per entity type. The entity types having
+// a handler of any event type get an empty list: the server reads it as all the event types of the entity,
+// the ones unknown to the client included (the eventuatetest server does the same).
 func  (handlersMap *EventResultHandlerMap) transformToEntityEventGroups() map[string][]string {
+	anyEntity := (*handlersMap)[AnyEntityType]
+	_, hasDefault := anyEntity[AnyEventType]
+
 	result := make(map[string][]string)
-	for key1, val1 := range *handlersMap {
-		if _, hasKey := result[key1]; !hasKey {
-			result[key1] = []string{}
+	for aggType, handlers := range *handlersMap {
+		if aggType == AnyEntityType {
+			continue
 		}
-		for key2 := range val1 {
-			result[key1] = append(result[key1], key2)
+		if _, hasEntityHandler := handlers[AnyEventType]; hasEntityHandler || hasDefault {
+			result[aggType] = []string{}
+			continue
 		}
+
+		eventTypes := []string{}
+		for eventType := range handlers {
+			eventTypes = append(eventTypes, eventType)
+		}
+		for eventType := range anyEntity {
+			if _, hasHandler := handlers[eventType]; !hasHandler {
+				eventTypes = append(eventTypes, eventType)
+			}
+		}
+		result[aggType] = eventTypes
 	}
 	return result
 }
-
@@ -0,0 +1,115 @@
+package eventuate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventResultHandlerMap_GetHandler(t *testing.T) {
+	handlerOf := func(name string) eventuate.EventResultHandler {
+		return func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			return future.NewSuccess(name)
+		}
+	}
+	handlerName := func(handlers *eventuate.EventResultHandlerMap, aggType, eventType string) interface{} {
+		handler, err := handlers.GetHandler(aggType, eventType)
+		if err != nil {
+			return err.Error()
+		}
+		name, _ := (*handler)(nil, nil).GetValue()
+		return name
+	}
+
+	handlers := eventuate.NewEventResultHandlerMap().
+		AddHandler(AGG_TYPE, EVT_TYPE_A, handlerOf("exact")).
+		AddEntityHandler(AGG_TYPE, handlerOf("entity")).
+		AddHandler(eventuate.AnyEntityType, EVT_TYPE_B, handlerOf("event"))
+
+	assert.Equal(t, "exact", handlerName(handlers, AGG_TYPE, EVT_TYPE_A))
+	assert.Equal(t, "entity", handlerName(handlers, AGG_TYPE, EVT_TYPE_B))
+	assert.Equal(t, "event", handlerName(handlers, "OtherAggregate", EVT_TYPE_B))
+	assert.Contains(t, handlerName(handlers, "OtherAggregate", EVT_TYPE_A),
+		"Event handler for entity type OtherAggregate is not registered")
+
+	handlers.SetDefaultHandler(handlerOf("default"))
+	assert.Equal(t, "default", handlerName(handlers, "OtherAggregate", EVT_TYPE_A))
+	assert.Equal(t, "entity", handlerName(handlers, AGG_TYPE, "OtherEvent"))
+
+	wrapped := handlers.WithMiddleware(func(next eventuate.EventResultHandler) eventuate.EventResultHandler {
+		return func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			name, _ := next(data, meta).GetValue()
+			return future.NewSuccess("wrapped " + name.(string))
+		}
+	})
+	assert.Equal(t, "wrapped default", handlerName(wrapped, "OtherAggregate", EVT_TYPE_A))
+	assert.Equal(t, "wrapped entity", handlerName(wrapped, AGG_TYPE, "OtherEvent"))
+}
+
+func TestSubscribeAndDispatch_Wildcards(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	const OTHER_ENTITY_TYPE = "net.chrisrichardson.eventstore.example.OtherEntity"
+	const UNWATCHED_ENTITY_TYPE = "net.chrisrichardson.eventstore.example.UnwatchedEntity"
+	const EVENT_UNKNOWN = "net.chrisrichardson.eventstore.example.MyEntityWasForgotten"
+
+	repoClient := buildREST(t, srv.ClientBuilder())
+	// the handlers of any event type receive the events of the types the client has no type hints of as well
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}))
+
+	handled := make(chan string, 8)
+	handlerOf := func(name string) eventuate.EventResultHandler {
+		return func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			handled <- name + " " + meta.EventType
+			return future.NewSuccess(true)
+		}
+	}
+
+	_, err := stomp.SubscribeAndDispatch("default-only-subscriber",
+		eventuate.NewEventResultHandlerMap().SetDefaultHandler(handlerOf("default")), nil, false)
+	assert.Error(t, err)
+
+	handlers := eventuate.NewEventResultHandlerMap().
+		AddHandler(ENTITY_TYPE, EVENT_CREATED, handlerOf("created")).
+		AddEntityHandler(OTHER_ENTITY_TYPE, handlerOf("other")).
+		SetDefaultHandler(handlerOf("default"))
+	_, err = stomp.SubscribeAndDispatch("wildcard-subscriber", handlers, nil, false)
+	assert.Nil(t, err)
+
+	for _, entityType := range []string{ENTITY_TYPE, OTHER_ENTITY_TYPE, UNWATCHED_ENTITY_TYPE} {
+		_, err = repoClient.Save(entityType, []eventuate.EventTypeAndData{
+			{
+				EventType: EVENT_CREATED,
+				EventData: `{"name":"Marvin"}`},
+			{
+				EventType: EVENT_CHANGED,
+				EventData: `{"name":"Marvin"}`},
+			{
+				EventType: EVENT_UNKNOWN,
+				EventData: `{"name":"Marvin"}`}}, nil)
+		assert.Nil(t, err)
+	}
+
+	var names []string
+	for idx := 0; idx < 6; idx++ {
+		names = append(names, receive(t, handled))
+	}
+	assert.ElementsMatch(t, []string{
+		"created " + EVENT_CREATED,
+		"default " + EVENT_CHANGED,
+		"default " + EVENT_UNKNOWN,
+		"other " + EVENT_CREATED,
+		"other " + EVENT_CHANGED,
+		"other " + EVENT_UNKNOWN}, names)
+
+	select {
+	case name := <-handled:
+		t.Fatalf("unexpected event dispatched: %s", name)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,95 @@
+package eventuate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-resty/resty"
+)
+
+// HTTPOptions configures the HTTP client a RESTClient owns
+type HTTPOptions struct {
+	// Timeout of a request, none when zero
+	Timeout time.Duration
+	// Transport replaces the HTTP transport of the client, the proxy and TLS options are not applied to it
+	Transport http.RoundTripper
+	// ProxyURL is the proxy of the requests, taken from the environment (HTTP_PROXY) when empty
+	ProxyURL string
+	// TLSConfig is the base TLS configuration, the CA bundle and the client certificates are added to a copy of it
+	TLSConfig *tls.Config
+	// CABundleFile is a PEM file of the certificate authorities trusted instead of the system ones
+	CABundleFile       string
+	ClientCertificates []tls.Certificate
+	// RetryCount is the number of retries of the requests failed because the server was unavailable
+	RetryCount       int
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+	UserAgent        string
+}
+
+// newRestyClient makes the HTTP client of a RESTClient, isolated from the other clients
+func newRestyClient(credentials *Credentials, options HTTPOptions) (*resty.Client, *tls.Config, error) {
+	client := resty.New().
+		SetHeader("Content-Type", "application/json").
+		SetBasicAuth(credentials.apiKeyId, credentials.apiKeySecret)
+
+	if options.UserAgent != "" {
+		client.SetHeader("User-Agent", options.UserAgent)
+	}
+	if options.Timeout > 0 {
+		client.SetTimeout(options.Timeout)
+	}
+	if options.RetryCount > 0 {
+		client.SetRetryCount(options.RetryCount)
+	}
+	if options.RetryWaitTime > 0 {
+		client.SetRetryWaitTime(options.RetryWaitTime)
+	}
+	if options.RetryMaxWaitTime > 0 {
+		client.SetRetryMaxWaitTime(options.RetryMaxWaitTime)
+	}
+
+	if options.Transport != nil {
+		client.SetTransport(options.Transport)
+		return client, nil, nil
+	}
+
+	tlsConfig, tlsErr := options.tlsConfig()
+	if tlsErr != nil {
+		return nil, nil, tlsErr
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if options.ProxyURL != "" {
+		proxyUrl, proxyErr := url.Parse(options.ProxyURL)
+		if proxyErr != nil {
+			return nil, nil, AppError("Invalid proxy URL: %w", proxyErr)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+	client.SetTransport(transport)
+	return client, tlsConfig, nil
+}
+
+func (options HTTPOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+	if options.TLSConfig != nil {
+		config = options.TLSConfig.Clone()
+	}
+	if options.CABundleFile != "" {
+		pem, err := os.ReadFile(options.CABundleFile)
+		if err != nil {
+			return nil, AppError("Cannot read the CA bundle: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, AppError("No certificate found in the CA bundle %s", options.CABundleFile)
+		}
+	}
+	config.Certificates = append(config.Certificates, options.ClientCertificates...)
+	return config, nil
+}
@@ -0,0 +1,141 @@
+package eventuate_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// findServer answers the Find requests, after `handle` had a chance to inspect or fail them
+func findServer(handle func(w http.ResponseWriter, request *http.Request) bool) *httptest.Server {
+	mux, server := testServer()
+	mux.HandleFunc(fmt.Sprintf("/entity/%s/%s/%s", NAMESPACE, ENTITY_TYPE, ENTITY_ID),
+		func(w http.ResponseWriter, request *http.Request) {
+			if !handle(w, request) {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"events":[{"id":"%s","eventType":"%s","eventData":"%s"}],"triggeringEvents":[]}`,
+				eventuate.Int128FromString(EVENT_ID_1),
+				EVENT_CREATED,
+				EVENT_DATA_1_ESC)
+		})
+	return server
+}
+
+func find(client *eventuate.RESTClient) error {
+	_, err := client.Find(ENTITY_TYPE, eventuate.Int128FromString(ENTITY_ID), nil)
+	return err
+}
+
+func TestRESTClient_IsolatedCredentials(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		users []string
+	)
+	server := findServer(func(w http.ResponseWriter, request *http.Request) bool {
+		user, _, _ := request.BasicAuth()
+		mutex.Lock()
+		users = append(users, user)
+		mutex.Unlock()
+		return true
+	})
+	defer server.Close()
+
+	first, firstErr := eventuate.ClientBuilder().WithUrl(server.URL).WithSpace(NAMESPACE).
+		WithCredentials("first", "secret").BuildREST()
+	second, secondErr := eventuate.ClientBuilder().WithUrl(server.URL).WithSpace(NAMESPACE).
+		WithCredentials("second", "secret").BuildREST()
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	first.SetInsecureSkipVerify(true)
+	second.SetInsecureSkipVerify(true)
+
+	assert.NoError(t, find(first))
+	assert.NoError(t, find(second))
+	assert.NoError(t, find(first))
+
+	assert.Equal(t, []string{"first", "second", "first"}, users)
+}
+
+func TestRESTClient_CABundleAndUserAgent(t *testing.T) {
+	var userAgent string
+	server := findServer(func(w http.ResponseWriter, request *http.Request) bool {
+		userAgent = request.UserAgent()
+		return true
+	})
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(bundle, pemBytes, 0600))
+
+	client, clientErr := eventuate.ClientBuilder().WithUrl(server.URL).WithSpace(NAMESPACE).
+		WithCredentials("123", "456").
+		WithCABundle(bundle).
+		WithUserAgent("orders-service/1.0").
+		BuildREST()
+	assert.NoError(t, clientErr)
+
+	assert.NoError(t, find(client))
+	assert.Equal(t, "orders-service/1.0", userAgent)
+
+	_, clientErr = eventuate.ClientBuilder().WithCredentials("123", "456").
+		WithCABundle(filepath.Join(t.TempDir(), "missing.pem")).
+		BuildREST()
+	assert.Error(t, clientErr)
+}
+
+func TestRESTClient_RetriesAndTimeout(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		attempts int
+	)
+	server := findServer(func(w http.ResponseWriter, request *http.Request) bool {
+		mutex.Lock()
+		attempts++
+		attempt := attempts
+		mutex.Unlock()
+
+		switch attempt {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return false
+		case 3:
+			time.Sleep(200 * time.Millisecond)
+		}
+		return true
+	})
+	defer server.Close()
+
+	metrics := eventuatetest.NewMetricsRecorder()
+	client, clientErr := eventuate.ClientBuilder().WithUrl(server.URL).WithSpace(NAMESPACE).
+		WithCredentials("123", "456").
+		WithRetries(2, time.Millisecond, 10*time.Millisecond).
+		WithTimeout(50 * time.Millisecond).
+		WithMetricsRecorder(metrics).
+		BuildREST()
+	assert.NoError(t, clientErr)
+	client.SetInsecureSkipVerify(true)
+
+	assert.NoError(t, find(client))
+	assert.Equal(t, 1, metrics.Retries(eventuate.MetricsOperationFind))
+
+	client, _ = eventuate.ClientBuilder().WithUrl(server.URL).WithSpace(NAMESPACE).
+		WithCredentials("123", "456").
+		WithTimeout(50 * time.Millisecond).
+		BuildREST()
+	client.SetInsecureSkipVerify(true)
+
+	assert.Error(t, find(client))
+}
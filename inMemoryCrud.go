@@ -0,0 +1,258 @@
+package eventuate
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// InMemoryCrud is a process-local implementation of the Crud interface.
+// It mimics the Eventuate server semantics (generated ids, versions and conflicts),
+// which makes it suitable for tests and local development.
+type InMemoryCrud struct {
+	sync.RWMutex
+	epoch    uint64
+	sequence uint64
+	entities map[EntityIdAndType]*inMemoryEntity
+	// triggering events used for entity creation, keyed by aggregate type
+	createdBy map[string]map[EventContext]Int128
+}
+
+type inMemoryEntity struct {
+	events           []EventIdTypeAndData
+	triggeringEvents map[EventContext]bool
+	snapshot         *SerializedSnapshotWithVersion
+	// number of events covered by the snapshot
+	snapshotEvents int
+}
+
+func NewInMemoryCrud() *InMemoryCrud {
+	return &InMemoryCrud{
+		epoch:     uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		entities:  make(map[EntityIdAndType]*inMemoryEntity),
+		createdBy: make(map[string]map[EventContext]Int128)}
+}
+
+func (crud *InMemoryCrud) Find(
+	aggregateType string,
+	entityId Int128,
+	findOptions *AggregateCrudFindOptions) (*LoadedEvents, error) {
+
+	crud.RLock()
+	defer crud.RUnlock()
+
+	entity, hasEntity := crud.entities[EntityIdAndType{
+		EntityType: aggregateType,
+		EntityId:   entityId}]
+	if !hasEntity {
+		return nil, NewRestError(http.StatusNotFound, "",
+			"InMemoryCrud.Find: entity %s/%s", aggregateType, entityId).
+			forEntity(aggregateType, entityId, Int128Nil)
+	}
+
+	if findOptions != nil && findOptions.TriggeringEvent != nil {
+		if entity.triggeringEvents[*findOptions.TriggeringEvent] {
+			return nil, NewRestError(http.StatusConflict, "duplicate_event",
+				"InMemoryCrud.Find: entity %s/%s, triggering event: %s",
+				aggregateType, entityId, *findOptions.TriggeringEvent).
+				forEntity(aggregateType, entityId, Int128Nil)
+		}
+	}
+
+	events := make([]EventIdTypeAndData, len(entity.events)-entity.snapshotEvents)
+	copy(events, entity.events[entity.snapshotEvents:])
+
+	return &LoadedEvents{
+		Events:   events,
+		Snapshot: entity.snapshot}, nil
+}
+
+func (crud *InMemoryCrud) Save(
+	aggregateType string,
+	events []EventTypeAndData,
+	saveOptions *AggregateCrudSaveOptions) (*EntityIdVersionAndEventIds, error) {
+
+	crud.Lock()
+	defer crud.Unlock()
+
+	var (
+		entityId        Int128
+		triggeringEvent *EventContext
+	)
+	if saveOptions != nil {
+		entityId = saveOptions.EntityId
+		triggeringEvent = saveOptions.TriggeringEvent
+
+		var metadataErr error
+		if events, metadataErr = withEventMetadata(events, saveOptions.EventMetadata); metadataErr != nil {
+			return nil, metadataErr
+		}
+	}
+
+	if triggeringEvent != nil {
+		if createdId, isDuplicate := crud.createdBy[aggregateType][*triggeringEvent]; isDuplicate {
+			return nil, NewRestError(http.StatusConflict, "duplicate_event",
+				"InMemoryCrud.Save: entity %s/%s, triggering event: %s",
+				aggregateType, createdId, *triggeringEvent).
+				forEntity(aggregateType, createdId, Int128Nil)
+		}
+	}
+
+	if entityId.IsNil() {
+		entityId = crud.nextId()
+	}
+
+	idAndType := EntityIdAndType{
+		EntityType: aggregateType,
+		EntityId:   entityId}
+
+	if _, exists := crud.entities[idAndType]; exists {
+		return nil, NewRestError(http.StatusConflict, "entity_exists",
+			"InMemoryCrud.Save: entity %s/%s", aggregateType, entityId).
+			forEntity(aggregateType, entityId, Int128Nil)
+	}
+
+	entity := &inMemoryEntity{
+		triggeringEvents: make(map[EventContext]bool)}
+	crud.entities[idAndType] = entity
+
+	if triggeringEvent != nil {
+		entity.triggeringEvents[*triggeringEvent] = true
+		if _, hasType := crud.createdBy[aggregateType]; !hasType {
+			crud.createdBy[aggregateType] = make(map[EventContext]Int128)
+		}
+		crud.createdBy[aggregateType][*triggeringEvent] = entityId
+	}
+
+	return crud.appendEvents(entityId, entity, events), nil
+}
+
+func (crud *InMemoryCrud) Update(
+	entityIdAndType EntityIdAndType,
+	entityVersion Int128,
+	events []EventTypeAndData,
+	updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error) {
+
+	crud.Lock()
+	defer crud.Unlock()
+
+	entity, hasEntity := crud.entities[entityIdAndType]
+	if !hasEntity {
+		return nil, NewRestError(http.StatusNotFound, "",
+			"InMemoryCrud.Update: entity %s/%s",
+			entityIdAndType.EntityType, entityIdAndType.EntityId).
+			forEntity(entityIdAndType.EntityType, entityIdAndType.EntityId, entityVersion)
+	}
+
+	if updateOptions != nil && updateOptions.TriggeringEvent != nil {
+		if entity.triggeringEvents[*updateOptions.TriggeringEvent] {
+			return nil, NewRestError(http.StatusConflict, "duplicate_event",
+				"InMemoryCrud.Update: entity %s/%s, triggering event: %s",
+				entityIdAndType.EntityType, entityIdAndType.EntityId, *updateOptions.TriggeringEvent).
+				forEntity(entityIdAndType.EntityType, entityIdAndType.EntityId, entityVersion)
+		}
+	}
+
+	currentVersion := entity.version()
+	if currentVersion != entityVersion {
+		return nil, NewRestError(http.StatusConflict, "optimistic_lock_error",
+			"InMemoryCrud.Update: entity %s/%s, expected version: %s, actual version: %s",
+			entityIdAndType.EntityType, entityIdAndType.EntityId, entityVersion, currentVersion).
+			forEntity(entityIdAndType.EntityType, entityIdAndType.EntityId, entityVersion)
+	}
+
+	if updateOptions != nil {
+		var metadataErr error
+		if events, metadataErr = withEventMetadata(events, updateOptions.EventMetadata); metadataErr != nil {
+			return nil, metadataErr
+		}
+	}
+
+	if updateOptions != nil && updateOptions.TriggeringEvent != nil {
+		entity.triggeringEvents[*updateOptions.TriggeringEvent] = true
+	}
+
+	result := crud.appendEvents(entityIdAndType.EntityId, entity, events)
+
+	if updateOptions != nil && updateOptions.SerializedSnapshot != nil {
+		snapshot := *updateOptions.SerializedSnapshot
+		entity.snapshot = &SerializedSnapshotWithVersion{
+			SerializedSnapshot: &snapshot,
+			EntityVersion:      result.EntityVersion}
+		entity.snapshotEvents = len(entity.events)
+	}
+
+	return result, nil
+}
+
+// FindCtx, SaveCtx and UpdateCtx complete right away, the context is only checked upfront
+
+func (crud *InMemoryCrud) FindCtx(
+	ctx context.Context,
+	aggregateType string,
+	entityId Int128,
+	findOptions *AggregateCrudFindOptions) (*LoadedEvents, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return crud.Find(aggregateType, entityId, findOptions)
+}
+
+func (crud *InMemoryCrud) SaveCtx(
+	ctx context.Context,
+	aggregateType string,
+	events []EventTypeAndData,
+	saveOptions *AggregateCrudSaveOptions) (*EntityIdVersionAndEventIds, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return crud.Save(aggregateType, events, saveOptions)
+}
+
+func (crud *InMemoryCrud) UpdateCtx(
+	ctx context.Context,
+	entityIdAndType EntityIdAndType,
+	entityVersion Int128,
+	events []EventTypeAndData,
+	updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return crud.Update(entityIdAndType, entityVersion, events, updateOptions)
+}
+
+func (crud *InMemoryCrud) appendEvents(
+	entityId Int128,
+	entity *inMemoryEntity,
+	events []EventTypeAndData) *EntityIdVersionAndEventIds {
+
+	eventIds := make([]Int128, len(events))
+	for idx, event := range events {
+		eventIds[idx] = crud.nextId()
+		entity.events = append(entity.events, EventIdTypeAndData{
+			EventId:          eventIds[idx],
+			EventTypeAndData: event})
+	}
+
+	return &EntityIdVersionAndEventIds{
+		EntityId:      entityId,
+		EntityVersion: entity.version(),
+		EventIds:      eventIds}
+}
+
+// nextId produces ids that grow monotonically within this InMemoryCrud
+func (crud *InMemoryCrud) nextId() Int128 {
+	crud.sequence++
+	return Int128{crud.epoch, crud.sequence}
+}
+
+func (entity *inMemoryEntity) version() Int128 {
+	if len(entity.events) == 0 {
+		return Int128Nil
+	}
+	return entity.events[len(entity.events)-1].EventId
+}
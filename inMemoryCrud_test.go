@@ -0,0 +1,176 @@
+package eventuate_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+const COUNTER_ENTITY = "net.chrisrichardson.eventstore.example.CounterEntity"
+const COUNTER_INCREMENTED = "net.chrisrichardson.eventstore.example.CounterIncrementedEvent"
+
+type CounterAggregate struct {
+	Total int
+}
+
+type IncrementCommand struct {
+	Amount int
+}
+
+type CounterIncrementedEvent struct {
+	Amount int
+}
+
+func NewCounterAggregate() *CounterAggregate {
+	return &CounterAggregate{}
+}
+
+func (counter *CounterAggregate) ProcessIncrementCommand(cmd *IncrementCommand) []eventuate.Event {
+	return []eventuate.Event{&CounterIncrementedEvent{Amount: cmd.Amount}}
+}
+
+func (counter *CounterAggregate) ApplyCounterIncrementedEvent(evt *CounterIncrementedEvent) *CounterAggregate {
+	counter.Total += evt.Amount
+	return counter
+}
+
+func TestInMemoryCrud_SaveFindUpdate(t *testing.T) {
+	crud := eventuate.NewInMemoryCrud()
+
+	created, err := crud.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: EVENT_DATA_1}}, nil)
+	assertNoError(t, err)
+	assert.False(t, created.EntityId.IsNil())
+	assert.Equal(t, created.EventIds[0], created.EntityVersion)
+
+	updated, err := crud.Update(eventuate.EntityIdAndType{
+		EntityType: ENTITY_TYPE,
+		EntityId:   created.EntityId},
+		created.EntityVersion,
+		[]eventuate.EventTypeAndData{
+			{
+				EventType: EVENT_CHANGED,
+				EventData: EVENT_DATA_2}}, nil)
+	assertNoError(t, err)
+	assert.Equal(t, created.EntityId, updated.EntityId)
+	assert.True(t, updated.EntityVersion.String() > created.EntityVersion.String())
+
+	loaded, err := crud.Find(ENTITY_TYPE, created.EntityId, nil)
+	assertNoError(t, err)
+
+	assert.Equal(t, &eventuate.LoadedEvents{
+		Events: []eventuate.EventIdTypeAndData{
+			{
+				EventId: created.EntityVersion,
+				EventTypeAndData: eventuate.EventTypeAndData{
+					EventType: EVENT_CREATED,
+					EventData: EVENT_DATA_1}},
+			{
+				EventId: updated.EntityVersion,
+				EventTypeAndData: eventuate.EventTypeAndData{
+					EventType: EVENT_CHANGED,
+					EventData: EVENT_DATA_2}}}}, loaded)
+}
+
+func TestInMemoryCrud_Conflicts(t *testing.T) {
+	crud := eventuate.NewInMemoryCrud()
+	entityId := eventuate.Int128FromString(ENTITY_ID)
+	triggeringEvent := eventuate.EventContext("token-1")
+	events := []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: EVENT_DATA_1}}
+
+	created, err := crud.Save(ENTITY_TYPE, events, &eventuate.AggregateCrudSaveOptions{
+		EntityId: entityId})
+	assertNoError(t, err)
+	assert.Equal(t, entityId, created.EntityId)
+
+	_, err = crud.Save(ENTITY_TYPE, events, &eventuate.AggregateCrudSaveOptions{
+		EntityId: entityId})
+	assertConflict(t, err, "entity already exists")
+
+	idAndType := eventuate.EntityIdAndType{
+		EntityType: ENTITY_TYPE,
+		EntityId:   entityId}
+
+	_, err = crud.Update(idAndType, eventuate.Int128FromString(EVENT_ID_1), events, nil)
+	assertConflict(t, err, "optimistic locking")
+
+	updated, err := crud.Update(idAndType, created.EntityVersion, events, &eventuate.AggregateCrudUpdateOptions{
+		TriggeringEvent: &triggeringEvent})
+	assertNoError(t, err)
+
+	_, err = crud.Update(idAndType, updated.EntityVersion, events, &eventuate.AggregateCrudUpdateOptions{
+		TriggeringEvent: &triggeringEvent})
+	assertConflict(t, err, "duplicate triggering Event")
+
+	_, err = crud.Find(ENTITY_TYPE, entityId, &eventuate.AggregateCrudFindOptions{
+		TriggeringEvent: &triggeringEvent})
+	assertConflict(t, err, "duplicate triggering Event")
+
+	_, err = crud.Find(ENTITY_TYPE, eventuate.Int128FromString(EVENT_ID_2), nil)
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "Resource is not found"), err.Error())
+	}
+}
+
+func TestInMemoryCrud_AggregateRepository(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+
+	repo := eventuate.NewAggregateRepository(eventuate.NewInMemoryCrud(), meta)
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+
+	saved, err := repo.Save(&IncrementCommand{Amount: 2})
+	assertNoError(t, err)
+
+	_, err = repo.Update(saved.EntityId, &IncrementCommand{Amount: 3})
+	assertNoError(t, err)
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, 5, found.EntityInstance.(*CounterAggregate).Total)
+}
+
+func TestInMemoryCrud_AggregateRepositoryTriggeringEvents(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+
+	repo := eventuate.NewAggregateRepository(eventuate.NewInMemoryCrud(), meta)
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+
+	createdBy := eventuate.EventContext("token-1")
+	saved, err := repo.SaveWithOptions(&IncrementCommand{Amount: 2}, &eventuate.SaveOptions{
+		TriggeringEvent: &createdBy})
+	assertNoError(t, err)
+	assert.False(t, saved.AlreadyProcessed)
+
+	duplicate, err := repo.SaveWithOptions(&IncrementCommand{Amount: 2}, &eventuate.SaveOptions{
+		TriggeringEvent: &createdBy})
+	assertNoError(t, err)
+	assert.True(t, duplicate.AlreadyProcessed)
+
+	updatedBy := eventuate.EventContext("token-2")
+	for _, expectProcessed := range []bool{false, true} {
+		updated, err := repo.UpdateWithOptions(saved.EntityId, &IncrementCommand{Amount: 3}, &eventuate.UpdateOptions{
+			TriggeringEvent: &updatedBy})
+		assertNoError(t, err)
+		assert.Equal(t, expectProcessed, updated.AlreadyProcessed)
+		assert.Equal(t, saved.EntityId, updated.EntityId)
+	}
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, 5, found.EntityInstance.(*CounterAggregate).Total)
+}
+
+func assertConflict(t *testing.T, err error, message string) {
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), message), err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package eventuate
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/gmallard/stompngo"
@@ -23,12 +24,53 @@ type Crud interface {
 		updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error)
 }
 
+// CrudCtx is a Crud which can be cancelled through a context.
+// AggregateRepository prefers it over the plain Crud methods.
+type CrudCtx interface {
+	Crud
+	FindCtx(
+		ctx context.Context,
+		aggregateType string,
+		entityId Int128,
+		findOptions *AggregateCrudFindOptions) (*LoadedEvents, error)
+	SaveCtx(
+		ctx context.Context,
+		aggregateType string,
+		events []EventTypeAndData,
+		saveOptions *AggregateCrudSaveOptions) (*EntityIdVersionAndEventIds, error)
+	UpdateCtx(
+		ctx context.Context,
+		entityIdAndType EntityIdAndType,
+		entityVersion Int128,
+		events []EventTypeAndData,
+		updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error)
+}
+
 type Repository interface {
 	Save(cmd Command) (*EntityMetadata, error)
 	Update(entityId Int128, cmd Command) (*EntityMetadata, error)
 	Find(entityId Int128) (*EntityMetadata, error)
 }
 
+// RepositoryWithOptions is a Repository taking the options of the saves and updates, e.g. their triggering events.
+// AggregateRepository implements it.
+type RepositoryWithOptions interface {
+	Repository
+	SaveWithOptions(cmd Command, saveOptions *SaveOptions) (*EntityMetadata, error)
+	UpdateWithOptions(entityId Int128, cmd Command, updateOptions *UpdateOptions) (*EntityMetadata, error)
+}
+
+// RepositoryCtx is a RepositoryWithOptions which can be cancelled through a context.
+// AggregateRepository implements it.
+type RepositoryCtx interface {
+	RepositoryWithOptions
+	SaveCtx(ctx context.Context, cmd Command, saveOptions *SaveOptions) (*EntityMetadata, error)
+	UpdateCtx(ctx context.Context, entityId Int128, cmd Command, updateOptions *UpdateOptions) (*EntityMetadata, error)
+	FindCtx(ctx context.Context, entityId Int128) (*EntityMetadata, error)
+}
+
+var _ RepositoryCtx = (*AggregateRepository)(nil)
+
 type Dispatcher interface {
 	Dispatch(interface{}, *EventMetadata) future.Settler
 }
@@ -47,6 +89,27 @@ type Subscriber interface {
 		useSwimlane bool) (*DispatchingSubscription, error)
 }
 
+// SubscriberCtx is a Subscriber whose subscriptions are bound to a context.
+// StompClient implements it.
+type SubscriberCtx interface {
+	Subscriber
+	SubscribeCtx(
+		ctx context.Context,
+		subscriberId string,
+		aggregatesAndEvents map[string][]string,
+		subscriberOptions *SubscriberOptions,
+		handler *EventResultHandler) (*Subscription, error)
+
+	SubscribeAndDispatchCtx(
+		ctx context.Context,
+		subscriberId string,
+		eventHandlers *EventResultHandlerMap,
+		subscriberOptions *SubscriberOptions,
+		useSwimlane bool) (*DispatchingSubscription, error)
+}
+
+var _ SubscriberCtx = (*StompClient)(nil)
+
 type SubscriberDispatcher interface {
 	Subscribe(
 		subscriberId string,
@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// NewSlogBackend writes the messages to `logger`
+func NewSlogBackend(logger *slog.Logger) Backend {
+	return BackendFunc(func(level LogLevelEnum, msg string, keysAndValues []interface{}) {
+		logger.Log(context.Background(), slogLevel(level), msg, keysAndValues...)
+	})
+}
+
+func slogLevel(level LogLevelEnum) slog.Level {
+	switch level {
+	case Error:
+		return slog.LevelError
+	case Warn:
+		return slog.LevelWarn
+	case Info:
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// NewTextBackend writes the messages to `w` as `key=value` lines
+func NewTextBackend(w io.Writer) Backend {
+	return NewSlogBackend(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})))
+}
+
+// NewPrintfBackend writes the messages to a Printf logger (e.g. *log.Logger), fields appended as `key=value`
+func NewPrintfBackend(printer Logger) Backend {
+	return BackendFunc(func(level LogLevelEnum, msg string, keysAndValues []interface{}) {
+		args := []interface{}{level, msg}
+		format := "%v %s"
+		for idx := 0; idx < len(keysAndValues); idx += 2 {
+			if idx+1 < len(keysAndValues) {
+				format += " %v=%v"
+				args = append(args, keysAndValues[idx], keysAndValues[idx+1])
+			} else {
+				format += " %v"
+				args = append(args, keysAndValues[idx])
+			}
+		}
+		printer.Printf(format, args...)
+	})
+}
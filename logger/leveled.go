@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Keys of the fields attached to the messages of the client
+const (
+	KeySubscriberId   = "subscriber_id"
+	KeySubscriptionId = "subscription_id"
+	KeyEntityType     = "entity_type"
+	KeyEntityId       = "entity_id"
+	KeyEventType      = "event_type"
+	KeyEventId        = "event_id"
+	KeySwimlane       = "swimlane"
+	KeyError          = "error"
+	KeyUrl            = "url"
+	KeyStatus         = "status"
+	// payload fields, see Options.RedactPayloads
+	KeyEvents       = "events"
+	KeyEventData    = "event_data"
+	KeyRequestBody  = "request_body"
+	KeyResponseBody = "response_body"
+)
+
+// PayloadKeys are the fields holding event payloads
+var PayloadKeys = []string{KeyEvents, KeyEventData, KeyRequestBody, KeyResponseBody}
+
+// LeveledLogger logs messages with key-value fields, e.g.
+//
+//	lg.Warn("Event handler failed", KeyEventId, evt.Id, KeyError, err)
+//
+// Its Println and Printf log at the Debug level.
+type LeveledLogger interface {
+	Logger
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+	// With returns a logger adding the fields to every message
+	With(keysAndValues ...interface{}) LeveledLogger
+	Enabled(level LogLevelEnum) bool
+}
+
+// Backend writes the messages of a LeveledLogger
+type Backend interface {
+	Log(level LogLevelEnum, msg string, keysAndValues []interface{})
+}
+
+// BackendFunc makes a Backend out of a function
+type BackendFunc func(level LogLevelEnum, msg string, keysAndValues []interface{})
+
+func (fn BackendFunc) Log(level LogLevelEnum, msg string, keysAndValues []interface{}) {
+	fn(level, msg, keysAndValues)
+}
+
+// Options of NewLeveledLogger
+type Options struct {
+	// Backend writes the messages, stderr when nil
+	Backend Backend
+	// RedactPayloads replaces the event payloads and the request and response bodies with their size
+	RedactPayloads bool
+	// RedactKeys lists further fields to replace
+	RedactKeys []string
+}
+
+type leveledLogger struct {
+	level   LogLevelEnum
+	backend Backend
+	redact  map[string]bool
+	fields  []interface{}
+}
+
+func NewLeveledLogger(level LogLevelEnum, options Options) LeveledLogger {
+	if level == Silent {
+		return NewNilLogger()
+	}
+
+	backend := options.Backend
+	if backend == nil {
+		backend = NewTextBackend(os.Stderr)
+	}
+	redact := make(map[string]bool)
+	if options.RedactPayloads {
+		for _, key := range PayloadKeys {
+			redact[key] = true
+		}
+	}
+	for _, key := range options.RedactKeys {
+		redact[key] = true
+	}
+
+	return &leveledLogger{
+		level:   level,
+		backend: backend,
+		redact:  redact}
+}
+
+func (lg *leveledLogger) Println(v ...interface{}) {
+	if lg.Enabled(Debug) {
+		lg.log(Debug, strings.TrimSuffix(fmt.Sprintln(v...), "\n"), nil)
+	}
+}
+
+func (lg *leveledLogger) Printf(format string, v ...interface{}) {
+	if lg.Enabled(Debug) {
+		lg.log(Debug, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"), nil)
+	}
+}
+
+func (lg *leveledLogger) Debug(msg string, keysAndValues ...interface{}) {
+	lg.log(Debug, msg, keysAndValues)
+}
+
+func (lg *leveledLogger) Info(msg string, keysAndValues ...interface{}) {
+	lg.log(Info, msg, keysAndValues)
+}
+
+func (lg *leveledLogger) Warn(msg string, keysAndValues ...interface{}) {
+	lg.log(Warn, msg, keysAndValues)
+}
+
+func (lg *leveledLogger) Error(msg string, keysAndValues ...interface{}) {
+	lg.log(Error, msg, keysAndValues)
+}
+
+func (lg *leveledLogger) With(keysAndValues ...interface{}) LeveledLogger {
+	result := *lg
+	result.fields = append(append([]interface{}(nil), lg.fields...), keysAndValues...)
+	return &result
+}
+
+func (lg *leveledLogger) Enabled(level LogLevelEnum) bool {
+	return lg.level.Enables(level)
+}
+
+func (lg *leveledLogger) log(level LogLevelEnum, msg string, keysAndValues []interface{}) {
+	if !lg.Enabled(level) {
+		return
+	}
+
+	fields := make([]interface{}, 0, len(lg.fields)+len(keysAndValues))
+	fields = append(fields, lg.fields...)
+	fields = append(fields, keysAndValues...)
+	for idx := 0; idx+1 < len(fields); idx += 2 {
+		if key, isString := fields[idx].(string); isString && lg.redact[key] {
+			fields[idx+1] = redacted(fields[idx+1])
+		}
+	}
+	lg.backend.Log(level, msg, fields)
+}
+
+func redacted(value interface{}) string {
+	switch payload := value.(type) {
+	case string:
+		return fmt.Sprintf("[redacted %d bytes]", len(payload))
+	case []byte:
+		return fmt.Sprintf("[redacted %d bytes]", len(payload))
+	}
+	return "[redacted]"
+}
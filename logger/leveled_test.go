@@ -0,0 +1,72 @@
+package logger_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+type logRecord struct {
+	level         loglib.LogLevelEnum
+	msg           string
+	keysAndValues []interface{}
+}
+
+func recordingLogger(level loglib.LogLevelEnum, options loglib.Options) (loglib.LeveledLogger, *[]logRecord) {
+	records := new([]logRecord)
+	options.Backend = loglib.BackendFunc(func(level loglib.LogLevelEnum, msg string, keysAndValues []interface{}) {
+		*records = append(*records, logRecord{level, msg, keysAndValues})
+	})
+	return loglib.NewLeveledLogger(level, options), records
+}
+
+func TestLeveledLogger_Levels(t *testing.T) {
+	lg, records := recordingLogger(loglib.Warn, loglib.Options{})
+
+	lg.Debug("debug")
+	lg.Printf("printf %d", 1)
+	lg.Info("info")
+	lg.Warn("warn")
+	lg.Error("error")
+
+	if assert.Len(t, *records, 2) {
+		assert.Equal(t, loglib.Warn, (*records)[0].level)
+		assert.Equal(t, loglib.Error, (*records)[1].level)
+	}
+	assert.False(t, lg.Enabled(loglib.Info))
+	assert.True(t, loglib.Verbose.Enables(loglib.Debug))
+	assert.False(t, loglib.Silent.Enables(loglib.Error))
+}
+
+func TestLeveledLogger_FieldsAndRedaction(t *testing.T) {
+	lg, records := recordingLogger(loglib.Debug, loglib.Options{
+		RedactPayloads: true,
+		RedactKeys:     []string{"email"}})
+
+	subLg := lg.With(loglib.KeySubscriberId, "subscriber")
+	subLg.Debug("Event received", loglib.KeyEventId, "1", loglib.KeyEventData, `{"name":"Arthur"}`, "email", "arthur@example.com")
+	lg.Printf("legacy %s", "message")
+
+	if assert.Len(t, *records, 2) {
+		assert.Equal(t, []interface{}{
+			loglib.KeySubscriberId, "subscriber",
+			loglib.KeyEventId, "1",
+			loglib.KeyEventData, "[redacted 17 bytes]",
+			"email", "[redacted 18 bytes]"}, (*records)[0].keysAndValues)
+		assert.Equal(t, "legacy message", (*records)[1].msg)
+		assert.Equal(t, loglib.Debug, (*records)[1].level)
+	}
+}
+
+func TestSlogBackend(t *testing.T) {
+	var out bytes.Buffer
+	lg := loglib.NewLeveledLogger(loglib.Info, loglib.Options{
+		Backend: loglib.NewSlogBackend(slog.New(slog.NewTextHandler(&out, nil)))})
+
+	lg.Warn("Event handler failed", loglib.KeyEventId, "1")
+
+	assert.Contains(t, out.String(), `level=WARN msg="Event handler failed" event_id=1`)
+}
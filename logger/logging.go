@@ -14,31 +14,84 @@ type LogLevelEnum int
 
 const (
 	Silent LogLevelEnum = iota
+	// Verbose logs every message, as Debug does
 	Verbose
+	Error
+	Warn
+	Info
+	Debug
 )
 
+// verbosity orders the levels from Silent (nothing logged) to Debug (everything logged)
+func (level LogLevelEnum) verbosity() int {
+	switch level {
+	case Silent:
+		return 0
+	case Error:
+		return 1
+	case Warn:
+		return 2
+	case Info:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// Enables tells whether a logger of this level logs the messages of `msgLevel`
+func (level LogLevelEnum) Enables(msgLevel LogLevelEnum) bool {
+	return level != Silent && msgLevel.verbosity() <= level.verbosity()
+}
+
+func (level LogLevelEnum) String() string {
+	switch level {
+	case Silent:
+		return "SILENT"
+	case Error:
+		return "ERROR"
+	case Warn:
+		return "WARN"
+	case Info:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
 type nilLogger struct{}
 
 func NewNilLogger() *nilLogger {
 	return &nilLogger{}
 }
 
-func (*nilLogger) Println(v ...interface{})               {}
-func (*nilLogger) Printf(format string, v ...interface{}) {}
+func (*nilLogger) Println(v ...interface{})                       {}
+func (*nilLogger) Printf(format string, v ...interface{})         {}
+func (*nilLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (*nilLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (*nilLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (*nilLogger) Error(msg string, keysAndValues ...interface{}) {}
+func (*nilLogger) Enabled(level LogLevelEnum) bool                { return false }
+
+func (lg *nilLogger) With(keysAndValues ...interface{}) LeveledLogger {
+	return lg
+}
 
-func NewLogger(level LogLevelEnum) Logger {
+// NewLogger logs the messages of `level` (and the more severe ones) to stderr
+func NewLogger(level LogLevelEnum) LeveledLogger {
 	switch level {
 	case Silent:
 		{
 			return NewNilLogger()
 		}
 	case Verbose:
-		fallthrough
-	default:
 		{
 			//return log.New(os.Stdout, "#LL# ", 0)
-			return log.New(os.Stderr, "#EV#: ", log.LstdFlags)
+			return NewLeveledLogger(level, Options{
+				Backend: NewPrintfBackend(log.New(os.Stderr, "#EV#: ", log.LstdFlags))})
+		}
+	default:
+		{
+			return NewLeveledLogger(level, Options{})
 		}
-
 	}
 }
@@ -0,0 +1,94 @@
+package eventuate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Operations the REST calls are recorded under
+const (
+	MetricsOperationFind   = "Find"
+	MetricsOperationSave   = "Save"
+	MetricsOperationUpdate = "Update"
+)
+
+// MetricsStatusError is the status of the REST calls failed without a response of the server
+const MetricsStatusError = "error"
+
+// MetricsRecorder receives the measurements of the REST calls, the subscriptions and the swimlane dispatchers.
+// The prometheusmetrics package implements it with Prometheus.
+type MetricsRecorder interface {
+	// ObserveRequest records the duration of a REST call, `status` is the HTTP status code of the response
+	ObserveRequest(operation, status string, duration time.Duration)
+	// CountConflict counts the 409 responses by their Eventuate conflict code (e.g. `optimistic_lock_error`)
+	CountConflict(operation, conflict string)
+	// CountRetry counts the REST calls retried because the server was unavailable
+	CountRetry(operation string)
+	CountEventsReceived(subscriberId string, count int)
+	CountEventsAcked(subscriberId string, count int)
+	CountEventsFailed(subscriberId string, count int)
+	// SetPendingAcks reports the number of events of a subscription awaiting their acks
+	SetPendingAcks(subscriberId string, count int)
+	// SetSwimlaneQueueDepth reports the number of events queued in a swimlane of an EventTypeSwimlaneDispatcher
+	SetSwimlaneQueueDepth(subscriberId, entityType string, swimlane, depth int)
+}
+
+// NoopMetricsRecorder is the recorder of the clients without metrics
+type NoopMetricsRecorder struct{}
+
+func (NoopMetricsRecorder) ObserveRequest(operation, status string, duration time.Duration) {}
+func (NoopMetricsRecorder) CountConflict(operation, conflict string)                        {}
+func (NoopMetricsRecorder) CountRetry(operation string)                                     {}
+func (NoopMetricsRecorder) CountEventsReceived(subscriberId string, count int)              {}
+func (NoopMetricsRecorder) CountEventsAcked(subscriberId string, count int)                 {}
+func (NoopMetricsRecorder) CountEventsFailed(subscriberId string, count int)                {}
+func (NoopMetricsRecorder) SetPendingAcks(subscriberId string, count int)                   {}
+func (NoopMetricsRecorder) SetSwimlaneQueueDepth(subscriberId, entityType string, swimlane, depth int) {
+}
+
+func metricsOrNoop(recorder MetricsRecorder) MetricsRecorder {
+	if recorder == nil {
+		return NoopMetricsRecorder{}
+	}
+	return recorder
+}
+
+type metricsOperationKey struct{}
+
+// withMetricsOperation tags the context of a REST call with its operation, for the retry condition to find it
+func withMetricsOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, metricsOperationKey{}, operation)
+}
+
+func metricsOperation(ctx context.Context) string {
+	operation, _ := ctx.Value(metricsOperationKey{}).(string)
+	return operation
+}
+
+// observeRequest records the REST call started at `start` that ended with `err`
+func (rest *RESTClient) observeRequest(operation string, start time.Time, err error) {
+	status := strconv.Itoa(http.StatusOK)
+	var restErr *RestError
+	if errors.As(err, &restErr) {
+		status = strconv.Itoa(restErr.HttpCode)
+		if restErr.Conflict != "" {
+			rest.metrics.CountConflict(operation, restErr.Conflict)
+		}
+	} else if err != nil {
+		status = MetricsStatusError
+	}
+	rest.metrics.ObserveRequest(operation, status, time.Since(start))
+}
+
+// SetMetricsRecorder sets the recorder of the REST calls, nil stops recording
+func (rest *RESTClient) SetMetricsRecorder(recorder MetricsRecorder) {
+	rest.metrics = metricsOrNoop(recorder)
+}
+
+// SetMetricsRecorder sets the recorder of the subscriptions made with this client, nil stops recording
+func (stomp *StompClient) SetMetricsRecorder(recorder MetricsRecorder) {
+	stomp.metrics = metricsOrNoop(recorder)
+}
@@ -0,0 +1,72 @@
+package eventuate_test
+
+import (
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClients_Metrics(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	recorder := eventuatetest.NewMetricsRecorder()
+	client := buildREST(t, srv.ClientBuilder().WithMetricsRecorder(recorder))
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}).
+		WithTypeHintPair(EVENT_CHANGED, MyEntityWasCreatedEvent{}).
+		WithMetricsRecorder(recorder))
+
+	handled := make(chan string, 2)
+	handlers := eventuate.NewEventResultHandlerMap().
+		AddHandler(ENTITY_TYPE, EVENT_CREATED,
+			func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+				handled <- meta.EventType
+				return future.NewSuccess(true)
+			}).
+		AddHandler(ENTITY_TYPE, EVENT_CHANGED,
+			func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+				handled <- meta.EventType
+				return future.NewFailure(eventuate.AppError("cannot handle the change"))
+			})
+	_, err := stomp.SubscribeAndDispatch("measured-subscriber", handlers, nil, true)
+	assert.Nil(t, err)
+
+	created, err := client.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Arthur Dent"}`}}, nil)
+	assert.Nil(t, err)
+
+	idAndType := eventuate.EntityIdAndType{
+		EntityType: ENTITY_TYPE,
+		EntityId:   created.EntityId}
+	changes := []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CHANGED,
+			EventData: `{"name":"Zaphod Beeblebrox"}`}}
+	_, err = client.Update(idAndType, created.EntityVersion, changes, nil)
+	assert.Nil(t, err)
+	_, err = client.Update(idAndType, created.EntityVersion, changes, nil)
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, recorder.Requests(eventuate.MetricsOperationSave, "200"))
+	assert.Equal(t, 1, recorder.Requests(eventuate.MetricsOperationUpdate, "200"))
+	assert.Equal(t, 1, recorder.Requests(eventuate.MetricsOperationUpdate, "409"))
+	assert.Equal(t, 1, recorder.Conflicts(eventuate.MetricsOperationUpdate, "optimistic_lock_error"))
+
+	for idx := 0; idx < 2; idx++ {
+		receive(t, handled)
+	}
+	eventually(t, func() bool {
+		return recorder.EventsAcked("measured-subscriber")+recorder.EventsFailed("measured-subscriber") >= 2
+	})
+
+	assert.Equal(t, 2, recorder.EventsReceived("measured-subscriber"))
+	assert.Equal(t, 1, recorder.EventsAcked("measured-subscriber"))
+	assert.Equal(t, 1, recorder.EventsFailed("measured-subscriber"))
+	assert.Equal(t, 1, recorder.PendingAcks("measured-subscriber"))
+}
@@ -0,0 +1,84 @@
+//go:build otel
+
+// Package oteltracing implements eventuate.Tracer with OpenTelemetry.
+// It is built with the `otel` tag, so that the client does not depend on OpenTelemetry otherwise.
+package oteltracing
+
+import (
+	"context"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/eventuate-clients/eventuate-client-golang"
+
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var _ eventuate.Tracer = (*Tracer)(nil)
+
+// NewTracer makes a tracer out of `provider` (the global one when nil) carrying the trace context
+// in the event metadata with `propagator` (W3C trace context when nil)
+func NewTracer(provider trace.TracerProvider, propagator propagation.TextMapPropagator) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return &Tracer{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagator}
+}
+
+func (tracer *Tracer) StartSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, eventuate.Span) {
+	ctx, span := tracer.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(toAttributes(attributes)...))
+	return ctx, otelSpan{span}
+}
+
+func (tracer *Tracer) Inject(ctx context.Context, metadata map[string]string) {
+	tracer.propagator.Inject(ctx, propagation.MapCarrier(metadata))
+}
+
+func (tracer *Tracer) StartEventSpan(ctx context.Context, name string, metadata map[string]string, attributes map[string]string) (context.Context, eventuate.Span) {
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(toAttributes(attributes)...)}
+
+	producer := trace.SpanContextFromContext(tracer.propagator.Extract(context.Background(), propagation.MapCarrier(metadata)))
+	if producer.IsValid() {
+		options = append(options, trace.WithLinks(trace.Link{SpanContext: producer}))
+	}
+
+	ctx, span := tracer.tracer.Start(ctx, name, options...)
+	return ctx, otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (span otelSpan) End(err error) {
+	if err != nil {
+		span.span.RecordError(err)
+		span.span.SetStatus(codes.Error, err.Error())
+	}
+	span.span.End()
+}
+
+func toAttributes(attributes map[string]string) []attribute.KeyValue {
+	result := make([]attribute.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		result = append(result, attribute.String(key, value))
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+//go:build otel
+
+package oteltracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/oteltracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer_LinksEventSpanToProducer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := oteltracing.NewTracer(provider, nil)
+
+	ctx, producer := tracer.StartSpan(context.Background(), "eventuate.repository.Update",
+		map[string]string{eventuate.SpanAttributeEntityType: "Customer"})
+	metadata := make(map[string]string)
+	tracer.Inject(ctx, metadata)
+	producer.End(nil)
+	assert.NotEmpty(t, metadata["traceparent"])
+
+	_, consumer := tracer.StartEventSpan(context.Background(), "eventuate.subscription.Handle", metadata,
+		map[string]string{eventuate.SpanAttributeSubscriberId: "sub"})
+	consumer.End(errors.New("handler failed"))
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Contains(t, spans[0].Attributes, attribute.String(eventuate.SpanAttributeEntityType, "Customer"))
+	if assert.Len(t, spans[1].Links, 1) {
+		assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Links[0].SpanContext.SpanID())
+		assert.Equal(t, spans[0].SpanContext.TraceID(), spans[1].Links[0].SpanContext.TraceID())
+	}
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+}
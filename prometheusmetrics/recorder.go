@@ -0,0 +1,111 @@
+//go:build prometheus
+
+// Package prometheusmetrics implements eventuate.MetricsRecorder with Prometheus.
+// It is built with the `prometheus` tag, so that the client does not depend on Prometheus otherwise.
+package prometheusmetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Recorder struct {
+	requestDuration *prometheus.HistogramVec
+	conflicts       *prometheus.CounterVec
+	retries         *prometheus.CounterVec
+	eventsReceived  *prometheus.CounterVec
+	eventsAcked     *prometheus.CounterVec
+	eventsFailed    *prometheus.CounterVec
+	pendingAcks     *prometheus.GaugeVec
+	swimlaneDepth   *prometheus.GaugeVec
+}
+
+var _ eventuate.MetricsRecorder = (*Recorder)(nil)
+
+// NewRecorder registers the metrics of the client with `registerer` (the default registry when nil),
+// their names are prefixed with `namespace` (e.g. `eventuate_rest_request_duration_seconds`)
+func NewRecorder(registerer prometheus.Registerer, namespace string) (*Recorder, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	rec := &Recorder{
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rest_request_duration_seconds",
+			Help:      "Duration of the calls to the Eventuate REST API.",
+			Buckets:   prometheus.DefBuckets}, []string{"operation", "status"}),
+		conflicts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rest_conflicts_total",
+			Help:      "Conflict responses of the Eventuate REST API by conflict code."}, []string{"operation", "conflict"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rest_retries_total",
+			Help:      "Calls to the Eventuate REST API retried because the server was unavailable."}, []string{"operation"}),
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "subscription_events_received_total",
+			Help:      "Events received by the subscriptions."}, []string{"subscriber"}),
+		eventsAcked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "subscription_events_acked_total",
+			Help:      "Events acknowledged by the subscriptions."}, []string{"subscriber"}),
+		eventsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "subscription_events_failed_total",
+			Help:      "Events the handlers of the subscriptions failed on."}, []string{"subscriber"}),
+		pendingAcks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "subscription_pending_acks",
+			Help:      "Events of the subscriptions awaiting their acks."}, []string{"subscriber"}),
+		swimlaneDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "swimlane_queue_depth",
+			Help:      "Events queued in the swimlanes of the dispatchers."}, []string{"subscriber", "entity_type", "swimlane"})}
+
+	for _, collector := range []prometheus.Collector{
+		rec.requestDuration, rec.conflicts, rec.retries,
+		rec.eventsReceived, rec.eventsAcked, rec.eventsFailed,
+		rec.pendingAcks, rec.swimlaneDepth} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+func (rec *Recorder) ObserveRequest(operation, status string, duration time.Duration) {
+	rec.requestDuration.WithLabelValues(operation, status).Observe(duration.Seconds())
+}
+
+func (rec *Recorder) CountConflict(operation, conflict string) {
+	rec.conflicts.WithLabelValues(operation, conflict).Inc()
+}
+
+func (rec *Recorder) CountRetry(operation string) {
+	rec.retries.WithLabelValues(operation).Inc()
+}
+
+func (rec *Recorder) CountEventsReceived(subscriberId string, count int) {
+	rec.eventsReceived.WithLabelValues(subscriberId).Add(float64(count))
+}
+
+func (rec *Recorder) CountEventsAcked(subscriberId string, count int) {
+	rec.eventsAcked.WithLabelValues(subscriberId).Add(float64(count))
+}
+
+func (rec *Recorder) CountEventsFailed(subscriberId string, count int) {
+	rec.eventsFailed.WithLabelValues(subscriberId).Add(float64(count))
+}
+
+func (rec *Recorder) SetPendingAcks(subscriberId string, count int) {
+	rec.pendingAcks.WithLabelValues(subscriberId).Set(float64(count))
+}
+
+func (rec *Recorder) SetSwimlaneQueueDepth(subscriberId, entityType string, swimlane, depth int) {
+	rec.swimlaneDepth.WithLabelValues(subscriberId, entityType, strconv.Itoa(swimlane)).Set(float64(depth))
+}
@@ -0,0 +1,50 @@
+//go:build prometheus
+
+package prometheusmetrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/prometheusmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	rec, err := prometheusmetrics.NewRecorder(registry, "eventuate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec.ObserveRequest(eventuate.MetricsOperationUpdate, "409", 20*time.Millisecond)
+	rec.CountConflict(eventuate.MetricsOperationUpdate, "optimistic_lock_error")
+	rec.CountEventsReceived("subscriber", 3)
+	rec.CountEventsAcked("subscriber", 2)
+	rec.SetPendingAcks("subscriber", 1)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP eventuate_rest_conflicts_total Conflict responses of the Eventuate REST API by conflict code.
+# TYPE eventuate_rest_conflicts_total counter
+eventuate_rest_conflicts_total{conflict="optimistic_lock_error",operation="Update"} 1
+# HELP eventuate_subscription_events_acked_total Events acknowledged by the subscriptions.
+# TYPE eventuate_subscription_events_acked_total counter
+eventuate_subscription_events_acked_total{subscriber="subscriber"} 2
+# HELP eventuate_subscription_events_received_total Events received by the subscriptions.
+# TYPE eventuate_subscription_events_received_total counter
+eventuate_subscription_events_received_total{subscriber="subscriber"} 3
+# HELP eventuate_subscription_pending_acks Events of the subscriptions awaiting their acks.
+# TYPE eventuate_subscription_pending_acks gauge
+eventuate_subscription_pending_acks{subscriber="subscriber"} 1
+`), "eventuate_rest_conflicts_total", "eventuate_subscription_events_acked_total",
+		"eventuate_subscription_events_received_total", "eventuate_subscription_pending_acks")
+	assert.Nil(t, err)
+
+	count, err := testutil.GatherAndCount(registry, "eventuate_rest_request_duration_seconds")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -1,10 +1,12 @@
 package eventuate
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/go-resty/resty"
 
@@ -16,12 +18,24 @@ type RESTClient struct {
 	Credentials *Credentials
 	Url         *url.URL
 	ll          loglib.LogLevelEnum
-	lg          loglib.Logger
+	lg          loglib.LeveledLogger
 	typeHints   typeHintsMap
 	resty       *resty.Client
+	tlsConfig   *tls.Config
+	upcasters   *UpcasterRegistry
+	codecs      *EventCodecs
+	encryptor   *PIIEncryptor
+	metadata    EventMetadataProvider
+	tracer      Tracer
+	metrics     MetricsRecorder
 }
 
 func NewRESTClient(credentials *Credentials, serverUrl string) (*RESTClient, error) {
+	return NewRESTClientWithOptions(credentials, serverUrl, HTTPOptions{})
+}
+
+// NewRESTClientWithOptions makes a client with its own HTTP client, configured by `options`
+func NewRESTClientWithOptions(credentials *Credentials, serverUrl string, options HTTPOptions) (*RESTClient, error) {
 
 	if credentials == nil {
 		return nil, AppError("NewRESTClient: Credentials not provided")
@@ -39,6 +53,10 @@ func NewRESTClient(credentials *Credentials, serverUrl string) (*RESTClient, err
 	var rest *RESTClient
 
 	retryOnUnavailability := resty.RetryConditionFunc(func(resp *resty.Response) (bool, error) {
+		if resp == nil || resp.RawResponse == nil {
+			// failed without a response, resty retries it anyway
+			return false, nil
+		}
 
 		status := resp.StatusCode()
 		switch status {
@@ -48,15 +66,17 @@ func NewRESTClient(credentials *Credentials, serverUrl string) (*RESTClient, err
 		case http.StatusServiceUnavailable:
 			fallthrough
 		case http.StatusRequestTimeout:
+			rest.metrics.CountRetry(metricsOperation(resp.Request.RawRequest.Context()))
 			return true, nil
 		}
 		return false, rest.handleNon200Code(status, storeServerUrl, resp.Request.Body, resp.Body())
 	})
 
-	restyClient := resty.
-		SetHeader("Content-Type", "application/json").
-		SetBasicAuth(credentials.apiKeyId, credentials.apiKeySecret).
-		AddRetryCondition(retryOnUnavailability)
+	restyClient, tlsConfig, clientErr := newRestyClient(credentials, options)
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	restyClient.AddRetryCondition(retryOnUnavailability)
 
 	rest = &RESTClient{
 		Credentials: credentials,
@@ -64,7 +84,9 @@ func NewRESTClient(credentials *Credentials, serverUrl string) (*RESTClient, err
 		ll:          loglib.Silent,
 		lg:          loglib.NewNilLogger(),
 		resty:       restyClient,
-		typeHints:   NewTypeHintsMap()}
+		tlsConfig:   tlsConfig,
+		typeHints:   NewTypeHintsMap(),
+		metrics:     NoopMetricsRecorder{}}
 
 	return rest, nil
 }
@@ -74,9 +96,19 @@ func (rest *RESTClient) SetLogLevel(level loglib.LogLevelEnum) {
 	rest.ll = level
 }
 
+// SetLogger makes the client, and the repositories made with it, log to `lg`
+func (rest *RESTClient) SetLogger(lg loglib.LeveledLogger) {
+	rest.lg = lg
+}
+
 func (rest *RESTClient) SetInsecureSkipVerify(flag bool) {
-	rest.resty.SetTLSClientConfig(&tls.Config{
-		InsecureSkipVerify: flag})
+	config := &tls.Config{}
+	if rest.tlsConfig != nil {
+		config = rest.tlsConfig.Clone()
+	}
+	config.InsecureSkipVerify = flag
+	rest.tlsConfig = config
+	rest.resty.SetTLSClientConfig(config)
 }
 
 func (rest *RESTClient) Find(
@@ -84,7 +116,31 @@ func (rest *RESTClient) Find(
 	entityId Int128,
 	findOptions *AggregateCrudFindOptions) (*LoadedEvents, error) {
 
-	rest.lg.Printf("Find(type: %s, entityId: %s)", aggregateType, entityId)
+	return rest.FindCtx(context.Background(), aggregateType, entityId, findOptions)
+}
+
+func (rest *RESTClient) FindCtx(
+	ctx context.Context,
+	aggregateType string,
+	entityId Int128,
+	findOptions *AggregateCrudFindOptions) (*LoadedEvents, error) {
+
+	ctx, span := startSpan(rest.tracer, ctx, "eventuate.rest.Find", entitySpanAttributes(aggregateType, entityId))
+	start := time.Now()
+	result, err := rest.find(withMetricsOperation(ctx, MetricsOperationFind), aggregateType, entityId, findOptions)
+	rest.observeRequest(MetricsOperationFind, start, err)
+	span.End(err)
+	return result, err
+}
+
+func (rest *RESTClient) find(
+	ctx context.Context,
+	aggregateType string,
+	entityId Int128,
+	findOptions *AggregateCrudFindOptions) (*LoadedEvents, error) {
+
+	lg := rest.lg.With(loglib.KeyEntityType, aggregateType, loglib.KeyEntityId, entityId)
+	lg.Debug("Find")
 
 	query := url.Values{}
 	if findOptions != nil {
@@ -95,34 +151,70 @@ func (rest *RESTClient) Find(
 
 	reqUrl, parseErr := rest.Url.Parse(makeGetUrl(rest.Credentials.Space, aggregateType, entityId.String(), query.Encode()))
 	if parseErr != nil {
-		rest.lg.Println(parseErr)
+		lg.Error("Cannot make the request URL", loglib.KeyError, parseErr)
 		return nil, parseErr
 	}
 
-	resp, respErr := rest.resty.R().Get(reqUrl.String())
+	resp, respErr := rest.resty.R().SetContext(ctx).Get(reqUrl.String())
 	if respErr != nil {
-		rest.lg.Println(respErr)
+		lg.Warn("Request failed", loglib.KeyUrl, reqUrl, loglib.KeyError, respErr)
 		return nil, respErr
 	}
 
 	status := resp.StatusCode()
 	body := resp.Body()
 
-	rest.lg.Printf("\nFIND:\nURL: %s\nHEADERS: %v\nRESPONSE: %v\n",
-		reqUrl, resp.Request.Header, string(body))
+	lg.Debug("Find response", loglib.KeyUrl, reqUrl, loglib.KeyStatus, status,
+		loglib.KeyResponseBody, string(body))
 
 	if status == http.StatusOK {
 		return rest.handleGetResponse(body)
 	}
 
-	return nil, rest.handleNon200Code(status, reqUrl, resp.Request.Body, body)
+	return nil, rest.handleNon200Code(status, reqUrl, resp.Request.Body, body).
+		forEntity(aggregateType, entityId, Int128Nil)
 }
 
 func (rest *RESTClient) Save(aggregateType string,
 	events []EventTypeAndData,
 	saveOptions *AggregateCrudSaveOptions) (*EntityIdVersionAndEventIds, error) {
 
-	rest.lg.Printf("Save(type: %s, events: %s)", aggregateType, events)
+	return rest.SaveCtx(context.Background(), aggregateType, events, saveOptions)
+}
+
+func (rest *RESTClient) SaveCtx(
+	ctx context.Context,
+	aggregateType string,
+	events []EventTypeAndData,
+	saveOptions *AggregateCrudSaveOptions) (*EntityIdVersionAndEventIds, error) {
+
+	var entityId Int128
+	if saveOptions != nil {
+		entityId = saveOptions.EntityId
+	}
+	ctx, span := startSpan(rest.tracer, ctx, "eventuate.rest.Save", entitySpanAttributes(aggregateType, entityId))
+	start := time.Now()
+	result, err := rest.save(withMetricsOperation(ctx, MetricsOperationSave), aggregateType, events, saveOptions)
+	rest.observeRequest(MetricsOperationSave, start, err)
+	span.End(err)
+	return result, err
+}
+
+func (rest *RESTClient) save(
+	ctx context.Context,
+	aggregateType string,
+	events []EventTypeAndData,
+	saveOptions *AggregateCrudSaveOptions) (*EntityIdVersionAndEventIds, error) {
+
+	lg := rest.lg.With(loglib.KeyEntityType, aggregateType)
+	lg.Debug("Save", loglib.KeyEvents, events)
+
+	if saveOptions != nil {
+		var metadataErr error
+		if events, metadataErr = withEventMetadata(events, saveOptions.EventMetadata); metadataErr != nil {
+			return nil, metadataErr
+		}
+	}
 
 	jsonPayload := make(map[string]interface{})
 	jsonPayload["entityTypeName"] = aggregateType
@@ -131,38 +223,46 @@ func (rest *RESTClient) Save(aggregateType string,
 		if !saveOptions.EntityId.IsNil() {
 			jsonPayload["entityId"] = saveOptions.EntityId
 		}
+		if saveOptions.TriggeringEvent != nil {
+			jsonPayload["triggeringEventToken"] = *saveOptions.TriggeringEvent
+		}
 	}
 
 	reqUrl, parseErr := rest.Url.Parse(makeNsUrl(rest.Credentials.Space))
 	if parseErr != nil {
-		rest.lg.Println(parseErr)
+		lg.Error("Cannot make the request URL", loglib.KeyError, parseErr)
 		return nil, parseErr
 	}
 
 	reqJson, reqJsonErr := json.Marshal(jsonPayload)
 	if reqJsonErr != nil {
-		rest.lg.Println(reqJsonErr)
+		lg.Error("Cannot serialize the request", loglib.KeyError, reqJsonErr)
 		return nil, reqJsonErr
 	}
 	reqJsonTxt := string(reqJson)
 
-	resp, respErr := rest.resty.R().SetBody(jsonPayload).Post(reqUrl.String())
+	resp, respErr := rest.resty.R().SetContext(ctx).SetBody(jsonPayload).Post(reqUrl.String())
 	if respErr != nil {
-		rest.lg.Println(respErr)
+		lg.Warn("Request failed", loglib.KeyUrl, reqUrl, loglib.KeyError, respErr)
 		return nil, respErr
 	}
 
 	status := resp.StatusCode()
 	body := resp.Body()
 
-	rest.lg.Printf("\nSAVE:\nURL: %s\nBODY: %v\n(%v) \nHEADERS: %v\nRESPONSE: %v\n",
-		reqUrl, reqJsonTxt, jsonPayload, resp.Request.Header, string(body))
+	lg.Debug("Save response", loglib.KeyUrl, reqUrl, loglib.KeyStatus, status,
+		loglib.KeyRequestBody, reqJsonTxt, loglib.KeyResponseBody, string(body))
 
 	if status == http.StatusOK {
 		return rest.handleCreateResponse(body)
 	}
 
-	return nil, rest.handleNon200Code(status, reqUrl, resp.Request.Body, body)
+	var entityId Int128
+	if saveOptions != nil {
+		entityId = saveOptions.EntityId
+	}
+	return nil, rest.handleNon200Code(status, reqUrl, resp.Request.Body, body).
+		forEntity(aggregateType, entityId, Int128Nil)
 }
 
 func (rest *RESTClient) Update(
@@ -171,7 +271,41 @@ func (rest *RESTClient) Update(
 	events []EventTypeAndData,
 	updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error) {
 
-	rest.lg.Printf("Update(type: %s, events: %s)", aggregateIdAndType, events)
+	return rest.UpdateCtx(context.Background(), aggregateIdAndType, entityVersion, events, updateOptions)
+}
+
+func (rest *RESTClient) UpdateCtx(
+	ctx context.Context,
+	aggregateIdAndType EntityIdAndType,
+	entityVersion Int128,
+	events []EventTypeAndData,
+	updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error) {
+
+	ctx, span := startSpan(rest.tracer, ctx, "eventuate.rest.Update",
+		entitySpanAttributes(aggregateIdAndType.EntityType, aggregateIdAndType.EntityId))
+	start := time.Now()
+	result, err := rest.update(withMetricsOperation(ctx, MetricsOperationUpdate), aggregateIdAndType, entityVersion, events, updateOptions)
+	rest.observeRequest(MetricsOperationUpdate, start, err)
+	span.End(err)
+	return result, err
+}
+
+func (rest *RESTClient) update(
+	ctx context.Context,
+	aggregateIdAndType EntityIdAndType,
+	entityVersion Int128,
+	events []EventTypeAndData,
+	updateOptions *AggregateCrudUpdateOptions) (*EntityIdVersionAndEventIds, error) {
+
+	lg := rest.lg.With(loglib.KeyEntityType, aggregateIdAndType.EntityType, loglib.KeyEntityId, aggregateIdAndType.EntityId)
+	lg.Debug("Update", loglib.KeyEvents, events)
+
+	if updateOptions != nil {
+		var metadataErr error
+		if events, metadataErr = withEventMetadata(events, updateOptions.EventMetadata); metadataErr != nil {
+			return nil, metadataErr
+		}
+	}
 
 	jsonPayload := make(map[string]interface{})
 	jsonPayload["entityVersion"] = entityVersion
@@ -180,38 +314,42 @@ func (rest *RESTClient) Update(
 		if updateOptions.TriggeringEvent != nil {
 			jsonPayload["triggeringEventToken"] = *updateOptions.TriggeringEvent
 		}
+		if updateOptions.SerializedSnapshot != nil {
+			jsonPayload["snapshot"] = updateOptions.SerializedSnapshot
+		}
 	}
 
 	reqUrl, parseErr := rest.Url.Parse(makeUpdateUrl(rest.Credentials.Space, aggregateIdAndType))
 	if parseErr != nil {
-		rest.lg.Println(parseErr)
+		lg.Error("Cannot make the request URL", loglib.KeyError, parseErr)
 		return nil, parseErr
 	}
 
 	reqJson, reqJsonErr := json.Marshal(jsonPayload)
 	if reqJsonErr != nil {
-		rest.lg.Println(reqJsonErr)
+		lg.Error("Cannot serialize the request", loglib.KeyError, reqJsonErr)
 		return nil, reqJsonErr
 	}
 	reqJsonTxt := string(reqJson)
 
-	resp, respErr := rest.resty.R().SetBody(jsonPayload).Post(reqUrl.String())
+	resp, respErr := rest.resty.R().SetContext(ctx).SetBody(jsonPayload).Post(reqUrl.String())
 	if respErr != nil {
-		rest.lg.Println(respErr)
+		lg.Warn("Request failed", loglib.KeyUrl, reqUrl, loglib.KeyError, respErr)
 		return nil, respErr
 	}
 
 	status := resp.StatusCode()
 	body := resp.Body()
 
-	rest.lg.Printf("\nUPDATE:\nURL: %s\nBODY: %v\n(%v) \nHEADERS: %v\nRESPONSE: %v\n",
-		reqUrl, reqJsonTxt, jsonPayload, resp.Request.Header, string(body))
+	lg.Debug("Update response", loglib.KeyUrl, reqUrl, loglib.KeyStatus, status,
+		loglib.KeyRequestBody, reqJsonTxt, loglib.KeyResponseBody, string(body))
 
 	if status == http.StatusOK {
 		return rest.handleUpdateResponse(body)
 	}
 
-	return nil, rest.handleNon200Code(status, reqUrl, resp.Request.Body, body)
+	return nil, rest.handleNon200Code(status, reqUrl, resp.Request.Body, body).
+		forEntity(aggregateIdAndType.EntityType, aggregateIdAndType.EntityId, entityVersion)
 }
 
 func makeNsUrl(space string) string {
@@ -229,7 +367,7 @@ func makeGetUrl(space, aggType, entityId, query string) string {
 	return fmt.Sprintf("%s/%s/%s?%s", makeNsUrl(space), aggType, entityId, query)
 }
 
-func (rest *RESTClient) handleNon200Code(code int, reqUrl *url.URL, reqBody interface{}, respBody []byte) error {
+func (rest *RESTClient) handleNon200Code(code int, reqUrl *url.URL, reqBody interface{}, respBody []byte) *RestError {
 
 	var conflict string
 	if code == http.StatusConflict {
@@ -247,7 +385,7 @@ func (rest *RESTClient) handleNon200Code(code int, reqUrl *url.URL, reqBody inte
 		}
 	}
 
-	return RestError(code, conflict, "URL: %s\nRequest: %#v\nResponse: %v", reqUrl, reqBody, string(respBody))
+	return NewRestError(code, conflict, "URL: %s\nRequest: %#v\nResponse: %v", reqUrl, reqBody, string(respBody))
 }
 
 func (rest *RESTClient) handleGetResponse(respBody []byte) (*LoadedEvents, error) {
@@ -260,9 +398,9 @@ func (rest *RESTClient) handleGetResponse(respBody []byte) (*LoadedEvents, error
 
 	tmp := LoadedEvents(response)
 
-	if rest.ll != loglib.Silent {
+	if rest.lg.Enabled(loglib.Debug) {
 		o, _ := json.Marshal(tmp)
-		rest.lg.Printf("handleGetResponse() result: <LoadedEvents: %v />\n", string(o))
+		rest.lg.Debug("Find result", loglib.KeyEvents, string(o))
 	}
 
 	return &tmp, nil
@@ -278,9 +416,9 @@ func (rest *RESTClient) handleCreateResponse(respBody []byte) (*EntityIdVersionA
 
 	tmp := EntityIdVersionAndEventIds(response)
 
-	if rest.ll != loglib.Silent {
+	if rest.lg.Enabled(loglib.Debug) {
 		o, _ := json.Marshal(tmp)
-		rest.lg.Printf("handleCreateResponse() result: <EntityIdVersionAndEventIds: %v />\n", string(o))
+		rest.lg.Debug("Save result", loglib.KeyResponseBody, string(o))
 	}
 
 	return &tmp, nil
@@ -296,9 +434,9 @@ func (rest *RESTClient) handleUpdateResponse(respBody []byte) (*EntityIdVersionA
 
 	tmp := EntityIdVersionAndEventIds(response)
 
-	if rest.ll != loglib.Silent {
+	if rest.lg.Enabled(loglib.Debug) {
 		o, _ := json.Marshal(tmp)
-		rest.lg.Printf("handleUpdateResponse() result: <EntityIdVersionAndEventIds: %v />\n", string(o))
+		rest.lg.Debug("Update result", loglib.KeyResponseBody, string(o))
 	}
 
 	return &tmp, nil
@@ -0,0 +1,94 @@
+package eventuate
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+)
+
+// RetryPolicy describes how many times and how often an operation is retried.
+// The delay before retry `n` is `Backoff * 2^(n-1)`, capped at `MaxBackoff` (if set)
+// and randomized by up to `Jitter` (0..1) of its value.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Jitter      float64
+}
+
+func NewRetryPolicy(maxAttempts int, backoff time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff}
+}
+
+func (policy *RetryPolicy) maxAttempts() int {
+	if policy.MaxAttempts < 1 {
+		return 1
+	}
+	return policy.MaxAttempts
+}
+
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.Backoff
+	for i := 1; i < attempt; i++ {
+		if (policy.MaxBackoff > 0 && delay >= policy.MaxBackoff) || delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+
+	if policy.Jitter > 0 && delay > 0 {
+		jitter := policy.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay -= time.Duration(rand.Float64() * jitter * float64(delay))
+	}
+	return delay
+}
+
+// updateWithRetries repeats `update` on optimistic locking failures according to `policy` (none if nil).
+// The failures of `update` are told apart with `errors.Is`.
+func updateWithRetries(ctx context.Context, policy *RetryPolicy, lg loglib.LeveledLogger, entityId Int128,
+	update func() error) error {
+
+	for attempt := 1; ; attempt++ {
+		err := update()
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if policy == nil || !errors.Is(err, ErrOptimisticLock) {
+			return err
+		}
+
+		if attempt >= policy.maxAttempts() {
+			return &RetryExhaustedError{
+				Attempts: attempt,
+				Err:      err}
+		}
+
+		delay := policy.backoff(attempt)
+		lg.Info("Optimistic locking failure, retrying the update", loglib.KeyEntityId, entityId,
+			"attempt", attempt, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
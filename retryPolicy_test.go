@@ -0,0 +1,122 @@
+package eventuate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+// racingCrud lets a concurrent writer update the entity right before each of the first `races` updates
+type racingCrud struct {
+	*eventuate.InMemoryCrud
+	races   int
+	updates int
+}
+
+func (crud *racingCrud) Update(
+	entityIdAndType eventuate.EntityIdAndType,
+	entityVersion eventuate.Int128,
+	events []eventuate.EventTypeAndData,
+	updateOptions *eventuate.AggregateCrudUpdateOptions) (*eventuate.EntityIdVersionAndEventIds, error) {
+
+	return crud.UpdateCtx(context.Background(), entityIdAndType, entityVersion, events, updateOptions)
+}
+
+func (crud *racingCrud) UpdateCtx(
+	ctx context.Context,
+	entityIdAndType eventuate.EntityIdAndType,
+	entityVersion eventuate.Int128,
+	events []eventuate.EventTypeAndData,
+	updateOptions *eventuate.AggregateCrudUpdateOptions) (*eventuate.EntityIdVersionAndEventIds, error) {
+
+	crud.updates++
+	if crud.races > 0 {
+		crud.races--
+		loaded, err := crud.InMemoryCrud.Find(entityIdAndType.EntityType, entityIdAndType.EntityId, nil)
+		if err != nil {
+			return nil, err
+		}
+		current := loaded.Events[len(loaded.Events)-1].EventId
+		if _, err := crud.InMemoryCrud.Update(entityIdAndType, current, []eventuate.EventTypeAndData{
+			{
+				EventType: COUNTER_INCREMENTED,
+				EventData: `{"Amount":10}`}}, nil); err != nil {
+			return nil, err
+		}
+	}
+	return crud.InMemoryCrud.UpdateCtx(ctx, entityIdAndType, entityVersion, events, updateOptions)
+}
+
+func newRacingRepository(t *testing.T, races int) (*eventuate.AggregateRepository, *racingCrud) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+
+	crud := &racingCrud{
+		InMemoryCrud: eventuate.NewInMemoryCrud(),
+		races:        races}
+	repo := eventuate.NewAggregateRepository(crud, meta)
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+	return repo, crud
+}
+
+func TestAggregateRepository_UpdateRetries(t *testing.T) {
+	repo, crud := newRacingRepository(t, 2)
+
+	saved, err := repo.Save(&IncrementCommand{Amount: 1})
+	assertNoError(t, err)
+
+	_, err = repo.UpdateWithOptions(saved.EntityId, &IncrementCommand{Amount: 2}, &eventuate.UpdateOptions{
+		Retry: &eventuate.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+			MaxBackoff:  5 * time.Millisecond,
+			Jitter:      0.5}})
+	assertNoError(t, err)
+	assert.Equal(t, 3, crud.updates)
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, 23, found.EntityInstance.(*CounterAggregate).Total)
+}
+
+func TestAggregateRepository_UpdateRetriesExhausted(t *testing.T) {
+	repo, crud := newRacingRepository(t, 5)
+
+	saved, err := repo.Save(&IncrementCommand{Amount: 1})
+	assertNoError(t, err)
+
+	_, err = repo.UpdateWithOptions(saved.EntityId, &IncrementCommand{Amount: 2}, &eventuate.UpdateOptions{
+		Retry: eventuate.NewRetryPolicy(2, time.Millisecond)})
+	if assert.IsType(t, &eventuate.RetryExhaustedError{}, err) {
+		exhausted := err.(*eventuate.RetryExhaustedError)
+		assert.Equal(t, 2, exhausted.Attempts)
+		assertConflict(t, exhausted.Err, "optimistic locking")
+	}
+	assert.Equal(t, 2, crud.updates)
+
+	// without a policy the conflict is reported right away
+	_, err = repo.Update(saved.EntityId, &IncrementCommand{Amount: 2})
+	assertConflict(t, err, "optimistic locking")
+	assert.Equal(t, 3, crud.updates)
+}
+
+func TestAggregateRepository_UpdateCtxCancelledWhileRetrying(t *testing.T) {
+	repo, crud := newRacingRepository(t, 5)
+
+	saved, err := repo.Save(&IncrementCommand{Amount: 1})
+	assertNoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = repo.UpdateCtx(ctx, saved.EntityId, &IncrementCommand{Amount: 2}, &eventuate.UpdateOptions{
+		Retry: eventuate.NewRetryPolicy(5, time.Minute)})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, crud.updates)
+
+	_, err = repo.FindCtx(ctx, saved.EntityId)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,72 @@
+package eventuate
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// SnapshotStrategy decides whether AggregateRepository.Update stores a snapshot of the aggregate
+// together with the new events. `eventsSinceSnapshot` includes the events produced by the update.
+type SnapshotStrategy interface {
+	ShouldSnapshot(aggregate interface{}, eventsSinceSnapshot int) bool
+}
+
+type everyNEventsSnapshotStrategy struct {
+	n int
+}
+
+// NewEveryNEventsSnapshotStrategy snapshots an aggregate once `n` events were applied after the previous snapshot
+func NewEveryNEventsSnapshotStrategy(n int) SnapshotStrategy {
+	return &everyNEventsSnapshotStrategy{
+		n: n}
+}
+
+func (strategy *everyNEventsSnapshotStrategy) ShouldSnapshot(aggregate interface{}, eventsSinceSnapshot int) bool {
+	return strategy.n > 0 && eventsSinceSnapshot >= strategy.n
+}
+
+func (meta *AggregateMetadata) SetSnapshotStrategy(strategy SnapshotStrategy) *AggregateMetadata {
+	meta.snapshotStrategy = strategy
+	return meta
+}
+
+func (meta *AggregateMetadata) serializeSnapshot(entity *EntityMetadata) (*SerializedSnapshot, error) {
+	serializedAggregate, err := json.Marshal(getUnderlyingValue(entity.EntityInstance))
+	if err != nil {
+		return nil, AppError("Cannot serialize snapshot of (%v), json Error: %v", meta.EntityTypeName, err)
+	}
+
+	return &SerializedSnapshot{
+		SnapshotType: meta.EntityTypeName,
+		Json:         string(serializedAggregate)}, nil
+}
+
+// restoreSnapshot builds the aggregate with its constructor and overlays the snapshot state
+func (meta *AggregateMetadata) restoreSnapshot(snapshot *SerializedSnapshot) (*EntityMetadata, error) {
+	if snapshot.SnapshotType != meta.EntityTypeName {
+		return nil, AppError("Snapshot of type `%s` cannot be restored into `%s`",
+			snapshot.SnapshotType,
+			meta.EntityTypeName)
+	}
+
+	entity, ctorErr := meta.newInstance()
+	if ctorErr != nil {
+		return nil, ctorErr
+	}
+
+	instance := reflect.ValueOf(entity.EntityInstance)
+	target := instance
+	if instance.Kind() != reflect.Ptr {
+		target = reflect.New(instance.Type())
+		target.Elem().Set(instance)
+	}
+
+	if err := json.Unmarshal([]byte(snapshot.Json), target.Interface()); err != nil {
+		return nil, AppError("Cannot deserialize snapshot of type `%s`, json Error: %v", snapshot.SnapshotType, err)
+	}
+
+	if instance.Kind() != reflect.Ptr {
+		entity.EntityInstance = target.Elem().Interface()
+	}
+	return entity, nil
+}
@@ -0,0 +1,72 @@
+package eventuate_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateRepository_Snapshots(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+	meta.SetSnapshotStrategy(eventuate.NewEveryNEventsSnapshotStrategy(2))
+
+	crud := eventuate.NewInMemoryCrud()
+	repo := eventuate.NewAggregateRepository(crud, meta)
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+
+	saved, err := repo.Save(&IncrementCommand{Amount: 2})
+	assertNoError(t, err)
+
+	snapshotted, err := repo.Update(saved.EntityId, &IncrementCommand{Amount: 3})
+	assertNoError(t, err)
+
+	loaded, err := crud.Find(COUNTER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	assert.Len(t, loaded.Events, 0)
+	if assert.NotNil(t, loaded.Snapshot) {
+		assert.Equal(t, snapshotted.EntityVersion, loaded.Snapshot.EntityVersion)
+		assert.Equal(t, &eventuate.SerializedSnapshot{
+			SnapshotType: COUNTER_ENTITY,
+			Json:         `{"Total":5}`}, loaded.Snapshot.SerializedSnapshot)
+	}
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, snapshotted.EntityVersion, found.EntityVersion)
+	assert.Equal(t, 5, found.EntityInstance.(*CounterAggregate).Total)
+
+	updated, err := repo.Update(saved.EntityId, &IncrementCommand{Amount: 4})
+	assertNoError(t, err)
+
+	loaded, err = crud.Find(COUNTER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	assert.Len(t, loaded.Events, 1)
+	assert.Equal(t, snapshotted.EntityVersion, loaded.Snapshot.EntityVersion)
+
+	found, err = repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, updated.EntityVersion, found.EntityVersion)
+	assert.Equal(t, 9, found.EntityInstance.(*CounterAggregate).Total)
+}
+
+func TestSerializedSnapshotWithVersion_Json(t *testing.T) {
+	snapshot := eventuate.SerializedSnapshotWithVersion{
+		SerializedSnapshot: &eventuate.SerializedSnapshot{
+			SnapshotType: COUNTER_ENTITY,
+			Json:         `{"Total":5}`},
+		EntityVersion: eventuate.Int128FromString(EVENT_ID_1)}
+
+	serialized, err := json.Marshal(snapshot)
+	assertNoError(t, err)
+	assert.JSONEq(t, `{
+		"snapshotType": "`+COUNTER_ENTITY+`",
+		"json": "{\"Total\":5}",
+		"entityVersion": "`+EVENT_ID_1+`"}`, string(serialized))
+
+	var restored eventuate.SerializedSnapshotWithVersion
+	assertNoError(t, json.Unmarshal(serialized, &restored))
+	assert.Equal(t, snapshot, restored)
+}
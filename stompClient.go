@@ -1,12 +1,15 @@
 package eventuate
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gmallard/stompngo"
 	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
@@ -16,9 +19,32 @@ type StompClient struct {
 	credentials     *Credentials
 	Url             *url.URL
 	ll              loglib.LogLevelEnum
-	lg              loglib.Logger
+	lg              loglib.LeveledLogger
 	stompConnection *stompngo.Connection
+	netConnection   net.Conn
+	closed          bool
 	typeHints       typeHintsMap
+	cmu             sync.Mutex
+	subscriptions   map[string]*activeSubscription
+	lostConnections chan lostConnection
+	reconnectPolicy *RetryPolicy
+	stateHandler    ConnectionStateHandler
+	upcasters       *UpcasterRegistry
+	codecs          *EventCodecs
+	encryptor       *PIIEncryptor
+	tracer          Tracer
+	metrics         MetricsRecorder
+	tlsConfig       *tls.Config
+	// connectTimeout limits the TCP dial, handshakeTimeout the TLS and STOMP handshakes
+	connectTimeout   time.Duration
+	handshakeTimeout time.Duration
+	transport        StompTransport
+	proxyUrl         *url.URL
+}
+
+// SetLogger makes the client, and the subscriptions made with it, log to `lg`
+func (stomp *StompClient) SetLogger(lg loglib.LeveledLogger) {
+	stomp.lg = lg
 }
 
 func (stomp *StompClient) RegisterEventType(name string, typeInstance interface{}) error {
@@ -38,11 +64,16 @@ func NewStompClient(credentials *Credentials, serverUrl string) (*StompClient, e
 		return nil, urlErr
 	}
 	return &StompClient{
-		credentials: credentials,
-		Url:         stompServerUrl,
-		ll:          loglib.Silent,
-		lg:          loglib.NewNilLogger(),
-		typeHints:   NewTypeHintsMap()}, nil
+		credentials:   credentials,
+		Url:           stompServerUrl,
+		ll:            loglib.Silent,
+		lg:            loglib.NewNilLogger(),
+		typeHints:     NewTypeHintsMap(),
+		subscriptions: make(map[string]*activeSubscription),
+		metrics:       NoopMetricsRecorder{},
+
+		connectTimeout:   DefaultStompConnectTimeout,
+		handshakeTimeout: DefaultStompHandshakeTimeout}, nil
 }
 
 func (stomp *StompClient) SubscribeAndDispatch(
@@ -51,7 +82,18 @@ func (stomp *StompClient) SubscribeAndDispatch(
 	subscriberOptions *SubscriberOptions,
 	useSwimlane bool) (*DispatchingSubscription, error) {
 
-	return newSubscriptionManager(stomp).Subscribe(subscriberId, eventHandlers, subscriberOptions, useSwimlane)
+	return stomp.SubscribeAndDispatchCtx(context.Background(), subscriberId, eventHandlers, subscriberOptions, useSwimlane)
+}
+
+// SubscribeAndDispatchCtx is SubscribeAndDispatch bound to `ctx`, see SubscribeCtx
+func (stomp *StompClient) SubscribeAndDispatchCtx(
+	ctx context.Context,
+	subscriberId string,
+	eventHandlers *EventResultHandlerMap,
+	subscriberOptions *SubscriberOptions,
+	useSwimlane bool) (*DispatchingSubscription, error) {
+
+	return newSubscriptionManager(stomp).SubscribeCtx(ctx, subscriberId, eventHandlers, subscriberOptions, useSwimlane)
 }
 
 func (stomp *StompClient) Subscribe(
@@ -60,8 +102,30 @@ func (stomp *StompClient) Subscribe(
 	subscriberOptions *SubscriberOptions,
 	handler *EventResultHandler) (*Subscription, error) {
 
+	return stomp.SubscribeCtx(context.Background(), subscriberId, aggregatesAndEvents, subscriberOptions, handler)
+}
+
+// SubscribeCtx is Subscribe that gives up connecting once `ctx` is done.
+// Cancelling the context later unsubscribes, events not acknowledged by then are redelivered by the server.
+func (stomp *StompClient) SubscribeCtx(
+	ctx context.Context,
+	subscriberId string,
+	aggregatesAndEvents map[string][]string,
+	subscriberOptions *SubscriberOptions,
+	handler *EventResultHandler) (*Subscription, error) {
+
+	stomp.cmu.Lock()
+	defer stomp.cmu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if stomp.closed {
+		return nil, ErrClientClosed
+	}
+
 	if stomp.stompConnection == nil {
-		_, err := stomp.makeStompConnection()
+		_, err := stomp.makeStompConnection(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -75,6 +139,7 @@ func (stomp *StompClient) Subscribe(
 		EntityTypesAndEvents: aggregatesAndEvents,
 		SubscriberID:         subscriberId,
 		Space:                stomp.credentials.Space,
+		Options:              newSubscriptionRequestOptions(subscriberOptions),
 	}
 
 	// #2.2/3
@@ -98,24 +163,90 @@ func (stomp *StompClient) Subscribe(
 		return nil, subscrErr
 	}
 
-	subscription := newSubscription(uid, stomp.stompConnection, receiveChan, handler)
-	subscription.SetLogLevel(stomp.ll)
+	var progressHandler ProgressNotificationHandler
+	if subscriberOptions != nil {
+		progressHandler = subscriberOptions.ProgressHandler
+	}
+
+	subscription := newSubscription(uid, stomp.stompConnection, receiveChan, handler, progressHandler, subscriberId, stomp.metrics,
+		stomp.lg.With(loglib.KeySubscriberId, subscriberId, loglib.KeySubscriptionId, uid))
 	subscription.unsubscribeFn = func() error {
-		return stomp.stompConnection.Unsubscribe(headers)
+		return stomp.unsubscribe(uid, headers)
+	}
+
+	stomp.subscriptions[uid] = &activeSubscription{
+		subscription:    subscription,
+		headers:         headers,
+		stompConnection: stomp.stompConnection}
+
+	if ctx.Done() != nil {
+		go stomp.unsubscribeOnDone(ctx, subscription)
 	}
 
 	return subscription, nil
 }
 
-func (stomp *StompClient) makeStompConnection() (*stompngo.Connection, error) {
+// ContextShutdownTimeout bounds the wait for the event handlers of a subscription whose context is done
+var ContextShutdownTimeout = 30 * time.Second
+
+func (stomp *StompClient) unsubscribeOnDone(ctx context.Context, subscription *Subscription) {
+	select {
+	case <-ctx.Done():
+		// the context is done already, the handlers are drained within a fresh one
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ContextShutdownTimeout)
+		defer cancel()
+		if err := subscription.Shutdown(shutdownCtx); err != nil {
+			stomp.lg.Warn("Shutdown on the end of the context failed",
+				loglib.KeySubscriptionId, subscription.Id, loglib.KeyError, err)
+		}
+	case <-subscription.Done():
+	}
+}
+
+// Shutdown shuts the subscriptions down, see Subscription.Shutdown, then disconnects.
+// The client cannot subscribe afterwards.
+func (stomp *StompClient) Shutdown(ctx context.Context) error {
+	stomp.cmu.Lock()
+	stomp.closed = true
+	subscriptions := make([]*Subscription, 0, len(stomp.subscriptions))
+	for _, active := range stomp.subscriptions {
+		subscriptions = append(subscriptions, active.subscription)
+	}
+	stomp.cmu.Unlock()
 
-	var (
-		conn    *stompngo.Connection
-		err     error
-		netConn net.Conn
-	)
+	errs := make(chan error, len(subscriptions))
+	for _, subscription := range subscriptions {
+		go func(subscription *Subscription) {
+			errs <- subscription.Shutdown(ctx)
+		}(subscription)
+	}
+	var result error
+	for range subscriptions {
+		if err := <-errs; err != nil && result == nil {
+			result = err
+		}
+	}
 
-	netConn, netErr := stomp.makeTcpConnection()
+	stomp.cmu.Lock()
+	conn, netConn := stomp.stompConnection, stomp.netConnection
+	stomp.stompConnection = nil
+	stomp.netConnection = nil
+	stomp.cmu.Unlock()
+
+	if conn != nil {
+		if err := conn.Disconnect(stompngo.Headers{"noreceipt", "true"}); err != nil && err != stompngo.ECONBAD {
+			stomp.lg.Warn("STOMP disconnect failed", loglib.KeyError, err)
+		}
+		netConn.Close()
+		stomp.lg.Info("STOMP disconnected")
+	}
+	return result
+}
+
+// makeStompConnection connects and starts watching the connection. Must be called with `cmu` locked.
+func (stomp *StompClient) makeStompConnection(ctx context.Context) (*stompngo.Connection, error) {
+
+	netConn, netErr := stomp.makeNetConnection(ctx)
 
 	if netErr != nil {
 		return nil, netErr
@@ -133,46 +264,56 @@ func (stomp *StompClient) makeStompConnection() (*stompngo.Connection, error) {
 	stompConn, stompErr := stompngo.Connect(netConn, headers)
 
 	if stompErr != nil {
+		netConn.Close()
 		return nil, stompErr
 	}
+	netConn.SetDeadline(time.Time{})
 
-	// Show connect response
-	stomp.lg.Printf("connsess:%s common_connect_response connresp:%v\n",
-		stompConn.Session(),
-		stompConn.ConnectResponse)
-	// Heartbeat Data
-	stomp.lg.Printf("connsess:%s common_connect_heart_beat_send hbsend:%d\n",
-		stompConn.Session(),
-		stompConn.SendTickerInterval())
-	stomp.lg.Printf("connsess:%s common_connect_heart_beat_recv hbrecv:%d\n",
-		stompConn.Session(),
-		stompConn.ReceiveTickerInterval())
+	stomp.lg.Info("STOMP connected",
+		"session", stompConn.Session(),
+		"heart_beat_send", stompConn.SendTickerInterval(),
+		"heart_beat_recv", stompConn.ReceiveTickerInterval())
 
 	stomp.stompConnection = stompConn
+	stomp.netConnection = netConn
 
-	return conn, err
+	if stomp.lostConnections == nil {
+		stomp.lostConnections = make(chan lostConnection)
+		go stomp.superviseConnections()
+	}
+	go stomp.watchConnection(stompConn, netConn)
+
+	return stompConn, nil
 }
 
-func (stomp *StompClient) makeTcpConnection() (net.Conn, error) {
+// makeNetConnection dials the server, over TLS for the secure schemes and over WebSocket when selected,
+// with the handshake deadline set
+func (stomp *StompClient) makeNetConnection(ctx context.Context) (net.Conn, error) {
 
-	n, err := net.Dial(stompngo.NetProtoTCP, stomp.Url.Host)
+	n, err := stomp.dial(ctx)
 
 	if err != nil {
 		return nil, err
 	}
+	n.SetDeadline(time.Now().Add(stomp.handshakeTimeout))
 
-	if strings.HasPrefix(strings.ToLower(stomp.Url.Scheme), "https") {
-		//tc := new(tls.Config)
-		tc := &tls.Config{}
-		tc.InsecureSkipVerify = true
-		tc.ServerName = stomp.getHost()
-
-		sn := tls.Client(n, tc)
-		err = sn.Handshake()
+	if stomp.usesTLS() {
+		sn := tls.Client(n, stomp.clientTLSConfig())
+		err = sn.HandshakeContext(ctx)
 		if err != nil {
+			n.Close()
 			return nil, err
 		}
-		return sn, nil
+		n = sn
+	}
+
+	if stomp.usesWebSocket() {
+		ws, wsErr := stomp.makeWebSocketConnection(n)
+		if wsErr != nil {
+			n.Close()
+			return nil, wsErr
+		}
+		return ws, nil
 	}
 
 	return n, nil
@@ -0,0 +1,219 @@
+package eventuate
+
+import (
+	"context"
+	"net"
+	"time"
+
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+	"github.com/gmallard/stompngo"
+)
+
+type ConnectionState int
+
+const (
+	CONNECTED ConnectionState = iota
+	RECONNECTING
+	FAILED
+)
+
+func (state ConnectionState) String() string {
+	switch state {
+	case CONNECTED:
+		return "CONNECTED"
+	case RECONNECTING:
+		return "RECONNECTING"
+	case FAILED:
+		return "FAILED"
+	}
+	return "UNKNOWN"
+}
+
+// ConnectionStateHandler is notified when the STOMP connection is lost (RECONNECTING),
+// re-established (CONNECTED) or given up on (FAILED). `err` is the cause of the latter two.
+// It is called from the go-routine that does the reconnection.
+type ConnectionStateHandler func(state ConnectionState, err error)
+
+// DefaultReconnectPolicy is used by StompClient unless another one is configured
+var DefaultReconnectPolicy = RetryPolicy{
+	MaxAttempts: 10,
+	Backoff:     500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.2}
+
+// activeSubscription is what StompClient needs to re-issue a subscription over a new connection
+type activeSubscription struct {
+	subscription    *Subscription
+	headers         stompngo.Headers
+	stompConnection *stompngo.Connection
+}
+
+type lostConnection struct {
+	stompConnection *stompngo.Connection
+	err             error
+}
+
+func (stomp *StompClient) SetReconnectPolicy(policy *RetryPolicy) {
+	stomp.cmu.Lock()
+	stomp.reconnectPolicy = policy
+	stomp.cmu.Unlock()
+}
+
+func (stomp *StompClient) SetConnectionStateHandler(handler ConnectionStateHandler) {
+	stomp.cmu.Lock()
+	stomp.stateHandler = handler
+	stomp.cmu.Unlock()
+}
+
+// watchConnection waits until the connection is lost. stompngo closes `MessageData` once its reader stops.
+func (stomp *StompClient) watchConnection(conn *stompngo.Connection, netConn net.Conn) {
+	var lastErr error
+	for md := range conn.MessageData {
+		if md.Error != nil {
+			lastErr = md.Error
+			continue
+		}
+		stomp.lg.Warn("STOMP frame outside of subscriptions", "command", md.Message.Command)
+	}
+	netConn.Close()
+
+	if lastErr == nil {
+		lastErr = AppError("STOMP connection lost")
+	}
+	stomp.lostConnections <- lostConnection{
+		stompConnection: conn,
+		err:             lastErr}
+}
+
+// superviseConnections reconnects lost connections one at a time
+func (stomp *StompClient) superviseConnections() {
+	for lost := range stomp.lostConnections {
+		stomp.cmu.Lock()
+		isCurrent := lost.stompConnection == stomp.stompConnection
+		if isCurrent {
+			stomp.stompConnection = nil
+		}
+		stomp.cmu.Unlock()
+
+		if isCurrent {
+			stomp.lg.Warn("STOMP connection severed", loglib.KeyError, lost.err)
+			stomp.reconnect(lost.err)
+		}
+	}
+}
+
+func (stomp *StompClient) reconnect(cause error) {
+	stomp.notifyState(RECONNECTING, cause)
+
+	stomp.cmu.Lock()
+	policy := stomp.reconnectPolicy
+	stomp.cmu.Unlock()
+	if policy == nil {
+		policy = &DefaultReconnectPolicy
+	}
+
+	err := cause
+	for attempt := 1; attempt <= policy.maxAttempts(); attempt++ {
+		time.Sleep(policy.backoff(attempt))
+
+		var resumptions map[*Subscription]subscriptionResumption
+		resumptions, err = stomp.resubscribe()
+		if err == nil {
+			for sub, resumption := range resumptions {
+				sub.resume(resumption.acker, resumption.receiptChannel)
+			}
+			stomp.notifyState(CONNECTED, nil)
+			return
+		}
+		if err == ErrClientClosed {
+			break
+		}
+		stomp.lg.Warn("STOMP reconnection failed", "attempt", attempt, loglib.KeyError, err)
+	}
+
+	stomp.cmu.Lock()
+	subscriptions := stomp.subscriptions
+	stomp.subscriptions = make(map[string]*activeSubscription)
+	stomp.cmu.Unlock()
+
+	for _, active := range subscriptions {
+		active.subscription.stop()
+	}
+	stomp.notifyState(FAILED, err)
+}
+
+// resubscribe re-issues the active subscriptions that are not subscribed over the current connection yet
+func (stomp *StompClient) resubscribe() (map[*Subscription]subscriptionResumption, error) {
+	stomp.cmu.Lock()
+	defer stomp.cmu.Unlock()
+
+	if stomp.closed {
+		return nil, ErrClientClosed
+	}
+	if stomp.stompConnection == nil {
+		if _, err := stomp.makeStompConnection(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	conn := stomp.stompConnection
+
+	result := make(map[*Subscription]subscriptionResumption)
+	for _, active := range stomp.subscriptions {
+		if active.stompConnection == conn {
+			continue
+		}
+
+		receiptChannel, err := conn.Subscribe(active.headers)
+		if err != nil {
+			// start over with a fresh connection
+			for _, resubscribed := range stomp.subscriptions {
+				if resubscribed.stompConnection == conn {
+					resubscribed.stompConnection = nil
+				}
+			}
+			stomp.stompConnection = nil
+			conn.Disconnect(stompngo.Headers{"noreceipt", "true"})
+			return nil, err
+		}
+
+		active.stompConnection = conn
+		result[active.subscription] = subscriptionResumption{
+			acker:          conn,
+			receiptChannel: receiptChannel}
+	}
+	return result, nil
+}
+
+// unsubscribe forgets the subscription, a subscription over a lost connection is gone already
+func (stomp *StompClient) unsubscribe(uid string, headers stompngo.Headers) error {
+	stomp.cmu.Lock()
+	defer stomp.cmu.Unlock()
+
+	active, isActive := stomp.subscriptions[uid]
+	delete(stomp.subscriptions, uid)
+
+	if !isActive || stomp.stompConnection == nil || active.stompConnection != stomp.stompConnection {
+		return nil
+	}
+
+	err := stomp.stompConnection.Unsubscribe(headers)
+	if err == stompngo.ECONBAD {
+		return nil
+	}
+	return err
+}
+
+func (stomp *StompClient) notifyState(state ConnectionState, err error) {
+	stomp.cmu.Lock()
+	handler := stomp.stateHandler
+	stomp.cmu.Unlock()
+
+	if err != nil {
+		stomp.lg.Info("STOMP connection state", "state", state, loglib.KeyError, err)
+	} else {
+		stomp.lg.Info("STOMP connection state", "state", state)
+	}
+	if handler != nil {
+		handler(state, err)
+	}
+}
@@ -0,0 +1,87 @@
+package eventuate
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+)
+
+// Timeouts of the STOMP connections when not set with SetTimeouts
+const (
+	DefaultStompConnectTimeout   = 10 * time.Second
+	DefaultStompHandshakeTimeout = 10 * time.Second
+)
+
+// stompTLSSchemes are the URL schemes of the STOMP servers reached over TLS
+var stompTLSSchemes = map[string]bool{
+	"https":     true,
+	"stomp+ssl": true,
+	"stomp+tls": true,
+	"stomps":    true,
+	"ssl":       true,
+	"tls":       true,
+	"wss":       true}
+
+const (
+	stompPort        = "61613"
+	stompTLSPort     = "61614"
+	webSocketPort    = "80"
+	webSocketTLSPort = "443"
+)
+
+func (stomp *StompClient) usesTLS() bool {
+	return stompTLSSchemes[strings.ToLower(stomp.Url.Scheme)]
+}
+
+// address is the host and port to dial, the port defaults to the one of the scheme and the transport
+func (stomp *StompClient) address() string {
+	if stomp.Url.Port() != "" {
+		return stomp.Url.Host
+	}
+	port := stompPort
+	switch {
+	case stomp.usesWebSocket() && stomp.usesTLS():
+		port = webSocketTLSPort
+	case stomp.usesWebSocket():
+		port = webSocketPort
+	case stomp.usesTLS():
+		port = stompTLSPort
+	}
+	return net.JoinHostPort(stomp.Url.Hostname(), port)
+}
+
+// clientTLSConfig is a copy of the configured TLS config, verifying the certificate of the STOMP host by default
+func (stomp *StompClient) clientTLSConfig() *tls.Config {
+	config := &tls.Config{}
+	if stomp.tlsConfig != nil {
+		config = stomp.tlsConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = stomp.getHost()
+	}
+	return config
+}
+
+// SetTLSConfig sets the roots, client certificates and server name of the TLS connections, nil restores the defaults
+func (stomp *StompClient) SetTLSConfig(config *tls.Config) {
+	stomp.tlsConfig = config
+}
+
+// SetInsecureSkipVerify turns off the verification of the server certificate, for development servers only
+func (stomp *StompClient) SetInsecureSkipVerify(flag bool) {
+	config := stomp.clientTLSConfig()
+	config.InsecureSkipVerify = flag
+	stomp.tlsConfig = config
+}
+
+// SetTimeouts limits the time to open a TCP connection and the time of the TLS and STOMP handshakes that follow.
+// Zero keeps the current timeout.
+func (stomp *StompClient) SetTimeouts(connect, handshake time.Duration) {
+	if connect > 0 {
+		stomp.connectTimeout = connect
+	}
+	if handshake > 0 {
+		stomp.handshakeTimeout = handshake
+	}
+}
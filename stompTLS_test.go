@@ -0,0 +1,57 @@
+package eventuate_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStompClient_TLS(t *testing.T) {
+	srv, err := eventuatetest.NewTLSServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	assert.True(t, strings.HasPrefix(srv.StompURL, "stomp+ssl://"))
+
+	// the certificate of the server is not trusted by default
+	untrusted, err := eventuate.ClientBuilder().
+		WithStompUrl(srv.StompURL).
+		WithCredentials("eventuatetest", "eventuatetest").
+		WithStompTimeouts(time.Second, time.Second).
+		BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = untrusted.Subscribe("tls-subscriber", map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}, nil, nil)
+	assert.Error(t, err)
+
+	rest, err := srv.ClientBuilder().BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stomp, err := srv.ClientBuilder().BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := stomp.Subscribe("tls-subscriber", map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	saved, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Arthur Dent"}`}}, nil)
+	assert.Nil(t, err)
+
+	evt, err := sub.ReadEvent()
+	assert.Nil(t, err)
+	assert.Equal(t, saved.EventIds[0], evt.Id)
+}
@@ -0,0 +1,177 @@
+package eventuate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// StompTransport is how the STOMP frames reach the server
+type StompTransport int
+
+const (
+	// StompTransportAuto uses WebSocket for the `ws` and `wss` URL schemes and TCP for the others
+	StompTransportAuto StompTransport = iota
+	StompTransportTCP
+	StompTransportWebSocket
+)
+
+var stompWebSocketSchemes = map[string]bool{
+	"ws":  true,
+	"wss": true}
+
+// stompWebSocketProtocol is the WebSocket subprotocol of STOMP 1.2
+const stompWebSocketProtocol = "v12.stomp"
+
+func (stomp *StompClient) usesWebSocket() bool {
+	switch stomp.transport {
+	case StompTransportTCP:
+		return false
+	case StompTransportWebSocket:
+		return true
+	}
+	return stompWebSocketSchemes[strings.ToLower(stomp.Url.Scheme)]
+}
+
+// SetTransport selects TCP or WebSocket regardless of the URL scheme
+func (stomp *StompClient) SetTransport(transport StompTransport) {
+	stomp.transport = transport
+}
+
+// SetProxy makes the WebSocket connections go through the HTTP proxy `proxyUrl`, taken from the environment
+// (HTTPS_PROXY, HTTP_PROXY) when empty
+func (stomp *StompClient) SetProxy(proxyUrl string) error {
+	if proxyUrl == "" {
+		stomp.proxyUrl = nil
+		return nil
+	}
+	parsed, err := url.Parse(proxyUrl)
+	if err != nil {
+		return AppError("Invalid proxy URL: %w", err)
+	}
+	stomp.proxyUrl = parsed
+	return nil
+}
+
+// dial opens the TCP connection to the server, or to the server through the proxy for WebSocket
+func (stomp *StompClient) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: stomp.connectTimeout}
+	address := stomp.address()
+
+	proxyUrl, proxyErr := stomp.proxy()
+	if proxyErr != nil {
+		return nil, proxyErr
+	}
+	if proxyUrl == nil {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+
+	n, err := dialer.DialContext(ctx, "tcp", proxyUrl.Host)
+	if err != nil {
+		return nil, err
+	}
+	n.SetDeadline(time.Now().Add(stomp.handshakeTimeout))
+
+	connect := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header)}
+	if proxyUrl.User != nil {
+		password, _ := proxyUrl.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyUrl.User.Username() + ":" + password))
+		connect.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err = connect.Write(n); err != nil {
+		n.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(n), connect)
+	if err != nil {
+		n.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		n.Close()
+		return nil, AppError("Proxy %s refused to connect to %s: %s", proxyUrl.Host, address, resp.Status)
+	}
+	return n, nil
+}
+
+func (stomp *StompClient) proxy() (*url.URL, error) {
+	if !stomp.usesWebSocket() {
+		return nil, nil
+	}
+	if stomp.proxyUrl != nil {
+		return stomp.proxyUrl, nil
+	}
+	return http.ProxyFromEnvironment(&http.Request{URL: stomp.webSocketUrl()})
+}
+
+// webSocketUrl is the URL of the WebSocket handshake, with the scheme http proxies are looked up by
+func (stomp *StompClient) webSocketUrl() *url.URL {
+	result := *stomp.Url
+	result.Scheme = "ws"
+	if stomp.usesTLS() {
+		result.Scheme = "wss"
+	}
+	result.Host = stomp.address()
+	return &result
+}
+
+// makeWebSocketConnection makes the WebSocket handshake over `n`, connected to the server
+func (stomp *StompClient) makeWebSocketConnection(n net.Conn) (net.Conn, error) {
+	location := stomp.webSocketUrl()
+	origin := &url.URL{Scheme: "http", Host: location.Host}
+	if stomp.usesTLS() {
+		origin.Scheme = "https"
+	}
+
+	ws, err := websocket.NewClient(&websocket.Config{
+		Location: location,
+		Origin:   origin,
+		Protocol: []string{stompWebSocketProtocol},
+		Version:  websocket.ProtocolVersionHybi13}, n)
+	if err != nil {
+		return nil, err
+	}
+	return &stompFrameConn{Conn: ws}, nil
+}
+
+// stompFrameConn sends a WebSocket message per STOMP frame, where stompngo may write a frame in several pieces
+type stompFrameConn struct {
+	*websocket.Conn
+	pending []byte
+}
+
+func (conn *stompFrameConn) Write(p []byte) (int, error) {
+	conn.pending = append(conn.pending, p...)
+	for {
+		end := bytes.IndexByte(conn.pending, 0)
+		if end < 0 {
+			break
+		}
+		if _, err := conn.Conn.Write(conn.pending[:end+1]); err != nil {
+			return 0, err
+		}
+		conn.pending = conn.pending[end+1:]
+	}
+
+	// heart-beats are bare end-of-lines between the frames
+	if len(conn.pending) > 0 && len(bytes.Trim(conn.pending, "\r\n")) == 0 {
+		if _, err := conn.Conn.Write(conn.pending); err != nil {
+			return 0, err
+		}
+		conn.pending = nil
+	}
+	return len(p), nil
+}
@@ -0,0 +1,92 @@
+package eventuate_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStompClient_WebSocket(t *testing.T) {
+	for _, useTLS := range []bool{false, true} {
+		srv, err := eventuatetest.NewServer()
+		if useTLS {
+			srv, err = eventuatetest.NewTLSServer()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// an HTTP proxy allowing CONNECT only, as the egress proxies do
+		proxy, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tunnels int32
+		go func() {
+			for {
+				client, err := proxy.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer client.Close()
+					req, err := http.ReadRequest(bufio.NewReader(client))
+					if err != nil || req.Method != http.MethodConnect {
+						return
+					}
+					server, err := net.Dial("tcp", req.Host)
+					if err != nil {
+						return
+					}
+					defer server.Close()
+					atomic.AddInt32(&tunnels, 1)
+					fmt.Fprint(client, "HTTP/1.1 200 Connection established\r\n\r\n")
+					go io.Copy(server, client)
+					io.Copy(client, server)
+				}()
+			}
+		}()
+
+		rest, err := srv.ClientBuilder().BuildREST()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stomp, err := srv.ClientBuilder().
+			WithStompUrl(srv.StompWebSocketURL).
+			WithStompProxy("http://" + proxy.Addr().String()).
+			BuildSTOMP()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sub, err := stomp.Subscribe("websocket-subscriber", map[string][]string{ENTITY_TYPE: {EVENT_CREATED}}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		saved, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+			{
+				EventType: EVENT_CREATED,
+				EventData: `{"name":"Arthur Dent"}`}}, nil)
+		assert.Nil(t, err)
+
+		evt, err := sub.ReadEvent()
+		assert.Nil(t, err)
+		assert.Equal(t, saved.EventIds[0], evt.Id)
+		sub.AcknowledgeEvent(evt)
+		awaitAcks(t, sub)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+
+		assert.Nil(t, sub.Unsubscribe())
+		proxy.Close()
+		srv.Close()
+	}
+}
@@ -1,13 +1,13 @@
 package eventuate
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gmallard/stompngo"
 	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
 	"strings"
 	"sync"
-	"time"
 )
 
 // Subscription is the struct for subscription
@@ -23,14 +23,32 @@ type Subscription struct {
 	unsubscribeFn            func() error
 	reqStop                  chan bool
 	reqCleanup               chan bool
+	reqReset                 chan Acker
+	resumed                  chan subscriptionResumption
+	done                     chan struct{}
+	readerDone               chan struct{}
 	pendingsCountReqChannel  chan bool
 	pendingsCountRespChannel chan int
+	flushReqChannel          chan bool
+	flushRespChannel         chan int
+	draining                 chan struct{} // closed once the subscription stops accepting events
+	drainOnce                sync.Once
+	awaitHandlers            func(ctx context.Context) error
 	eventHandler             *EventResultHandler
+	progressHandler          ProgressNotificationHandler
+	subscriberId             string
+	metrics                  MetricsRecorder
 	ll                       loglib.LogLevelEnum
-	lg                       loglib.Logger
+	lg                       loglib.LeveledLogger
 	lmu                      sync.Mutex
 }
 
+// subscriptionResumption carries a subscription re-issued over a new STOMP connection
+type subscriptionResumption struct {
+	acker          Acker
+	receiptChannel <-chan stompngo.MessageData
+}
+
 type pendingAcknowledge struct {
 	EventID   Int128
 	Acked     bool
@@ -47,7 +65,11 @@ func newSubscription(
 	uid string,
 	conn *stompngo.Connection, //Acker,
 	receiptChannel <-chan stompngo.MessageData,
-	eventHandler *EventResultHandler) *Subscription {
+	eventHandler *EventResultHandler,
+	progressHandler ProgressNotificationHandler,
+	subscriberId string,
+	metrics MetricsRecorder,
+	lg loglib.LeveledLogger) *Subscription {
 
 	sub := &Subscription{
 		//stomp:                   stomp,
@@ -59,137 +81,147 @@ func newSubscription(
 		ackEvent:                 make(chan *StompEvent, 16),
 		reqStop:                  make(chan bool),
 		reqCleanup:               make(chan bool),
+		reqReset:                 make(chan Acker),
+		resumed:                  make(chan subscriptionResumption, 1),
+		done:                     make(chan struct{}),
+		readerDone:               make(chan struct{}),
 		pendingsCountReqChannel:  make(chan bool),
 		pendingsCountRespChannel: make(chan int),
+		flushReqChannel:          make(chan bool),
+		flushRespChannel:         make(chan int),
+		draining:                 make(chan struct{}),
 		eventHandler:             eventHandler,
+		progressHandler:          progressHandler,
+		subscriberId:             subscriberId,
+		metrics:                  metricsOrNoop(metrics),
 		ll:                       loglib.Silent,
-		lg:                       loglib.NewLogger(loglib.Silent)}
-
-	go func(sub *Subscription) {
-		for {
-			if conn.Connected() {
-				sub.lg.Printf("STOMP Connection alive (Sub. #%v)", sub.Id)
-				time.Sleep(time.Duration(1) * time.Minute)
-				continue
-			}
-			sub.lg.Printf("STOMP Connection SEVERED. (Sub. #%v)", sub.Id)
-		}
-
-	}(sub)
+		lg:                       lg}
 
 	var pchan chan pendingAcknowledge = make(chan pendingAcknowledge)
 
 	go func(sub *Subscription, pchan chan pendingAcknowledge) {
-		for md := range sub.receiptChannel {
-			var (
-				stompEvent StompEvent
-				err        error
-			)
-
-			if md.Error != nil {
-				sub.subscriptionErrors <- err
-				sub.reqStop <- true
+		defer close(sub.readerDone)
 
-				continue
+		receiptChannel := sub.receiptChannel
+		for {
+			if isStopped := sub.readMessages(receiptChannel, pchan); isStopped {
+				return
 			}
 
-			if md.Message.Command != stompngo.MESSAGE {
-				sub.lg.Printf("Bad frame: %v", md.Message.Command)
-				sub.subscriptionErrors <- AppError("Bad frame: %v", md.Message.Command)
-				continue
+			// the connection is lost, wait for the StompClient to re-issue the subscription
+			var resumption subscriptionResumption
+			select {
+			case resumption = <-sub.resumed:
+			case <-sub.done:
+				return
 			}
+			sub.lg.Info("Subscription resumed on a new connection")
 
-			err = json.Unmarshal(md.Message.Body, &stompEvent)
-			if err != nil {
-				sub.lg.Printf("Cannot unmarshal event body: %v with error: %v", string(md.Message.Body), err.Error())
-				sub.subscriptionErrors <- err
-				continue
+			select {
+			case sub.reqReset <- resumption.acker:
+			case <-sub.done:
+				return
 			}
-
-			ackHeaderId := md.Message.Headers.Value("ack")
-			pchan <- pendingAcknowledge{
-				EventID:   stompEvent.Id,
-				Acked:     false,
-				AckHeader: ackHeaderId,
-				DebugInfo: stompEvent.String()}
-
-			sub.incomingEvent <- stompEvent
+			receiptChannel = resumption.receiptChannel
 		}
-
 	}(sub, pchan)
 
 	go func(acker Acker, sub *Subscription, pchan chan pendingAcknowledge) {
-		sub.lg.Println("Subscription go-routine started")
-		defer sub.lg.Println("Subscription go-routine finished")
-		defer sub.Unsubscribe()
+		sub.lg.Debug("Subscription go-routine started")
+		defer sub.lg.Debug("Subscription go-routine finished")
 
 		pendings := make([]pendingAcknowledge, 0)
 
-		for {
+		acknowledge := func(event *StompEvent) {
+			sub.lg.Debug("Acknowledging event", loglib.KeyEventId, event.Id)
 
-			if !sub.IsActive() {
-				return
+			needAcks, nextPendings := sub.getAcks(pendings, event)
+			sub.lg.Debug("Pending acks", "pending_acks", len(pendings), "need_acks", len(needAcks), "next_pending_acks", len(nextPendings))
+
+			acked := 0
+			for _, pending := range needAcks {
+
+				ackHeaders := stompngo.Headers{
+					"id", pending.AckHeader}
+
+				err := acker.Ack(ackHeaders)
+				if err != nil {
+					sub.lg.Error("STOMP ack failed", loglib.KeyEventId, pending.EventID, loglib.KeyError, err)
+
+					sub.subscriptionErrors <- AppError(
+						"Error in StompConnection.Ack(ackHeaders): %s\n%v",
+						pending.AckHeader, err)
+				} else {
+					sub.lg.Debug("STOMP ack sent", loglib.KeyEventId, pending.EventID)
+					acked++
+				}
 			}
 
+			pendings = nextPendings
+			sub.metrics.CountEventsAcked(sub.subscriberId, acked)
+			sub.metrics.SetPendingAcks(sub.subscriberId, len(pendings))
+		}
+
+		for {
+
 			select {
 			case <-sub.reqStop:
 				{
-					sub.lg.Println("reqStop chan, will terminate")
+					sub.lg.Debug("Subscription stopped")
+
+					sub.RWMutex.Lock()
+					sub.isActive = false
+					sub.RWMutex.Unlock()
+
+					sub.metrics.SetPendingAcks(sub.subscriberId, 0)
+					sub.closeChannels()
 					return
 				}
 
 			case pack := <-pchan:
 				{
 					pendings = append(pendings, pack)
+					sub.metrics.CountEventsReceived(sub.subscriberId, 1)
+					sub.metrics.SetPendingAcks(sub.subscriberId, len(pendings))
+				}
+			case nextAcker := <-sub.reqReset:
+				{
+					// messages of the lost connection are redelivered, hence cannot be acked
+					sub.lg.Info("Subscription reset, dropping the pending acks", "pending_acks", len(pendings))
+					acker = nextAcker
+					pendings = make([]pendingAcknowledge, 0)
+					sub.metrics.SetPendingAcks(sub.subscriberId, 0)
 				}
 			case event := <-sub.ackEvent:
 				{
-					sub.lg.Printf("newSubscription.g3: Received event via ackEvent channel: %v\n", event)
-
-					needAcks, nextPendings := sub.getAcks(pendings, event)
-					sub.lg.Printf("newSubscription.g3: Pendings count: %v, Need Acks count: %v, Next pendings count: %v\n", len(pendings), len(needAcks), len(nextPendings))
-
-					for _, pending := range needAcks {
-
-						ackHeaders := stompngo.Headers{
-							"id", pending.AckHeader}
-
-						sub.lg.Printf("newSubscription.g3: Before calling stompngo.Ack: %v\n", ackHeaders)
-						err := acker.Ack(ackHeaders)
-						if err != nil {
-							sub.lg.Printf("newSubscription.g3: Error in stompngo.Ack(ackHeaders): %s\n%v",
-								pending.AckHeader, err)
-
-							sub.subscriptionErrors <- AppError(
-								"Error in StompConnection.Ack(ackHeaders): %s\n%v",
-								pending.AckHeader, err)
-						} else {
-							sub.lg.Printf("newSubscription.g3: After calling stompngo.Ack (OK): %v\n", ackHeaders)
+					acknowledge(event)
+				}
+			case <-sub.flushReqChannel:
+				{
+					// the acks requested before the flush are sent before answering
+					for flushed := false; !flushed; {
+						select {
+						case event := <-sub.ackEvent:
+							acknowledge(event)
+						default:
+							flushed = true
 						}
 					}
-
-					pendings = nextPendings
+					sub.flushRespChannel <- len(pendings)
 				}
 
 			case <-sub.reqCleanup:
 				{
-					sub.lg.Println("reqCleanup channel (cleaning & closing)")
-
-					// cleanup
-					close(sub.reqStop)
-					close(sub.reqCleanup)
-					close(sub.incomingEvent)
-					close(sub.subscriptionErrors)
-					close(sub.ackEvent)
-					close(sub.pendingsCountReqChannel)
-					close(sub.pendingsCountRespChannel)
-					sub.eventHandler = nil
+					sub.lg.Debug("Subscription cleaned up")
+
+					sub.metrics.SetPendingAcks(sub.subscriberId, 0)
+					sub.closeChannels()
 					return
 				}
 			case err := <-sub.subscriptionErrors:
 				{
-					if err != nil && sub.lg != nil {
-						sub.lg.Printf("subscriptionErrors channel (proceeding): %v", err.Error())
+					if err != nil {
+						sub.lg.Warn("Subscription error", loglib.KeyError, err)
 					}
 				}
 
@@ -205,6 +237,104 @@ func newSubscription(
 	return sub
 }
 
+// readMessages forwards the events of a connection until the connection is lost
+// or the subscription is stopped, the latter is reported in `isStopped`
+func (sub *Subscription) readMessages(receiptChannel <-chan stompngo.MessageData, pchan chan pendingAcknowledge) (isStopped bool) {
+	for {
+		var (
+			md         stompngo.MessageData
+			isOpen     bool
+			stompEvent StompEvent
+			err        error
+		)
+
+		select {
+		case md, isOpen = <-receiptChannel:
+			if !isOpen {
+				return false
+			}
+		case <-sub.done:
+			return true
+		}
+
+		if md.Error != nil {
+			return !sub.reportError(md.Error)
+		}
+
+		if md.Message.Command != stompngo.MESSAGE {
+			sub.lg.Warn("Bad frame", "command", md.Message.Command)
+			if !sub.reportError(AppError("Bad frame: %v", md.Message.Command)) {
+				return true
+			}
+			continue
+		}
+
+		if _, isProgress := md.Message.Headers.Contains(ProgressNotificationHeader); isProgress {
+			sub.handleProgressNotification(md.Message.Body)
+			continue
+		}
+
+		err = json.Unmarshal(md.Message.Body, &stompEvent)
+		if err != nil {
+			sub.lg.Warn("Cannot unmarshal event body", loglib.KeyEventData, string(md.Message.Body), loglib.KeyError, err)
+			if !sub.reportError(err) {
+				return true
+			}
+			continue
+		}
+
+		if sub.isDraining() {
+			// not pending, the server redelivers it to the next subscription
+			sub.lg.Debug("Dropping event, the subscription is shutting down", loglib.KeyEventId, stompEvent.Id)
+			continue
+		}
+
+		ackHeaderId := md.Message.Headers.Value("ack")
+		select {
+		case pchan <- pendingAcknowledge{
+			EventID:   stompEvent.Id,
+			Acked:     false,
+			AckHeader: ackHeaderId,
+			DebugInfo: stompEvent.String()}:
+		case <-sub.done:
+			return true
+		}
+
+		select {
+		case sub.incomingEvent <- stompEvent:
+		case <-sub.draining:
+			// left unacknowledged, the receipt channel is read on until unsubscribing
+		case <-sub.done:
+			return true
+		}
+	}
+}
+
+// reportError is false if the subscription was stopped before the error could be reported
+func (sub *Subscription) reportError(err error) bool {
+	select {
+	case sub.subscriptionErrors <- err:
+		return true
+	case <-sub.done:
+		return false
+	}
+}
+
+func (sub *Subscription) handleProgressNotification(body []byte) {
+	var notification ProgressNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		sub.lg.Warn("Cannot unmarshal progress notification", "body", string(body), loglib.KeyError, err)
+		sub.reportError(err)
+		return
+	}
+
+	if sub.progressHandler == nil {
+		sub.lg.Debug("Progress notification without a handler", "notification", notification)
+		return
+	}
+	sub.progressHandler(&notification)
+}
+
 func (sub *Subscription) SetLogLevel(logLevel loglib.LogLevelEnum) {
 	sub.lmu.Lock()
 	sub.ll = logLevel
@@ -212,6 +342,12 @@ func (sub *Subscription) SetLogLevel(logLevel loglib.LogLevelEnum) {
 	sub.lmu.Unlock()
 }
 
+func (sub *Subscription) SetLogger(lg loglib.LeveledLogger) {
+	sub.lmu.Lock()
+	sub.lg = lg
+	sub.lmu.Unlock()
+}
+
 func (sub *Subscription) IsActive() bool {
 	sub.RWMutex.RLock()
 	defer sub.RWMutex.RUnlock()
@@ -219,19 +355,126 @@ func (sub *Subscription) IsActive() bool {
 }
 
 func (sub *Subscription) Unsubscribe() error {
-	sub.lg.Printf("Subscription.Unsubscribe()")
+	sub.lg.Debug("Unsubscribe")
 
 	sub.RWMutex.Lock()
-	defer sub.RWMutex.Unlock()
-	if sub.isActive {
-		err := sub.unsubscribeFn()
-		sub.isActive = false
-		if err == nil {
-			sub.reqCleanup <- true
+	if !sub.isActive {
+		sub.RWMutex.Unlock()
+		return nil
+	}
+	err := sub.unsubscribeFn()
+	sub.isActive = false
+	sub.RWMutex.Unlock()
+
+	// the lock must not be held here, the subscription go-routine may be waiting for it
+	if err == nil {
+		sub.reqCleanup <- true
+	}
+	return err
+}
+
+// Shutdown stops accepting events, waits until the handlers of the events dispatched already are done or `ctx` is,
+// sends the pending acks and unsubscribes. The events left unacknowledged are redelivered by the server.
+// The error of `ctx` is returned if it ended before the handlers.
+func (sub *Subscription) Shutdown(ctx context.Context) error {
+	if !sub.IsActive() {
+		return nil
+	}
+	sub.lg.Info("Shutting the subscription down")
+	sub.stopAccepting()
+
+	sub.RWMutex.RLock()
+	awaitHandlers := sub.awaitHandlers
+	sub.RWMutex.RUnlock()
+
+	var drainErr error
+	if awaitHandlers != nil {
+		if err := awaitHandlers(ctx); err != nil {
+			sub.lg.Warn("Event handlers still running on shutdown", loglib.KeyError, err)
+			drainErr = AppError("Shutdown of subscription %s: %w", sub.Id, err)
 		}
+	}
+
+	if unacked := sub.flushAcks(); unacked > 0 {
+		sub.lg.Info("Events left unacknowledged on shutdown", "pending_acks", unacked)
+	}
+
+	if err := sub.Unsubscribe(); err != nil {
 		return err
 	}
-	return nil
+	return drainErr
+}
+
+// stopAccepting makes the subscription drop the events received from now on
+func (sub *Subscription) stopAccepting() {
+	sub.drainOnce.Do(func() {
+		close(sub.draining)
+	})
+}
+
+func (sub *Subscription) isDraining() bool {
+	select {
+	case <-sub.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// flushAcks waits until the acks requested so far are sent, it is the count of the events left pending
+func (sub *Subscription) flushAcks() int {
+	select {
+	case sub.flushReqChannel <- true:
+		return <-sub.flushRespChannel
+	case <-sub.done:
+		return 0
+	}
+}
+
+// resume hands the subscription re-issued over a new connection to the reading go-routine
+func (sub *Subscription) resume(acker Acker, receiptChannel <-chan stompngo.MessageData) {
+	sub.RWMutex.RLock()
+	defer sub.RWMutex.RUnlock()
+	if !sub.isActive {
+		return
+	}
+
+	// a resumption not picked up yet belongs to a connection that is lost already
+	select {
+	case <-sub.resumed:
+	default:
+	}
+	sub.resumed <- subscriptionResumption{
+		acker:          acker,
+		receiptChannel: receiptChannel}
+}
+
+// stop terminates the subscription whose connection cannot be re-established
+func (sub *Subscription) stop() {
+	sub.RWMutex.Lock()
+	if !sub.isActive {
+		sub.RWMutex.Unlock()
+		return
+	}
+	sub.isActive = false
+	sub.RWMutex.Unlock()
+
+	sub.reqCleanup <- true
+}
+
+// closeChannels stops the reading go-routine first, it is the one sending to the channels closed here.
+// `subscriptionErrors` and `ackEvent` are left open, the handlers still running report and ack through them.
+func (sub *Subscription) closeChannels() {
+	close(sub.done)
+	<-sub.readerDone
+
+	close(sub.reqStop)
+	close(sub.reqCleanup)
+	close(sub.resumed)
+	close(sub.incomingEvent)
+	close(sub.pendingsCountReqChannel)
+	close(sub.pendingsCountRespChannel)
+	sub.eventHandler = nil
 }
 
 // ReadEvent is the function to read Event from subscription
@@ -243,6 +486,24 @@ func (sub *Subscription) ReadEvent() (*StompEvent, error) {
 	return nil, AppError("Cannot read from a closed Subscription")
 }
 
+// ReadEventCtx is ReadEvent that gives up with `ctx.Err()` once the context is done
+func (sub *Subscription) ReadEventCtx(ctx context.Context) (*StompEvent, error) {
+	select {
+	case evt, ok := <-sub.incomingEvent:
+		if ok {
+			return &evt, nil
+		}
+		return nil, AppError("Cannot read from a closed Subscription")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Done is closed once the subscription is unsubscribed or stopped
+func (sub *Subscription) Done() <-chan struct{} {
+	return sub.done
+}
+
 // ReadEventNonblocking is a function that doesn't block reading of events
 func (sub *Subscription) ReadEventNonblocking() (*StompEvent, error, bool) {
 	select {
@@ -267,10 +528,12 @@ func (sub *Subscription) ReadEventNonblocking() (*StompEvent, error, bool) {
 	}
 }
 
-// AcknowledgeEvent is the function to ack an Event
+// AcknowledgeEvent is the function to ack an Event, a no-op once the subscription is done
 func (sub *Subscription) AcknowledgeEvent(event *StompEvent) {
-	sub.ackEvent <- event
-	return
+	select {
+	case sub.ackEvent <- event:
+	case <-sub.done:
+	}
 }
 
 // FetchPendingsCount is an internal function to check the count of events awaiting their acks
@@ -281,27 +544,29 @@ func (sub *Subscription) FetchPendingsCount() int {
 
 func (sub *Subscription) getAcks(pendings []pendingAcknowledge, event *StompEvent) (needAcks []pendingAcknowledge, nextPendings []pendingAcknowledge) {
 
-	sub.lg.Printf("getAcks. Debug info: \n%v", sub.getDebugInfo(pendings, event.Id))
-	sub.lg.Println(sub.listUnacked(pendings))
+	if sub.lg.Enabled(loglib.Debug) {
+		sub.lg.Debug("Pending acks before the ack", loglib.KeyEventId, event.Id,
+			"pending_acks", sub.getDebugInfo(pendings, event.Id), loglib.KeyEvents, sub.listUnacked(pendings))
+	}
 	i := sub.findEventIndex(pendings, event.Id)
 
 	if i == -1 {
-		sub.lg.Printf("getAcks. Event with Id %v missing from pendingAcknowledges", event.Id)
+		sub.lg.Warn("Acknowledged event is not pending", loglib.KeyEventId, event.Id)
 		return make([]pendingAcknowledge, 0), pendings
 	}
 
-	sub.lg.Printf("getAcks. Event with Id %v present in pendingAcknowledges at #%v", event.Id, i)
-
 	// we mark the event first
 	pendings[i].Acked = true
 
 	i = findFirstNotAckedIndex(pendings)
 
 	needAcks = pendings[0:i]
-	sub.lg.Printf("getAcks. Count of events that need acks: %v", len(needAcks))
 
 	nextPendings = pendings[i:]
-	sub.lg.Printf("getAcks. Debug info (nextPendings): \n%v", sub.getDebugInfo(nextPendings, event.Id))
+	if sub.lg.Enabled(loglib.Debug) {
+		sub.lg.Debug("Pending acks after the ack", loglib.KeyEventId, event.Id,
+			"need_acks", len(needAcks), "pending_acks", sub.getDebugInfo(nextPendings, event.Id))
+	}
 
 	reallocatedNext := make([]pendingAcknowledge, len(nextPendings))
 	copy(reallocatedNext, nextPendings)
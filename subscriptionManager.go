@@ -1,7 +1,10 @@
 package eventuate
 
 import (
+	"context"
+
 	"github.com/eventuate-clients/eventuate-client-golang/future"
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
 )
 
 type subscriptionManager struct {
@@ -19,11 +22,24 @@ func (mgr *subscriptionManager) Subscribe(
 	subscriberOptions *SubscriberOptions,
 	useSwimlane bool) (*DispatchingSubscription, error) {
 
+	return mgr.SubscribeCtx(context.Background(), subscriberId, eventHandlers, subscriberOptions, useSwimlane)
+}
+
+func (mgr *subscriptionManager) SubscribeCtx(
+	ctx context.Context,
+	subscriberId string,
+	eventHandlers *EventResultHandlerMap,
+	subscriberOptions *SubscriberOptions,
+	useSwimlane bool) (*DispatchingSubscription, error) {
+
 	dspMaker := DispatcherMaker(NewEventDispatcher)
-	if useSwimlane {
+	if subscriberOptions != nil && subscriberOptions.Swimlanes != nil {
+		dspMaker = SwimlaneDispatcherMaker(*subscriberOptions.Swimlanes)
+	} else if useSwimlane {
 		dspMaker = DispatcherMaker(NewEventTypeSwimlaneDispatcher)
 	}
 	return mgr.subscribeForStrategy(
+		ctx,
 		subscriberId,
 		eventHandlers,
 		subscriberOptions,
@@ -31,41 +47,93 @@ func (mgr *subscriptionManager) Subscribe(
 }
 
 func (mgr *subscriptionManager) subscribeForStrategy(
+	ctx context.Context,
 	subscriberId string,
 	eventHandlers *EventResultHandlerMap,
 	subscriberOptions *SubscriberOptions,
 	dispatcherMaker DispatcherMaker) (*DispatchingSubscription, error) {
 
+	if subscriberOptions != nil {
+		if err := subscriberOptions.FailurePolicy.validate(); err != nil {
+			return nil, err
+		}
+	}
+	entityEventsGroup := eventHandlers.transformToEntityEventGroups()
+	if len(entityEventsGroup) == 0 {
+		// the handlers of any entity type cannot be subscribed on their own
+		return nil, AppError("Subscriber %s: no entity type to subscribe to", subscriberId)
+	}
+	upcasters := mgr.StompClient.upcasters
+	for entityType, eventTypes := range entityEventsGroup {
+		// events stored under their former names are upcast to the handled ones on receipt
+		for _, eventType := range eventTypes {
+			entityEventsGroup[entityType] = append(entityEventsGroup[entityType], upcasters.OldEventTypes(eventType)...)
+		}
+	}
+
+	msub := &DispatchingSubscription{
+		eventHandlers: eventHandlers,
+		typeHints:     mgr.typeHints,
+		upcasters:     upcasters,
+		codecs:        mgr.StompClient.codecs,
+		encryptor:     mgr.StompClient.encryptor,
+		tracer:        mgr.StompClient.tracer,
+		subscriberId:  subscriberId,
+		drained:       make(chan struct{})}
+
+	var middleware []HandlerMiddleware
+	if subscriberOptions != nil {
+		msub.failures = subscriberOptions.FailurePolicy
+		if msub.failures.maxAttempts() > 1 {
+			// outermost, the retries of an event hold its lane
+			middleware = append(middleware, msub.retryMiddleware(msub.failures.Retry))
+		}
+		middleware = append(middleware, subscriberOptions.Middleware...)
+	}
+	if len(middleware) > 0 {
+		dispatcherMaker = MiddlewareDispatcherMaker(dispatcherMaker, middleware...)
+	}
 	dispatcher, err := dispatcherMaker(eventHandlers)
 	if err != nil {
 		return nil, err
 	}
+	if swimlaneDispatcher, isSwimlane := dispatcher.(*EventTypeSwimlaneDispatcher); isSwimlane {
+		swimlaneDispatcher.SetMetricsRecorder(mgr.StompClient.metrics, subscriberId)
+		swimlaneDispatcher.SetLogger(mgr.lg.With(loglib.KeySubscriberId, subscriberId))
+	}
 
 	evtHandler := EventResultHandler(func(data interface{}, meta *EventMetadata) future.Settler {
-		mgr.lg.Printf("SUBS_MANAGER: handling event: %v, (%#v)\n", meta.Id, meta)
-		defer mgr.lg.Printf("SUBS_MANAGER: handling event (finished): %v\n", meta.Id)
-
 		return dispatcher.Dispatch(data, meta)
 	})
 
-	entityEventsGroup := eventHandlers.transformToEntityEventGroups()
-
-	sub, subErr := mgr.StompClient.Subscribe(subscriberId, entityEventsGroup, subscriberOptions, &evtHandler)
+	sub, subErr := mgr.StompClient.SubscribeCtx(ctx, subscriberId, entityEventsGroup, subscriberOptions, &evtHandler)
 	if subErr != nil {
+		closeDispatcher(dispatcher)
 		return nil, subErr
 	}
 
-	msub := &DispatchingSubscription{
-		Subscription:  sub,
-		eventHandlers: eventHandlers,
-		typeHints:     mgr.typeHints}
+	// set before any event is dispatched
+	msub.Subscription = sub
+	msub.handler = evtHandler
+	sub.RWMutex.Lock()
+	sub.awaitHandlers = msub.awaitHandlers
+	sub.RWMutex.Unlock()
 
 	go func(sub *Subscription) {
 		for evt := range sub.incomingEvent {
-			msub.lg.Printf("Received event via STOMP chan: %v (before dispatching)\n", evt.String())
+			msub.lg.Debug("Dispatching event", loglib.KeyEntityType, evt.EntityType, loglib.KeyEntityId, evt.EntityId,
+				loglib.KeyEventType, evt.EventType, loglib.KeyEventId, evt.Id)
 			msub.dispatchEvent(evt)
 		}
+		closeDispatcher(dispatcher)
 	}(sub)
 
 	return msub, nil
 }
+
+// closeDispatcher stops the lanes of the dispatchers having some, once their subscription is done
+func closeDispatcher(dispatcher Dispatcher) {
+	if closer, isCloser := dispatcher.(interface{ Close() }); isCloser {
+		closer.Close()
+	}
+}
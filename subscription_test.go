@@ -0,0 +1,151 @@
+package eventuate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscription_Shutdown(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	rest := buildREST(t, srv.ClientBuilder())
+	newStomp := func() *eventuate.StompClient {
+		stomp := buildSTOMP(t, srv.ClientBuilder().
+			WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}))
+		return stomp
+	}
+	save := func(name string) {
+		_, err := rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+			{
+				EventType: EVENT_CREATED,
+				EventData: `{"name":"` + name + `"}`}}, nil)
+		assert.Nil(t, err)
+	}
+	// the handlers settle their results once `release` is closed
+	handlersUntil := func(handled chan<- string, release <-chan struct{}) *eventuate.EventResultHandlerMap {
+		return eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+			func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+				result := future.NewResult()
+				handled <- data.(*MyEntityWasCreatedEvent).Name
+				go func() {
+					<-release
+					result.Settle(true, nil)
+				}()
+				return result
+			})
+	}
+
+	// the in-flight handler is waited for and its event acked, the later events are left to the next subscription
+	save("Trillian")
+	handled := make(chan string, 10)
+	release := make(chan struct{})
+	stomp := newStomp()
+	sub, err := stomp.SubscribeAndDispatch("shutdown-subscriber", handlersUntil(handled, release), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Trillian", receive(t, handled))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- sub.Shutdown(ctx)
+	}()
+	save("Ford Prefect")
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown did not wait for the handler")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	assert.Nil(t, <-shutdown)
+	assert.False(t, sub.IsActive())
+	assert.Empty(t, handled)
+
+	// the handler outlives the deadline, its event is redelivered
+	handled = make(chan string, 10)
+	stomp = newStomp()
+	_, err = stomp.SubscribeAndDispatch("shutdown-subscriber", handlersUntil(handled, make(chan struct{})), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Ford Prefect", receive(t, handled))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(stomp.Shutdown(ctx), context.DeadlineExceeded))
+	_, err = stomp.SubscribeAndDispatch("shutdown-subscriber", handlersUntil(handled, release), nil, false)
+	assert.True(t, errors.Is(err, eventuate.ErrClientClosed))
+
+	_, err = newStomp().SubscribeAndDispatch("shutdown-subscriber", handlersUntil(handled, release), nil, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ford Prefect", receive(t, handled))
+}
+
+func TestSubscribeAndDispatchCtx_DrainsOnCancel(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	rest := buildREST(t, srv.ClientBuilder())
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}))
+
+	handled := make(chan string, 10)
+	release := make(chan struct{})
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			result := future.NewResult()
+			handled <- data.(*MyEntityWasCreatedEvent).Name
+			go func() {
+				<-release
+				result.Settle(true, nil)
+			}()
+			return result
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, err := stomp.SubscribeAndDispatchCtx(ctx, "ctx-drain-subscriber", handlers, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rest.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_CREATED,
+			EventData: `{"name":"Arthur Dent"}`}}, nil)
+	assert.Nil(t, err)
+	receive(t, handled)
+
+	// the handler running when the context is cancelled is waited for and its event acked
+	cancel()
+	select {
+	case <-sub.Done():
+		t.Fatal("subscription did not wait for the handler")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-sub.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not shut down")
+	}
+
+	// the acked event is not redelivered
+	_, err = stomp.SubscribeAndDispatch("ctx-drain-subscriber", handlers, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-handled:
+		t.Fatalf("event %s was redelivered", name)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -3,8 +3,10 @@ package eventuate_test
 import (
 	"net/http"
 	"testing"
+	"time"
 
-	//"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,6 +30,70 @@ func assertNoError(t *testing.T, err error) {
 	assert.Equal(t, nil, err)
 }
 
+// MyEntityWasCreatedEvent is the type hint of EVENT_CREATED (and EVENT_CHANGED) in the tests against the emulator
+type MyEntityWasCreatedEvent struct {
+	Name string `json:"name"`
+}
+
+// newEmulator starts the server emulator the subscription tests run against
+func newEmulator(t *testing.T) *eventuatetest.Server {
+	srv, err := eventuatetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// buildREST and buildSTOMP build the clients the emulator tests run with
+func buildREST(t *testing.T, builder *eventuate.ClientBuilderInstance) *eventuate.RESTClient {
+	client, err := builder.BuildREST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func buildSTOMP(t *testing.T, builder *eventuate.ClientBuilderInstance) *eventuate.StompClient {
+	client, err := builder.BuildSTOMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// receive waits for a value sent by a handler, failing the test after 5 seconds
+func receive[T any](t *testing.T, values <-chan T) T {
+	t.Helper()
+	select {
+	case value := <-values:
+		return value
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not dispatched")
+		var none T
+		return none
+	}
+}
+
+// eventually polls `cond` for up to 5 seconds, it reports whether it held
+func eventually(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// awaitAcks waits until the subscription has sent all acks it was asked for
+func awaitAcks(t *testing.T, sub *eventuate.Subscription) {
+	if err := eventuatetest.AwaitAcks(sub, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
 //
 //func readNEventsSequentially(sub *eventuate.Subscription, count int) []*eventuate.StompEvent {
 //
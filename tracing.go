@@ -0,0 +1,59 @@
+package eventuate
+
+import "context"
+
+// Attributes of the spans started by the client
+const (
+	SpanAttributeEntityType   = "eventuate.entity_type"
+	SpanAttributeEntityId     = "eventuate.entity_id"
+	SpanAttributeEventType    = "eventuate.event_type"
+	SpanAttributeEventId      = "eventuate.event_id"
+	SpanAttributeSubscriberId = "eventuate.subscriber_id"
+)
+
+// Tracer instruments the REST calls, the repository operations and the event handlers,
+// and carries the trace context from the writer of an event to its handlers through the event metadata.
+// The oteltracing package implements it with OpenTelemetry.
+type Tracer interface {
+	// StartSpan starts a span named `name`, child of the span of `ctx`
+	StartSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+	// Inject adds the trace context of `ctx` to the metadata of the events being written
+	Inject(ctx context.Context, metadata map[string]string)
+	// StartEventSpan starts the span of handling an event, linked to the span the event was written in
+	// as found in its metadata
+	StartEventSpan(ctx context.Context, name string, metadata map[string]string, attributes map[string]string) (context.Context, Span)
+}
+
+type Span interface {
+	// End records `err` (if not nil) as the failure of the span and ends it
+	End(err error)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+func startSpan(tracer Tracer, ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.StartSpan(ctx, name, attributes)
+}
+
+func entitySpanAttributes(entityType string, entityId Int128) map[string]string {
+	attributes := map[string]string{SpanAttributeEntityType: entityType}
+	if !entityId.IsNil() {
+		attributes[SpanAttributeEntityId] = entityId.String()
+	}
+	return attributes
+}
+
+// SetTracer sets the tracer of the REST calls and of the repositories made with this client
+func (rest *RESTClient) SetTracer(tracer Tracer) {
+	rest.tracer = tracer
+}
+
+// SetTracer sets the tracer of the event handlers of the subscriptions made with this client
+func (stomp *StompClient) SetTracer(tracer Tracer) {
+	stomp.tracer = tracer
+}
@@ -0,0 +1,106 @@
+package eventuate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/eventuatetest"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateRepository_Tracing(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+
+	recorder := eventuatetest.NewSpanRecorder()
+	crud := eventuate.NewInMemoryCrud()
+	repo := eventuate.NewAggregateRepository(crud, meta).SetTracer(recorder)
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+
+	ctx, requestSpan := recorder.StartSpan(context.Background(), "http.request", nil)
+	saved, err := repo.SaveCtx(ctx, &IncrementCommand{Amount: 2}, nil)
+	assertNoError(t, err)
+	_, err = repo.UpdateCtx(ctx, saved.EntityId, &IncrementCommand{Amount: 3}, nil)
+	assertNoError(t, err)
+	_, err = repo.UpdateCtx(ctx, eventuate.Int128Random(), &IncrementCommand{Amount: 3}, nil)
+	assert.NotNil(t, err)
+	requestSpan.End(nil)
+
+	updates := recorder.SpansNamed("eventuate.repository.Update")
+	if !assert.Len(t, updates, 2) {
+		return
+	}
+	requestId := recorder.SpansNamed("http.request")[0].Id
+	assert.Equal(t, requestId, updates[0].ParentId)
+	assert.Equal(t, COUNTER_ENTITY, updates[0].Attributes[eventuate.SpanAttributeEntityType])
+	assert.Equal(t, saved.EntityId.String(), updates[0].Attributes[eventuate.SpanAttributeEntityId])
+	assert.Nil(t, updates[0].Err)
+	assert.NotNil(t, updates[1].Err)
+
+	// the events carry the span they were written in
+	loaded, err := crud.Find(COUNTER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	if assert.Len(t, loaded.Events, 2) {
+		saveId := recorder.SpansNamed("eventuate.repository.Save")[0].Id
+		assert.JSONEq(t, `{"eventuatetest-span":"`+saveId+`"}`, loaded.Events[0].Metadata)
+		assert.JSONEq(t, `{"eventuatetest-span":"`+updates[0].Id+`"}`, loaded.Events[1].Metadata)
+	}
+}
+
+func TestSubscribeAndDispatch_Tracing(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	type MyEntity struct {
+		Name string
+	}
+	type CreateMyEntity struct {
+		Name string
+	}
+
+	recorder := eventuatetest.NewSpanRecorder()
+	repoClient := buildREST(t, srv.ClientBuilder().WithTracer(recorder))
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}).
+		WithTracer(recorder))
+
+	repo := eventuate.NewTypedRepository[MyEntity](repoClient, ENTITY_TYPE, nil)
+	eventuate.RegisterCommand(repo, func(entity *MyEntity, cmd CreateMyEntity) ([]eventuate.Event, error) {
+		return []eventuate.Event{MyEntityWasCreatedEvent{Name: cmd.Name}}, nil
+	})
+	assert.Nil(t, eventuate.RegisterEvent(repo, EVENT_CREATED, func(entity *MyEntity, evt MyEntityWasCreatedEvent) {
+		entity.Name = evt.Name
+	}))
+
+	handled := make(chan *eventuate.EventMetadata, 1)
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			handled <- meta
+			return future.NewSuccess(true)
+		})
+
+	_, err := stomp.SubscribeAndDispatch("traced-subscriber", handlers, nil, false)
+	assert.Nil(t, err)
+
+	saved, err := repo.Save(CreateMyEntity{Name: "Eddie"})
+	assert.Nil(t, err)
+
+	assert.NotNil(t, receive(t, handled).TraceContext)
+	eventually(t, func() bool {
+		return len(recorder.SpansNamed("eventuate.subscription.Handle")) > 0
+	})
+
+	repoSpans := recorder.SpansNamed("eventuate.repository.Save")
+	restSpans := recorder.SpansNamed("eventuate.rest.Save")
+	handlerSpans := recorder.SpansNamed("eventuate.subscription.Handle")
+	if assert.Len(t, repoSpans, 1) && assert.Len(t, restSpans, 1) && assert.Len(t, handlerSpans, 1) {
+		assert.Equal(t, repoSpans[0].Id, restSpans[0].ParentId)
+		assert.Equal(t, repoSpans[0].Id, handlerSpans[0].LinkedId)
+		assert.Equal(t, saved.EntityId.String(), handlerSpans[0].Attributes[eventuate.SpanAttributeEntityId])
+		assert.Equal(t, EVENT_CREATED, handlerSpans[0].Attributes[eventuate.SpanAttributeEventType])
+		assert.Equal(t, "traced-subscriber", handlerSpans[0].Attributes[eventuate.SpanAttributeSubscriberId])
+		assert.Nil(t, handlerSpans[0].Err)
+	}
+}
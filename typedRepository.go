@@ -0,0 +1,323 @@
+package eventuate
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	loglib "github.com/eventuate-clients/eventuate-client-golang/logger"
+)
+
+// TypedRepository persists aggregates of type A. Unlike AggregateRepository, which looks up
+// `ProcessXCommand` and `ApplyXEvent` methods by their names, it dispatches to the handlers
+// registered with RegisterCommand and RegisterEvent, hence mismatches are compile-time errors.
+type TypedRepository[A any] struct {
+	Client          Crud
+	entityTypeName  string
+	ctor            func() *A
+	commandHandlers map[reflect.Type]func(aggregate *A, cmd Command) ([]Event, error)
+	eventHandlers   map[string]func(aggregate *A, entityId Int128, eventData, metadata string) error
+	eventAppliers   map[reflect.Type]func(aggregate *A, event Event)
+	eventTypes      map[reflect.Type]string
+	strategy        SnapshotStrategy
+	upcasters       *UpcasterRegistry
+	codecs          *EventCodecs
+	encryptor       *PIIEncryptor
+	metadata        EventMetadataProvider
+	tracer          Tracer
+	ll              loglib.LogLevelEnum
+	lg              loglib.LeveledLogger
+}
+
+// NewTypedRepository creates a repository for the aggregate type named `entityTypeName`,
+// `ctor` creates the initial state of an aggregate (`new(A)` if nil)
+func NewTypedRepository[A any](client Crud, entityTypeName string, ctor func() *A) *TypedRepository[A] {
+	var (
+		ll        loglib.LogLevelEnum  = loglib.Silent
+		lg        loglib.LeveledLogger = loglib.NewNilLogger()
+		upcasters *UpcasterRegistry
+		codecs    *EventCodecs
+		encryptor *PIIEncryptor
+		metadata  EventMetadataProvider
+		tracer    Tracer
+	)
+	maybeRestClient, maybeRestClientOk := client.(*RESTClient)
+	if maybeRestClientOk {
+		ll = maybeRestClient.ll
+		lg = maybeRestClient.lg
+		upcasters = maybeRestClient.upcasters
+		codecs = maybeRestClient.codecs
+		encryptor = maybeRestClient.encryptor
+		metadata = maybeRestClient.metadata
+		tracer = maybeRestClient.tracer
+	}
+	if ctor == nil {
+		ctor = func() *A {
+			return new(A)
+		}
+	}
+	return &TypedRepository[A]{
+		Client:          client,
+		entityTypeName:  entityTypeName,
+		ctor:            ctor,
+		commandHandlers: make(map[reflect.Type]func(aggregate *A, cmd Command) ([]Event, error)),
+		eventHandlers:   make(map[string]func(aggregate *A, entityId Int128, eventData, metadata string) error),
+		eventAppliers:   make(map[reflect.Type]func(aggregate *A, event Event)),
+		eventTypes:      make(map[reflect.Type]string),
+		upcasters:       upcasters,
+		codecs:          codecs,
+		encryptor:       encryptor,
+		metadata:        metadata,
+		tracer:          tracer,
+		ll:              ll,
+		lg:              lg}
+}
+
+// RegisterCommand makes commands of type C (passed by value or by pointer) processed by `handler`.
+// C may be a pointer type itself, the commands are keyed by the type they point to.
+func RegisterCommand[A, C any](repo *TypedRepository[A], handler func(aggregate *A, cmd C) ([]Event, error)) {
+	cmdType := reflect.TypeOf((*C)(nil)).Elem()
+	repo.commandHandlers[getUnderlyingType(cmdType)] = func(aggregate *A, cmd Command) ([]Event, error) {
+		switch typedCmd := cmd.(type) {
+		case C:
+			return handler(aggregate, typedCmd)
+		case *C:
+			return handler(aggregate, *typedCmd)
+		}
+		// a value passed to the handler of a pointer type
+		if value := reflect.ValueOf(cmd); cmdType.Kind() == reflect.Ptr && value.Type() == cmdType.Elem() {
+			pointer := reflect.New(value.Type())
+			pointer.Elem().Set(value)
+			return handler(aggregate, pointer.Interface().(C))
+		}
+		return nil, AppError("TypedRepository: unexpected command type %T", cmd)
+	}
+}
+
+// RegisterEvent makes events of type E stored under the name `eventType` and applied by `apply`.
+// The name is known to this repository only, subscriptions need it registered as a type hint of their client.
+func RegisterEvent[A, E any](repo *TypedRepository[A], eventType string, apply func(aggregate *A, event E)) error {
+	goType := reflect.TypeOf((*E)(nil)).Elem()
+	if registered, hasName := repo.eventTypes[goType]; hasName && registered != eventType {
+		return AppError("TypedRepository: %v is registered as `%s` already", goType, registered)
+	}
+
+	repo.eventTypes[goType] = eventType
+	repo.eventHandlers[eventType] = func(aggregate *A, entityId Int128, eventData, metadata string) error {
+		var event E
+		if err := repo.codecs.decode(eventData, metadata, &event); err != nil {
+			return AppError("Cannot deserialize Event of type `%s` into %v, Error: %v", eventType, goType, err)
+		}
+		if _, err := repo.encryptor.decryptFields(repo.entityTypeName, entityId, &event); err != nil {
+			return err
+		}
+		apply(aggregate, event)
+		return nil
+	}
+	repo.eventAppliers[goType] = func(aggregate *A, event Event) {
+		switch typedEvent := event.(type) {
+		case E:
+			apply(aggregate, typedEvent)
+		case *E:
+			apply(aggregate, *typedEvent)
+		}
+	}
+	return nil
+}
+
+func (repo *TypedRepository[A]) SetSnapshotStrategy(strategy SnapshotStrategy) *TypedRepository[A] {
+	repo.strategy = strategy
+	return repo
+}
+
+func (repo *TypedRepository[A]) SetUpcasters(registry *UpcasterRegistry) *TypedRepository[A] {
+	repo.upcasters = registry
+	return repo
+}
+
+func (repo *TypedRepository[A]) SetEventCodecs(codecs *EventCodecs) *TypedRepository[A] {
+	repo.codecs = codecs
+	return repo
+}
+
+// SetPIIEncryptor makes the fields tagged `eventuate:"pii"` of the events and snapshots encrypted,
+// see AggregateRepository.SetPIIEncryptor
+func (repo *TypedRepository[A]) SetPIIEncryptor(encryptor *PIIEncryptor) *TypedRepository[A] {
+	repo.encryptor = encryptor
+	return repo
+}
+
+func (repo *TypedRepository[A]) SetEventMetadataProvider(provider EventMetadataProvider) *TypedRepository[A] {
+	repo.metadata = provider
+	return repo
+}
+
+func (repo *TypedRepository[A]) SetTracer(tracer Tracer) *TypedRepository[A] {
+	repo.tracer = tracer
+	return repo
+}
+
+func (repo *TypedRepository[A]) SetLogLevel(level loglib.LogLevelEnum) *TypedRepository[A] {
+	repo.ll = level
+	repo.lg = loglib.NewLogger(level)
+	return repo
+}
+
+func (repo *TypedRepository[A]) SetLogger(lg loglib.LeveledLogger) *TypedRepository[A] {
+	repo.lg = lg
+	return repo
+}
+
+func (repo *TypedRepository[A]) Save(cmd Command) (*EntityMetadata, error) {
+	return repo.SaveCtx(context.Background(), cmd, nil)
+}
+
+// SaveCtx processes `cmd` against a new aggregate and saves the resulting events.
+// If the triggering event was already processed, the result has `AlreadyProcessed` set.
+func (repo *TypedRepository[A]) SaveCtx(ctx context.Context, cmd Command, saveOptions *SaveOptions) (*EntityMetadata, error) {
+	return repo.flow().saveCtx(ctx, cmd, saveOptions)
+}
+
+func (repo *TypedRepository[A]) Update(entityId Int128, cmd Command) (*EntityMetadata, error) {
+	return repo.UpdateCtx(context.Background(), entityId, cmd, nil)
+}
+
+// UpdateCtx processes `cmd` against the current state of the aggregate and saves the resulting events,
+// retrying on optimistic locking failures according to `updateOptions.Retry`.
+// If the triggering event was already processed, the result has `AlreadyProcessed` set.
+func (repo *TypedRepository[A]) UpdateCtx(ctx context.Context, entityId Int128, cmd Command, updateOptions *UpdateOptions) (*EntityMetadata, error) {
+	return repo.flow().updateCtx(ctx, entityId, cmd, updateOptions)
+}
+
+func (repo *TypedRepository[A]) Find(entityId Int128) (*A, error) {
+	return repo.FindCtx(context.Background(), entityId)
+}
+
+func (repo *TypedRepository[A]) FindCtx(ctx context.Context, entityId Int128) (*A, error) {
+	loaded, err := repo.flow().findCtx(ctx, entityId)
+	if err != nil {
+		return nil, err
+	}
+	return loaded.aggregate.(*A), nil
+}
+
+// flow is the save, update and find flow shared with AggregateRepository, see repositoryFlow
+func (repo *TypedRepository[A]) flow() *repositoryFlow {
+	return &repositoryFlow{
+		store:          repo,
+		client:         repo.Client,
+		entityTypeName: repo.entityTypeName,
+		encryptor:      repo.encryptor,
+		metadata:       repo.metadata,
+		tracer:         repo.tracer,
+		lg:             repo.lg}
+}
+
+// The aggregates of TypedRepository, see aggregateStore, are *A
+
+func (repo *TypedRepository[A]) newAggregate() (interface{}, error) {
+	return repo.ctor(), nil
+}
+
+func (repo *TypedRepository[A]) processCommand(aggregate interface{}, cmd Command) ([]Event, error) {
+	if cmd == nil {
+		return nil, AppError("TypedRepository: command cannot be nil")
+	}
+	handler, isRegistered := repo.commandHandlers[getUnderlyingType(reflect.TypeOf(cmd))]
+	if !isRegistered {
+		return nil, AppError("TypedRepository: no handler registered for command of type %T", cmd)
+	}
+	return handler(aggregate.(*A), cmd)
+}
+
+func (repo *TypedRepository[A]) serializeEvents(events []Event) ([]EventTypeAndData, error) {
+	mappedEvents := make([]EventTypeAndData, len(events))
+	for idx, event := range events {
+		if event == nil {
+			return nil, AppError("TypedRepository: command produced a nil Event")
+		}
+		eventType, isRegistered := repo.eventTypes[getUnderlyingType(reflect.TypeOf(event))]
+		if !isRegistered {
+			return nil, AppError("TypedRepository: Event of type %T is not registered", event)
+		}
+
+		serializedEvent, metadata, err := repo.codecs.encode(eventType, event, "")
+		if err != nil {
+			return nil, err
+		}
+
+		metadata, metadataErr := repo.upcasters.stamp(eventType, metadata)
+		if metadataErr != nil {
+			return nil, metadataErr
+		}
+
+		mappedEvents[idx] = EventTypeAndData{
+			EventType: eventType,
+			EventData: serializedEvent,
+			Metadata:  metadata}
+	}
+	return mappedEvents, nil
+}
+
+func (repo *TypedRepository[A]) restore(entityId Int128, loadedEvents *LoadedEvents) (interface{}, error) {
+	aggregate := repo.ctor()
+
+	if loadedEvents.Snapshot != nil && loadedEvents.Snapshot.SerializedSnapshot != nil {
+		snapshot := loadedEvents.Snapshot.SerializedSnapshot
+		if snapshot.SnapshotType != repo.entityTypeName {
+			return nil, AppError("Snapshot of type `%s` cannot be restored into `%s`",
+				snapshot.SnapshotType,
+				repo.entityTypeName)
+		}
+		if err := json.Unmarshal([]byte(snapshot.Json), aggregate); err != nil {
+			return nil, AppError("Cannot deserialize snapshot of type `%s`, json Error: %v", snapshot.SnapshotType, err)
+		}
+		if _, err := repo.encryptor.decryptFields(repo.entityTypeName, entityId, aggregate); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, event := range loadedEvents.Events {
+		eventType, eventData, upcastErr := repo.upcasters.Upcast(event.EventType, event.EventData, event.Metadata)
+		if upcastErr != nil {
+			return nil, upcastErr
+		}
+		handler, isRegistered := repo.eventHandlers[eventType]
+		if !isRegistered {
+			return nil, AppError("TypedRepository: no handler registered for Event of type `%s`", eventType)
+		}
+		if err := handler(aggregate, entityId, eventData, event.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return aggregate, nil
+}
+
+func (repo *TypedRepository[A]) snapshotStrategy() SnapshotStrategy {
+	return repo.strategy
+}
+
+func (repo *TypedRepository[A]) applyEvents(aggregate interface{}, events []Event) (interface{}, interface{}, error) {
+	typedAggregate := aggregate.(*A)
+	for _, event := range events {
+		repo.eventAppliers[getUnderlyingType(reflect.TypeOf(event))](typedAggregate, event)
+	}
+	return typedAggregate, typedAggregate, nil
+}
+
+func (repo *TypedRepository[A]) serializeSnapshot(aggregate interface{}, instance interface{}) (*SerializedSnapshot, error) {
+	serializedAggregate, jsonErr := json.Marshal(instance)
+	if jsonErr != nil {
+		return nil, AppError("Cannot serialize snapshot of (%v), json Error: %v", repo.entityTypeName, jsonErr)
+	}
+	return &SerializedSnapshot{
+		SnapshotType: repo.entityTypeName,
+		Json:         string(serializedAggregate)}, nil
+}
+
+func (repo *TypedRepository[A]) result(entityId Int128, entityVersion Int128) *EntityMetadata {
+	return &EntityMetadata{
+		EntityTypeName: repo.entityTypeName,
+		EntityId:       entityId,
+		EntityVersion:  entityVersion}
+}
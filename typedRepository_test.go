@@ -0,0 +1,134 @@
+package eventuate_test
+
+import (
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+type Account struct {
+	Balance int
+}
+
+type OpenAccount struct {
+	Deposit int
+}
+
+type Withdraw struct {
+	Amount int
+}
+
+type AccountOpened struct {
+	Deposit int
+}
+
+type MoneyWithdrawn struct {
+	Amount int
+}
+
+func newAccountRepository(t *testing.T, crud eventuate.Crud) *eventuate.TypedRepository[Account] {
+	repo := eventuate.NewTypedRepository[Account](crud, "net.chrisrichardson.eventstore.example.Account", nil)
+
+	eventuate.RegisterCommand(repo, func(account *Account, cmd OpenAccount) ([]eventuate.Event, error) {
+		return []eventuate.Event{AccountOpened{Deposit: cmd.Deposit}}, nil
+	})
+	eventuate.RegisterCommand(repo, func(account *Account, cmd Withdraw) ([]eventuate.Event, error) {
+		if account.Balance < cmd.Amount {
+			return nil, eventuate.AppError("insufficient funds")
+		}
+		return []eventuate.Event{&MoneyWithdrawn{Amount: cmd.Amount}}, nil
+	})
+
+	assertNoError(t, eventuate.RegisterEvent(repo, "net.chrisrichardson.eventstore.example.AccountOpened",
+		func(account *Account, evt AccountOpened) {
+			account.Balance = evt.Deposit
+		}))
+	assertNoError(t, eventuate.RegisterEvent(repo, "net.chrisrichardson.eventstore.example.MoneyWithdrawn",
+		func(account *Account, evt MoneyWithdrawn) {
+			account.Balance -= evt.Amount
+		}))
+	return repo
+}
+
+func TestTypedRepository_SaveFindUpdate(t *testing.T) {
+	crud := eventuate.NewInMemoryCrud()
+	repo := newAccountRepository(t, crud)
+
+	saved, err := repo.Save(&OpenAccount{Deposit: 100})
+	assertNoError(t, err)
+	assert.False(t, saved.EntityId.IsNil())
+
+	updated, err := repo.Update(saved.EntityId, Withdraw{Amount: 30})
+	assertNoError(t, err)
+	assert.Equal(t, saved.EntityId, updated.EntityId)
+
+	account, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, &Account{Balance: 70}, account)
+
+	_, err = repo.Update(saved.EntityId, &Withdraw{Amount: 100})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "insufficient funds")
+	}
+
+	_, err = repo.Update(saved.EntityId, &IncrementCommand{Amount: 1})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no handler registered for command")
+	}
+
+	loaded, err := crud.Find("net.chrisrichardson.eventstore.example.Account", saved.EntityId, nil)
+	assertNoError(t, err)
+	if assert.Len(t, loaded.Events, 2) {
+		assert.Equal(t, "net.chrisrichardson.eventstore.example.MoneyWithdrawn", loaded.Events[1].EventType)
+		assert.JSONEq(t, `{"Amount":30}`, loaded.Events[1].EventData)
+	}
+}
+
+func TestTypedRepository_Snapshots(t *testing.T) {
+	crud := eventuate.NewInMemoryCrud()
+	repo := newAccountRepository(t, crud).SetSnapshotStrategy(eventuate.NewEveryNEventsSnapshotStrategy(2))
+
+	saved, err := repo.Save(OpenAccount{Deposit: 100})
+	assertNoError(t, err)
+
+	for _, amount := range []int{10, 20} {
+		_, err = repo.Update(saved.EntityId, Withdraw{Amount: amount})
+		assertNoError(t, err)
+	}
+
+	loaded, err := crud.Find("net.chrisrichardson.eventstore.example.Account", saved.EntityId, nil)
+	assertNoError(t, err)
+	assert.Len(t, loaded.Events, 1)
+	if assert.NotNil(t, loaded.Snapshot) {
+		assert.JSONEq(t, `{"Balance":90}`, loaded.Snapshot.SerializedSnapshot.Json)
+	}
+
+	account, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, 70, account.Balance)
+}
+
+type Deposit struct {
+	Amount int
+}
+
+func TestTypedRepository_PointerCommandType(t *testing.T) {
+	repo := newAccountRepository(t, eventuate.NewInMemoryCrud())
+	eventuate.RegisterCommand(repo, func(account *Account, cmd *Deposit) ([]eventuate.Event, error) {
+		return []eventuate.Event{AccountOpened{Deposit: account.Balance + cmd.Amount}}, nil
+	})
+
+	saved, err := repo.Save(OpenAccount{Deposit: 100})
+	assertNoError(t, err)
+
+	// a handler of *C takes the commands passed by value or by pointer
+	_, err = repo.Update(saved.EntityId, &Deposit{Amount: 10})
+	assertNoError(t, err)
+	_, err = repo.Update(saved.EntityId, Deposit{Amount: 20})
+	assertNoError(t, err)
+
+	account, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, &Account{Balance: 130}, account)
+}
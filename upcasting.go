@@ -0,0 +1,168 @@
+package eventuate
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+)
+
+// EventMetadataSchemaVersion is the key of the event metadata holding the schema version of the event data.
+// Events without it are of version 1.
+const EventMetadataSchemaVersion = "schemaVersion"
+
+// Upcaster transforms the data of an event from one schema version to the next one
+type Upcaster func(eventData string) (string, error)
+
+// UpcastJSON makes an Upcaster out of a function that edits the fields of the event data in place
+func UpcastJSON(edit func(fields map[string]interface{}) error) Upcaster {
+	return func(eventData string) (string, error) {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(eventData), &fields); err != nil {
+			return "", err
+		}
+		if err := edit(fields); err != nil {
+			return "", err
+		}
+		result, err := json.Marshal(fields)
+		return string(result), err
+	}
+}
+
+// UpcasterRegistry upgrades the events read from the server to the current shape of their types.
+// Renamed event types are resolved first, then the upcasters registered for the (new) name
+// are chained starting from the schema version the event was written with.
+type UpcasterRegistry struct {
+	sync.RWMutex
+	upcasters map[string]map[int]Upcaster
+	renames   map[string]string
+}
+
+func NewUpcasterRegistry() *UpcasterRegistry {
+	return &UpcasterRegistry{
+		upcasters: make(map[string]map[int]Upcaster),
+		renames:   make(map[string]string)}
+}
+
+// RegisterUpcaster registers the transformation of `eventType` data from `fromVersion` to `fromVersion + 1`
+func (registry *UpcasterRegistry) RegisterUpcaster(eventType string, fromVersion int, upcaster Upcaster) *UpcasterRegistry {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, hasType := registry.upcasters[eventType]; !hasType {
+		registry.upcasters[eventType] = make(map[int]Upcaster)
+	}
+	registry.upcasters[eventType][fromVersion] = upcaster
+	return registry
+}
+
+// RenameEventType makes events stored as `oldEventType` read as `newEventType`
+func (registry *UpcasterRegistry) RenameEventType(oldEventType, newEventType string) *UpcasterRegistry {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.renames[oldEventType] = newEventType
+	return registry
+}
+
+// CurrentVersion is the schema version events of `eventType` are written with
+func (registry *UpcasterRegistry) CurrentVersion(eventType string) int {
+	if registry == nil {
+		return 1
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+
+	current := 1
+	for fromVersion := range registry.upcasters[eventType] {
+		if fromVersion >= current {
+			current = fromVersion + 1
+		}
+	}
+	return current
+}
+
+// OldEventTypes lists the names `eventType` was renamed from
+func (registry *UpcasterRegistry) OldEventTypes(eventType string) []string {
+	if registry == nil {
+		return nil
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var result []string
+	for oldEventType := range registry.renames {
+		if registry.resolve(oldEventType) == eventType {
+			result = append(result, oldEventType)
+		}
+	}
+	return result
+}
+
+// Upcast brings an event written with the metadata `metadata` to the current name and shape of its type
+func (registry *UpcasterRegistry) Upcast(eventType, eventData, metadata string) (string, string, error) {
+	if registry == nil {
+		return eventType, eventData, nil
+	}
+
+	version, versionErr := eventSchemaVersion(metadata)
+	if versionErr != nil {
+		return "", "", AppError("Cannot read the schema version of Event of type `%s`: %w", eventType, versionErr)
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	eventType = registry.resolve(eventType)
+	for {
+		upcaster, hasUpcaster := registry.upcasters[eventType][version]
+		if !hasUpcaster {
+			return eventType, eventData, nil
+		}
+
+		var err error
+		if eventData, err = upcaster(eventData); err != nil {
+			return "", "", AppError("Cannot upcast Event of type `%s` from version %d: %w", eventType, version, err)
+		}
+		version++
+	}
+}
+
+// resolve follows the renames of `eventType`. Must be called with the registry locked.
+func (registry *UpcasterRegistry) resolve(eventType string) string {
+	// a rename cycle cannot be followed further than the number of renames
+	for hops := 0; hops < len(registry.renames); hops++ {
+		newEventType, isRenamed := registry.renames[eventType]
+		if !isRenamed {
+			break
+		}
+		eventType = newEventType
+	}
+	return eventType
+}
+
+// stamp records the current schema version of `eventType` in the event metadata
+func (registry *UpcasterRegistry) stamp(eventType, metadata string) (string, error) {
+	version := registry.CurrentVersion(eventType)
+	if version == 1 {
+		return metadata, nil
+	}
+	return setEventMetadata(metadata, EventMetadataSchemaVersion, strconv.Itoa(version))
+}
+
+func eventSchemaVersion(metadata string) (int, error) {
+	version, hasVersion, err := eventMetadataValue(metadata, EventMetadataSchemaVersion)
+	if err != nil || !hasVersion {
+		return 1, err
+	}
+	return strconv.Atoi(version)
+}
+
+// SetUpcasters sets the registry the repositories made with this client upcast events with
+func (rest *RESTClient) SetUpcasters(registry *UpcasterRegistry) {
+	rest.upcasters = registry
+}
+
+// SetUpcasters sets the registry the subscriptions made with this client upcast events with
+func (stomp *StompClient) SetUpcasters(registry *UpcasterRegistry) {
+	stomp.upcasters = registry
+}
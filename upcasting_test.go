@@ -0,0 +1,113 @@
+package eventuate_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eventuate-clients/eventuate-client-golang"
+	"github.com/eventuate-clients/eventuate-client-golang/future"
+	"github.com/stretchr/testify/assert"
+)
+
+const COUNTER_ADDED = "net.chrisrichardson.eventstore.example.CounterAddedEvent"
+
+// newCounterUpcasters reads the former `CounterAddedEvent{Value}` as `CounterIncrementedEvent{Amount}`
+func newCounterUpcasters() *eventuate.UpcasterRegistry {
+	return eventuate.NewUpcasterRegistry().
+		RenameEventType(COUNTER_ADDED, COUNTER_INCREMENTED).
+		RegisterUpcaster(COUNTER_INCREMENTED, 1, eventuate.UpcastJSON(func(fields map[string]interface{}) error {
+			fields["Amount"] = fields["Value"]
+			delete(fields, "Value")
+			return nil
+		}))
+}
+
+func TestUpcasterRegistry_Upcast(t *testing.T) {
+	registry := newCounterUpcasters()
+	assert.Equal(t, 2, registry.CurrentVersion(COUNTER_INCREMENTED))
+	assert.Equal(t, []string{COUNTER_ADDED}, registry.OldEventTypes(COUNTER_INCREMENTED))
+
+	eventType, eventData, err := registry.Upcast(COUNTER_ADDED, `{"Value":2}`, "")
+	assertNoError(t, err)
+	assert.Equal(t, COUNTER_INCREMENTED, eventType)
+	assert.JSONEq(t, `{"Amount":2}`, eventData)
+
+	eventType, eventData, err = registry.Upcast(COUNTER_INCREMENTED, `{"Amount":3}`, `{"schemaVersion":"2"}`)
+	assertNoError(t, err)
+	assert.Equal(t, COUNTER_INCREMENTED, eventType)
+	assert.Equal(t, `{"Amount":3}`, eventData)
+
+	_, _, err = registry.Upcast(COUNTER_INCREMENTED, `{"Amount":3}`, `{"schemaVersion":"two"}`)
+	assert.Error(t, err)
+
+	var nilRegistry *eventuate.UpcasterRegistry
+	eventType, eventData, err = nilRegistry.Upcast(COUNTER_ADDED, `{"Value":2}`, "")
+	assertNoError(t, err)
+	assert.Equal(t, COUNTER_ADDED, eventType)
+	assert.Equal(t, `{"Value":2}`, eventData)
+}
+
+func TestAggregateRepository_Upcasting(t *testing.T) {
+	meta, err := eventuate.CreateAggregateMetadata(NewCounterAggregate, COUNTER_ENTITY)
+	assertNoError(t, err)
+
+	crud := eventuate.NewInMemoryCrud()
+	repo := eventuate.NewAggregateRepository(crud, meta).SetUpcasters(newCounterUpcasters())
+	assertNoError(t, repo.RegisterEventType(COUNTER_INCREMENTED, CounterIncrementedEvent{}))
+
+	saved, err := crud.Save(COUNTER_ENTITY, []eventuate.EventTypeAndData{{
+		EventType: COUNTER_ADDED,
+		EventData: `{"Value":2}`}}, nil)
+	assertNoError(t, err)
+
+	_, err = repo.Update(saved.EntityId, &IncrementCommand{Amount: 3})
+	assertNoError(t, err)
+
+	found, err := repo.Find(saved.EntityId)
+	assertNoError(t, err)
+	assert.Equal(t, 5, found.EntityInstance.(*CounterAggregate).Total)
+
+	loaded, err := crud.Find(COUNTER_ENTITY, saved.EntityId, nil)
+	assertNoError(t, err)
+	if assert.Len(t, loaded.Events, 2) {
+		assert.Equal(t, COUNTER_INCREMENTED, loaded.Events[1].EventType)
+		assert.JSONEq(t, fmt.Sprintf(`{%q:"2"}`, eventuate.EventMetadataSchemaVersion), loaded.Events[1].Metadata)
+	}
+}
+
+func TestSubscribeAndDispatch_Upcasting(t *testing.T) {
+	srv := newEmulator(t)
+	defer srv.Close()
+
+	const EVENT_REGISTERED = "net.chrisrichardson.eventstore.example.MyEntityWasRegistered"
+	upcasters := eventuate.NewUpcasterRegistry().
+		RenameEventType(EVENT_REGISTERED, EVENT_CREATED).
+		RegisterUpcaster(EVENT_CREATED, 1, eventuate.UpcastJSON(func(fields map[string]interface{}) error {
+			fields["name"] = fields["fullName"]
+			return nil
+		}))
+
+	repoClient := buildREST(t, srv.ClientBuilder())
+	stomp := buildSTOMP(t, srv.ClientBuilder().
+		WithTypeHintPair(EVENT_CREATED, MyEntityWasCreatedEvent{}).
+		WithUpcasters(upcasters))
+
+	received := make(chan interface{}, 1)
+	handlers := eventuate.NewEventResultHandlerMap().AddHandler(ENTITY_TYPE, EVENT_CREATED,
+		func(data interface{}, meta *eventuate.EventMetadata) future.Settler {
+			assert.Equal(t, EVENT_CREATED, meta.EventType)
+			received <- data
+			return future.NewSuccess(true)
+		})
+
+	_, err := stomp.SubscribeAndDispatch("upcasting-subscriber", handlers, nil, false)
+	assert.Nil(t, err)
+
+	_, err = repoClient.Save(ENTITY_TYPE, []eventuate.EventTypeAndData{
+		{
+			EventType: EVENT_REGISTERED,
+			EventData: `{"fullName":"Ford Prefect"}`}}, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, &MyEntityWasCreatedEvent{Name: "Ford Prefect"}, receive(t, received))
+}